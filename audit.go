@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"token-transfer-api/audit"
+	"token-transfer-api/cli"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
+)
+
+// runAudit implements the "audit verify", "audit checkpoint" and "audit public-key"
+// commands.
+func runAudit(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: audit verify | checkpoint | public-key")
+		os.Exit(2)
+	}
+
+	private, public, err := cli.AuditKeys(cfg.Features)
+	if err != nil {
+		fatal("failed to load the audit keys", err)
+	}
+	if args[0] == "public-key" {
+		if public == nil {
+			fmt.Fprintln(os.Stderr, "no audit key is configured")
+			os.Exit(2)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(public))
+		return
+	}
+
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+	s := cli.NewStore(cfg.Database, database)
+
+	ctx := context.Background()
+	switch args[0] {
+	case "verify":
+		report, err := audit.Verify(ctx, s, public)
+		if err != nil {
+			fatal("failed to verify the audit log", err)
+		}
+		if len(report.Problems) > 0 {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KIND\tACCOUNT\tSEQ\tDETAIL")
+			for _, problem := range report.Problems {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", problem.Kind, problem.Account, problem.Seq, problem.Detail)
+			}
+			w.Flush()
+		}
+		fmt.Printf("verified %d audit records and %d checkpoints, %d problems\n", report.Records, report.Checkpoints, len(report.Problems))
+		if !report.SignaturesVerified {
+			fmt.Println("checkpoint signatures were not checked: no audit key is configured")
+		}
+		if !report.OK() {
+			os.Exit(1)
+		}
+	case "checkpoint":
+		if private == nil {
+			fmt.Fprintln(os.Stderr, "AUDIT_KEY is required to sign checkpoints")
+			os.Exit(2)
+		}
+		checkpoint, err := audit.Checkpoint(ctx, s, private)
+		if err != nil {
+			fatal("failed to checkpoint the audit log", err)
+		}
+		if checkpoint == nil {
+			fmt.Println("no audit chain changed since the latest checkpoint")
+			return
+		}
+		fmt.Printf("checkpoint %d signed %d chains: %s\n", checkpoint.ID, len(checkpoint.Entries), checkpoint.Hash)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown audit command %q\n", args[0])
+		os.Exit(2)
+	}
+}
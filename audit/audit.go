@@ -0,0 +1,138 @@
+// Package audit maintains the tamper-evident audit log. Every row holding balance has a
+// hash chain of models.AuditRecord, appended in the transaction that changes the row.
+// Checkpoints periodically sign the heads of the chains, so records they cover cannot be
+// rewritten without the signing key, and Verify detects records and balances that were
+// edited or deleted behind the application's back.
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+	"token-transfer-api/ledger"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// ParseKey decodes the base64 seed of the ed25519 key that signs checkpoints.
+func ParseKey(encoded string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("audit key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParsePublicKey decodes the base64 public key that checks the signatures of checkpoints.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("audit public key must be %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// rowRecords returns records holding the current balances of a wallet row and its shards.
+func rowRecords(wallet *models.Wallet, shards []*models.WalletShard, operation string) []*models.AuditRecord {
+	records := []*models.AuditRecord{{
+		Account:   models.WalletAccount(wallet.Address),
+		Address:   wallet.Address,
+		Operation: operation,
+		Balance:   wallet.Balance,
+	}}
+	for _, shard := range shards {
+		records = append(records, &models.AuditRecord{
+			Account:   models.ShardAccount(shard.Address, shard.Shard),
+			Address:   shard.Address,
+			Operation: operation,
+			Balance:   shard.Balance,
+		})
+	}
+	return records
+}
+
+// Open starts the chains of the rows that predate the audit log with a models.AuditOpen
+// record of their balance. It runs at startup and does nothing once the log has any
+// record, so rows inserted behind the application's back later are not adopted.
+func Open(ctx context.Context, s store.Store) error {
+	return s.Transaction(ctx, func(tx store.Tx) error {
+		addresses, err := tx.AuditAddresses(ctx)
+		if err != nil {
+			return err
+		}
+		if len(addresses) > 0 {
+			return nil
+		}
+		wallets, err := tx.Wallets(ctx)
+		if err != nil {
+			return err
+		}
+		for _, wallet := range wallets {
+			wallet, shards, err := ledger.LockWallet(ctx, tx, wallet.Address)
+			if err != nil {
+				return err
+			}
+			if err := tx.AppendAuditRecords(ctx, rowRecords(wallet, shards, models.AuditOpen)...); err != nil {
+				return err
+			}
+		}
+		if len(wallets) > 0 {
+			slog.InfoContext(ctx, "opened audit chains", "wallets", len(wallets))
+		}
+		return nil
+	})
+}
+
+// Checkpoint signs the heads of the chains that changed since the latest checkpoint, and
+// returns the new checkpoint. It returns nil if no chain changed.
+func Checkpoint(ctx context.Context, s store.Store, key ed25519.PrivateKey) (*models.AuditCheckpoint, error) {
+	latest, err := s.LatestAuditCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	checkpointed, err := s.CheckpointedHeads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	heads, err := s.AuditHeads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	covered := make(map[string]int64, len(checkpointed))
+	for _, entry := range checkpointed {
+		covered[entry.Account] = entry.Seq
+	}
+	checkpoint := &models.AuditCheckpoint{CreatedAt: time.Now().UTC().Truncate(time.Microsecond)}
+	if latest != nil {
+		checkpoint.PrevHash = latest.Hash
+	}
+	for _, head := range heads {
+		if covered[head.Account] != head.Seq {
+			checkpoint.Entries = append(checkpoint.Entries, models.AuditCheckpointEntry{Account: head.Account, Seq: head.Seq, Hash: head.Hash})
+		}
+	}
+	if len(checkpoint.Entries) == 0 {
+		return nil, nil
+	}
+
+	checkpoint.Hash = checkpoint.ComputeHash()
+	checkpoint.Signature = hex.EncodeToString(ed25519.Sign(key, []byte(checkpoint.Hash)))
+	if err := s.SaveAuditCheckpoint(ctx, checkpoint); err != nil {
+		return nil, err
+	}
+	// The hash also goes to the logs, outside the database, so the latest checkpoints
+	// cannot be deleted unnoticed either.
+	slog.InfoContext(ctx, "signed audit checkpoint", "id", checkpoint.ID, "hash", checkpoint.Hash, "accounts", len(checkpoint.Entries))
+	return checkpoint, nil
+}
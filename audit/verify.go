@@ -0,0 +1,232 @@
+package audit
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"token-transfer-api/ledger"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// Kinds of problems Verify reports.
+const (
+	// ProblemEdited is a record whose Hash does not match its content.
+	ProblemEdited = "edited"
+	// ProblemBrokenLink is a record whose PrevHash is not the Hash of the record before
+	// it in its chain: records were deleted or inserted in between.
+	ProblemBrokenLink = "broken_link"
+	// ProblemBalance is a row whose balance differs from the head of its chain, or a
+	// deleted row whose chain ends with a balance.
+	ProblemBalance = "balance_mismatch"
+	// ProblemUnaudited is a row without a chain.
+	ProblemUnaudited = "unaudited"
+	// ProblemCheckpoint is a checkpoint that was edited, is not signed by the audit key
+	// or does not follow the checkpoint before it.
+	ProblemCheckpoint = "checkpoint"
+	// ProblemMissing is a record covered by a checkpoint that no longer exists as signed.
+	ProblemMissing = "missing_record"
+)
+
+// pageSize is how many records Verify reads at a time.
+const pageSize = 1000
+
+// Problem is a sign of tampering found by Verify. Seq is the record or checkpoint it
+// concerns, if any.
+type Problem struct {
+	Kind    string
+	Account string
+	Seq     int64
+	Detail  string
+}
+
+// Report is the outcome of Verify.
+type Report struct {
+	Records     int
+	Checkpoints int
+	// SignaturesVerified is false when Verify had no key to check checkpoints with.
+	SignaturesVerified bool
+	Problems           []Problem
+}
+
+// OK reports whether no problem was found.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Verify checks the checkpoints against key, each chain link by link and against the
+// checkpoints covering it, and the balance of every row against the head of its chain.
+// Each wallet is checked in a transaction holding the locks on its row and shards, so
+// transfers in flight do not show up as problems. Without a key, the signatures of
+// checkpoints are not checked.
+func Verify(ctx context.Context, s store.Store, key ed25519.PublicKey) (*Report, error) {
+	report := &Report{SignaturesVerified: key != nil}
+
+	checkpoints, err := s.AuditCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// covered maps the records signed by checkpoints to their hash.
+	covered := map[chainLink]string{}
+	prev := ""
+	for _, checkpoint := range checkpoints {
+		report.Checkpoints++
+		if problem := checkCheckpoint(checkpoint, prev, key); problem != "" {
+			report.Problems = append(report.Problems, Problem{Kind: ProblemCheckpoint, Seq: checkpoint.ID, Detail: problem})
+		}
+		prev = checkpoint.Hash
+		for _, entry := range checkpoint.Entries {
+			covered[chainLink{entry.Account, entry.Seq}] = entry.Hash
+		}
+	}
+
+	wallets, err := s.Wallets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := s.AuditAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, wallet := range wallets {
+		addresses = append(addresses, wallet.Address)
+	}
+	slices.Sort(addresses)
+	addresses = slices.Compact(addresses)
+
+	for _, address := range addresses {
+		var check walletCheck
+		err := s.Transaction(ctx, func(tx store.Tx) error {
+			check = walletCheck{covered: covered}
+			return check.run(ctx, tx, address)
+		})
+		if err != nil {
+			return nil, err
+		}
+		report.Records += check.records
+		report.Problems = append(report.Problems, check.problems...)
+		for _, seen := range check.seen {
+			delete(covered, seen)
+		}
+	}
+
+	missing := slices.SortedFunc(maps.Keys(covered), func(a, b chainLink) int {
+		return cmp.Or(strings.Compare(a.account, b.account), cmp.Compare(a.seq, b.seq))
+	})
+	for _, link := range missing {
+		report.Problems = append(report.Problems, Problem{Kind: ProblemMissing, Account: link.account, Seq: link.seq,
+			Detail: "record signed by a checkpoint was deleted"})
+	}
+	return report, nil
+}
+
+// chainLink identifies a record by its account and Seq.
+type chainLink struct {
+	account string
+	seq     int64
+}
+
+// checkCheckpoint returns what is wrong with a checkpoint that should follow the one
+// with hash prev, or "" if nothing is.
+func checkCheckpoint(checkpoint *models.AuditCheckpoint, prev string, key ed25519.PublicKey) string {
+	if checkpoint.PrevHash != prev {
+		return "does not follow the previous checkpoint"
+	}
+	if checkpoint.ComputeHash() != checkpoint.Hash {
+		return "hash does not match its entries"
+	}
+	if key == nil {
+		return ""
+	}
+	signature, err := hex.DecodeString(checkpoint.Signature)
+	if err != nil || !ed25519.Verify(key, []byte(checkpoint.Hash), signature) {
+		return "signature does not match the audit key"
+	}
+	return ""
+}
+
+// walletCheck verifies the chains of the rows of one wallet.
+type walletCheck struct {
+	covered  map[chainLink]string
+	records  int
+	problems []Problem
+	// seen are the covered records found.
+	seen []chainLink
+}
+
+func (c *walletCheck) problem(kind string, account string, seq int64, format string, args ...any) {
+	c.problems = append(c.problems, Problem{Kind: kind, Account: account, Seq: seq, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (c *walletCheck) run(ctx context.Context, tx store.Tx, address string) error {
+	rows := map[string]int{}
+	wallet, shards, err := ledger.LockWallet(ctx, tx, address)
+	if err != nil && !errors.Is(err, models.ErrWalletNotFound) {
+		return err
+	}
+	if err == nil {
+		for _, record := range rowRecords(wallet, shards, "") {
+			rows[record.Account] = record.Balance
+		}
+	}
+
+	heads := map[string]*models.AuditRecord{}
+	var after int64
+	for {
+		records, err := tx.AuditRecords(ctx, address, after, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			c.records++
+			prev := ""
+			if head := heads[record.Account]; head != nil {
+				prev = head.Hash
+			}
+			if record.ComputeHash() != record.Hash {
+				c.problem(ProblemEdited, record.Account, record.Seq, "hash does not match the record")
+			}
+			if record.PrevHash != prev {
+				c.problem(ProblemBrokenLink, record.Account, record.Seq, "does not follow the previous record of its chain")
+			}
+			link := chainLink{record.Account, record.Seq}
+			if signed, ok := c.covered[link]; ok {
+				c.seen = append(c.seen, link)
+				if signed != record.Hash {
+					c.problem(ProblemMissing, record.Account, record.Seq, "record differs from the one signed by a checkpoint")
+				}
+			}
+			heads[record.Account] = record
+			after = record.Seq
+		}
+		if len(records) < pageSize {
+			break
+		}
+	}
+
+	accounts := slices.Collect(maps.Keys(rows))
+	for account := range heads {
+		accounts = append(accounts, account)
+	}
+	slices.Sort(accounts)
+	accounts = slices.Compact(accounts)
+	for _, account := range accounts {
+		head, audited := heads[account]
+		balance, exists := rows[account]
+		switch {
+		case !audited:
+			c.problem(ProblemUnaudited, account, 0, "row holding %d has no audit records", balance)
+		case !exists && head.Balance != 0:
+			c.problem(ProblemBalance, account, head.Seq, "row was deleted holding %d", head.Balance)
+		case exists && head.Balance != balance:
+			c.problem(ProblemBalance, account, head.Seq, "row holds %d, audit log %d", balance, head.Balance)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package blocks seals committed transfers into numbered blocks, the epochs of the
+// ledger that downstream indexers consume. Every block holds the transfers committed
+// since the previous one, oldest first, and a header chaining it to that block and
+// committing to its transfers and to the balances once they are applied. See
+// models.Block.
+package blocks
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"sync/atomic"
+	"time"
+	"token-transfer-api/config"
+	"token-transfer-api/ledger"
+	"token-transfer-api/merkle"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// Seal puts the transfers that belong to no block into a new block, and returns it. The
+// transfers and the balances of the state root are read from one snapshot, see
+// ledger.Balances, so the state root is the balances with exactly the block's transfers
+// applied, and only the transfers are locked, to stamp them with the block number.
+// Wallets created and tokens minted since the previous block are part of the state root.
+// It returns nil if no transfer is waiting.
+func Seal(ctx context.Context, s store.Store) (*models.Block, error) {
+	var sealed *models.Block
+	err := s.Snapshot(ctx, func(tx store.Tx) error {
+		sealed = nil
+		transfers, err := tx.UnsealedTransfers(ctx)
+		if err != nil || len(transfers) == 0 {
+			return err
+		}
+		wallets, err := ledger.Balances(ctx, tx)
+		if err != nil {
+			return err
+		}
+		parent, err := tx.LatestBlock(ctx)
+		if err != nil {
+			return err
+		}
+
+		balances := make(map[string]int, len(wallets))
+		for _, wallet := range wallets {
+			balances[wallet.Address] = wallet.Balance
+		}
+		block := &models.Block{
+			Number:        1,
+			TransferRoot:  TransferRoot(transfers),
+			StateRoot:     stateRoot(balances),
+			TransferCount: len(transfers),
+			CreatedAt:     time.Now().UTC().Truncate(time.Microsecond),
+		}
+		if parent != nil {
+			block.Number, block.ParentHash = parent.Number+1, parent.Hash
+		}
+		block.Hash = block.ComputeHash()
+		if err := tx.SaveBlock(ctx, block, transfers); err != nil {
+			return err
+		}
+		sealed = block
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sealed != nil {
+		slog.InfoContext(ctx, "sealed block", "number", sealed.Number, "hash", sealed.Hash, "transfers", sealed.TransferCount)
+	}
+	return sealed, nil
+}
+
+// stateRoot returns the root of the tree of balances, built like a balance root.
+func stateRoot(balances map[string]int) string {
+	leaves := make([]merkle.Leaf, 0, len(balances))
+	for address, balance := range balances {
+		leaves = append(leaves, merkle.Leaf{Address: address, Balance: balance})
+	}
+	return merkle.Build(leaves).Root()
+}
+
+// TransferRoot returns the Merkle root of transfers in the order given. A leaf is
+// HashData of the 16 bytes of the ID, the length and bytes of the sender and of the
+// receiver, the amount and the creation time in Unix microseconds, each number an 8-byte
+// big-endian integer.
+func TransferRoot(transfers []*models.Transfer) string {
+	hashes := make([][]byte, len(transfers))
+	for i, transfer := range transfers {
+		data := append([]byte(nil), transfer.ID[:]...)
+		data = binary.BigEndian.AppendUint64(data, uint64(len(transfer.FromAddress)))
+		data = append(data, transfer.FromAddress...)
+		data = binary.BigEndian.AppendUint64(data, uint64(len(transfer.ToAddress)))
+		data = append(data, transfer.ToAddress...)
+		data = binary.BigEndian.AppendUint64(data, uint64(int64(transfer.Amount)))
+		data = binary.BigEndian.AppendUint64(data, uint64(transfer.CreatedAt.UnixMicro()))
+		hashes[i] = merkle.HashData(data)
+	}
+	return merkle.Root(hashes)
+}
+
+// Producer seals a block every Interval, and as soon as MaxTransfers transfers have
+// committed since the last time it did.
+type Producer struct {
+	store store.Store
+	cfg   config.BlocksConfig
+
+	committed atomic.Int64
+	full      chan struct{}
+}
+
+func NewProducer(s store.Store, cfg config.BlocksConfig) *Producer {
+	return &Producer{store: s, cfg: cfg, full: make(chan struct{}, 1)}
+}
+
+// TransferCommitted counts a committed transfer, and wakes Run once a block's worth of
+// them has committed.
+func (p *Producer) TransferCommitted() {
+	if p.committed.Add(1) == int64(p.cfg.MaxTransfers) {
+		select {
+		case p.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run seals blocks until ctx is done.
+func (p *Producer) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.full:
+		}
+		p.committed.Store(0)
+		if _, err := Seal(ctx, p.store); err != nil {
+			slog.ErrorContext(ctx, "failed to seal a block", "error", err)
+		}
+	}
+}
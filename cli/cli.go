@@ -0,0 +1,87 @@
+// Package cli holds what the server's commands and ttactl share: building the store and
+// the resolver from the configuration, and the migrate and reconcile commands, so that
+// both binaries behave the same.
+package cli
+
+import (
+	"crypto/ed25519"
+	"database/sql"
+	"token-transfer-api/audit"
+	"token-transfer-api/blocks"
+	"token-transfer-api/config"
+	"token-transfer-api/graph"
+	"token-transfer-api/models"
+	"token-transfer-api/rules"
+	"token-transfer-api/store"
+	"token-transfer-api/store/gormstore"
+
+	"gorm.io/gorm"
+)
+
+// NewStore returns the store the resolvers run on, retrying transactions that fail with
+// transient errors as configured.
+func NewStore(cfg config.DatabaseConfig, database *gorm.DB) store.Store {
+	base := gormstore.New(database)
+	if cfg.Isolation == "serializable" {
+		base.Isolation = sql.LevelSerializable
+	}
+	return store.WithRetry(base, store.RetryPolicy{
+		MaxAttempts: cfg.RetryAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+		Budget:      cfg.RetryBudget,
+	}, gormstore.Retryable)
+}
+
+// AuditKeys returns the key that signs audit checkpoints and the key that checks them,
+// either of which is nil if not configured.
+func AuditKeys(cfg config.FeaturesConfig) (ed25519.PrivateKey, ed25519.PublicKey, error) {
+	var private ed25519.PrivateKey
+	var public ed25519.PublicKey
+	if cfg.AuditKey != "" {
+		key, err := audit.ParseKey(cfg.AuditKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		private, public = key, key.Public().(ed25519.PublicKey)
+	}
+	if cfg.AuditPublicKey != "" {
+		key, err := audit.ParsePublicKey(cfg.AuditPublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		public = key
+	}
+	return private, public, nil
+}
+
+// NewResolver returns a resolver on s configured as cfg says, so that transfers obey the
+// same limits and rules wherever they are made. Its block producer, if blocks are
+// enabled, is not running: the caller starts it.
+func NewResolver(cfg *config.Config, s store.Store) (*graph.Resolver, error) {
+	_, auditPublicKey, err := AuditKeys(cfg.Features)
+	if err != nil {
+		return nil, err
+	}
+	resolver := &graph.Resolver{
+		Store:           s,
+		ProposalTTL:     cfg.Features.ProposalTTL,
+		HotWallets:      cfg.Features.HotWallets,
+		HotWalletShards: cfg.Features.HotWalletShards,
+		Admins:          cfg.Server.AdminPrincipals,
+		AuditKey:        auditPublicKey,
+	}
+	if cfg.Features.UnverifiedLimits {
+		resolver.UnverifiedLimits = &models.DefaultUnverifiedLimits
+	}
+	if cfg.Features.RulesFile != "" {
+		resolver.Rules, err = rules.LoadFile(cfg.Features.RulesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Blocks.Enabled {
+		resolver.Blocks = blocks.NewProducer(s, cfg.Blocks)
+	}
+	return resolver, nil
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"token-transfer-api/db/migrations"
+)
+
+// ParseSteps parses the STEPS argument of "migrate down": a positive number of
+// migrations to revert, or "all", for which it returns -1.
+func ParseSteps(arg string) (int, error) {
+	if arg == "all" {
+		return -1, nil
+	}
+	steps, err := strconv.Atoi(arg)
+	if err != nil || steps < 1 {
+		return 0, fmt.Errorf("%q is not a positive number", arg)
+	}
+	return steps, nil
+}
+
+// WriteMigrations writes the migrations that were applied or reverted, as verb says,
+// one per line.
+func WriteMigrations(w io.Writer, verb string, done []migrations.Migration) {
+	for _, migration := range done {
+		fmt.Fprintf(w, "%s %04d_%s\n", verb, migration.Version, migration.Name)
+	}
+	if len(done) == 0 {
+		fmt.Fprintf(w, "no migrations %s\n", verb)
+	}
+}
+
+// WriteMigrationStatus writes the status of every migration as a tab-separated table.
+func WriteMigrationStatus(w io.Writer, statuses []migrations.Status) {
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"token-transfer-api/models"
+)
+
+// WriteReconciliation writes the discrepancies of report as a tab-separated table, if
+// there are any, followed by a summary line.
+func WriteReconciliation(w io.Writer, report *models.ReconciliationReport) {
+	if len(report.Discrepancies) > 0 {
+		fmt.Fprintln(w, "KIND\tADDRESS\tEXPECTED\tACTUAL\tDETAIL")
+		for _, discrepancy := range report.Discrepancies {
+			address := discrepancy.Address
+			if address == "" {
+				address = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", discrepancy.Kind, address, discrepancy.Expected, discrepancy.Actual, discrepancy.Detail)
+		}
+	}
+	fmt.Fprintf(w, "reconciliation report %d: %d wallets holding %d, minted %d, burned %d, %d discrepancies\n",
+		report.ID, report.Wallets, report.Supply, report.Minted, report.Burned, len(report.Discrepancies))
+}
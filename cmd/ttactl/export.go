@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"token-transfer-api/dump"
+)
+
+// summaryView is the summary of an export or import as ttactl prints it.
+type summaryView struct {
+	Directory string `json:"directory"`
+	Format    string `json:"format"`
+	Wallets   int    `json:"wallets"`
+	Transfers int    `json:"transfers"`
+	Supply    int    `json:"supply"`
+}
+
+// runExport implements the "export" command, which writes every wallet and transfer to
+// DIR in one of the formats of package dump.
+func (a *app) runExport(args []string) {
+	rest := a.parse("export", "csv|jsonl|parquet DIR", args, 2, 2)
+	format := parseFormat(rest[0])
+
+	summary, err := dump.Export(context.Background(), a.store(), rest[1], format)
+	if err != nil {
+		fail("failed to export the ledger", err)
+	}
+	a.printSummary("exported", rest[1], format, summary)
+}
+
+// runImport implements the "import" command, which adds an export to the ledger if it
+// conserves supply.
+func (a *app) runImport(args []string) {
+	rest := a.parse("import", "csv|jsonl|parquet DIR", args, 2, 2)
+	format := parseFormat(rest[0])
+
+	a.confirm(fmt.Sprintf("Import the wallets and transfers in %s", rest[1]))
+	summary, err := dump.Import(context.Background(), a.store(), rest[1], format)
+	if err != nil {
+		fail("failed to import the ledger", err)
+	}
+	a.printSummary("imported", rest[1], format, summary)
+}
+
+func (a *app) printSummary(verb string, dir string, format dump.Format, summary *dump.Summary) {
+	view := &summaryView{
+		Directory: dir,
+		Format:    string(format),
+		Wallets:   summary.Wallets,
+		Transfers: summary.Transfers,
+		Supply:    summary.Supply,
+	}
+	a.print(view, func(w io.Writer) {
+		fmt.Fprintf(w, "%s %d wallets holding %d and %d transfers as %s in %s\n", verb, view.Wallets, view.Supply, view.Transfers, view.Format, view.Directory)
+	})
+}
+
+// parseFormat parses an export format argument, exiting if it names none.
+func parseFormat(arg string) dump.Format {
+	format, err := dump.ParseFormat(arg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return format
+}
@@ -0,0 +1,182 @@
+// Command ttactl inspects and fixes wallets and the ledger from the command line, so
+// operators do not have to edit the database with psql. It reads the same configuration
+// as the server, from the environment, a .env file or flags before the command:
+//
+//	ttactl [-config FILE] [-db-driver postgres|sqlite ...] COMMAND [-o table|json] [-y] [ARGS]
+//
+// Commands that move tokens, change the supply, freeze wallets, import an export or
+// revert migrations ask for confirmation first, unless -y is given.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"token-transfer-api/cli"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
+	"token-transfer-api/graph"
+	"token-transfer-api/store"
+
+	"gorm.io/gorm"
+)
+
+const usage = `usage: ttactl [settings flags] COMMAND [-o table|json] [-y] [ARGS]
+
+commands:
+  wallet list                         list every wallet
+  wallet show ADDRESS                 show a wallet, its limits and locked balance
+  wallet create ADDRESS [BALANCE]     create a wallet
+  wallet freeze ADDRESS               stop a wallet from sending and receiving
+  wallet unfreeze ADDRESS             let a frozen wallet transfer again
+  transfer FROM TO AMOUNT             transfer tokens, subject to limits and rules
+  mint ADDRESS AMOUNT                 credit a wallet with new tokens
+  export csv|jsonl|parquet DIR        write every wallet and transfer to DIR
+  import csv|jsonl|parquet DIR        add an export to the ledger if it conserves supply
+  reconcile                           reconcile the ledger; exits 1 on discrepancies
+  migrate up | down [STEPS|all] | status
+
+options:
+  -o table|json    output format (default table)
+  -y               do not ask for confirmation
+`
+
+// errAborted is returned when the operator declines a confirmation prompt.
+var errAborted = errors.New("aborted")
+
+func main() {
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		fail("invalid configuration", err)
+	}
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	app := &app{cfg: cfg}
+	switch args[0] {
+	case "wallet":
+		app.runWallet(args[1:])
+	case "transfer":
+		app.runTransfer(args[1:])
+	case "mint":
+		app.runMint(args[1:])
+	case "export":
+		app.runExport(args[1:])
+	case "import":
+		app.runImport(args[1:])
+	case "reconcile":
+		app.runReconcile(args[1:])
+	case "migrate":
+		app.runMigrate(args[1:])
+	case "help", "-h", "-help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		os.Exit(2)
+	}
+}
+
+// app connects to the database the first time a command needs it.
+type app struct {
+	cfg      *config.Config
+	database *gorm.DB
+	opts     options
+}
+
+func (a *app) db() *gorm.DB {
+	if a.database == nil {
+		database, err := db.Connect(a.cfg.Database)
+		if err != nil {
+			fail("failed to connect to the database", err)
+		}
+		a.database = database
+	}
+	return a.database
+}
+
+// store returns the store the server runs on, see cli.NewStore.
+func (a *app) store() store.Store {
+	return cli.NewStore(a.cfg.Database, a.db())
+}
+
+// resolver returns a resolver configured like the server's, so transfers made here obey
+// the same limits and rules as those made through the API.
+func (a *app) resolver() *graph.Resolver {
+	resolver, err := cli.NewResolver(a.cfg, a.store())
+	if err != nil {
+		fail("failed to configure the resolver", err)
+	}
+	return resolver
+}
+
+// options are the flags every command accepts after its name.
+type options struct {
+	output string
+	yes    bool
+}
+
+// parse reads the options of command from args and returns the remaining arguments. It
+// exits with the usage of the command unless there are between minArgs and maxArgs of
+// them; a negative maxArgs means no limit.
+func (a *app) parse(command string, synopsis string, args []string, minArgs int, maxArgs int) []string {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&a.opts.output, "o", "table", "output format: table or json")
+	fs.BoolVar(&a.opts.yes, "y", false, "do not ask for confirmation")
+	err := fs.Parse(args)
+	if err == nil && a.opts.output != "table" && a.opts.output != "json" {
+		err = fmt.Errorf("unknown output format %q", a.opts.output)
+	}
+	if err == nil && (fs.NArg() < minArgs || (maxArgs >= 0 && fs.NArg() > maxArgs)) {
+		err = errors.New("wrong number of arguments")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nusage: ttactl %s [-o table|json] [-y] %s\n", err, command, synopsis)
+		os.Exit(2)
+	}
+	return fs.Args()
+}
+
+// confirm asks the operator to confirm action on the terminal, unless -y was given. Any
+// answer but yes aborts.
+func (a *app) confirm(action string) {
+	if a.opts.yes {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s? [y/N] ", action)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+	default:
+		fail(action, errAborted)
+	}
+}
+
+// print writes value as indented JSON, or as the table writeTable writes in table mode.
+func (a *app) print(value any, writeTable func(w io.Writer)) {
+	if a.opts.output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(value); err != nil {
+			fail("failed to write the output", err)
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	writeTable(w)
+	w.Flush()
+}
+
+// fail reports err and exits.
+func fail(msg string, err error) {
+	fmt.Fprintf(os.Stderr, "ttactl: %s: %v\n", msg, err)
+	os.Exit(1)
+}
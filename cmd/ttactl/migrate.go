@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"token-transfer-api/cli"
+	"token-transfer-api/db/migrations"
+)
+
+// migrationView is a migration as ttactl prints it. AppliedAt is only set by status.
+type migrationView struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// runMigrate implements the "migrate up", "migrate down [STEPS|all]" and "migrate status"
+// commands. Reverting migrations can drop data, so down asks for confirmation.
+func (a *app) runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ttactl migrate up | down [STEPS|all] | status")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		a.parse("migrate up", "", args[1:], 0, 0)
+		applied, err := a.migrator().Up(ctx)
+		if err != nil {
+			fail("failed to apply migrations", err)
+		}
+		a.printMigrations("applied", applied)
+	case "down":
+		rest := a.parse("migrate down", "[STEPS|all]", args[1:], 0, 1)
+		steps := 1
+		if len(rest) == 1 {
+			var err error
+			if steps, err = cli.ParseSteps(rest[0]); err != nil {
+				fail("invalid number of steps", err)
+			}
+		}
+		switch steps {
+		case -1:
+			a.confirm("Revert every migration")
+		case 1:
+			a.confirm("Revert the last migration")
+		default:
+			a.confirm(fmt.Sprintf("Revert %d migrations", steps))
+		}
+		reverted, err := a.migrator().Down(ctx, steps)
+		if err != nil {
+			fail("failed to revert migrations", err)
+		}
+		a.printMigrations("reverted", reverted)
+	case "status":
+		a.parse("migrate status", "", args[1:], 0, 0)
+		statuses, err := a.migrator().Status(ctx)
+		if err != nil {
+			fail("failed to read migration status", err)
+		}
+		views := make([]*migrationView, len(statuses))
+		for i, status := range statuses {
+			views[i] = &migrationView{Version: status.Version, Name: status.Name, AppliedAt: status.AppliedAt}
+		}
+		a.print(views, func(w io.Writer) { cli.WriteMigrationStatus(w, statuses) })
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n", args[0])
+		os.Exit(2)
+	}
+}
+
+func (a *app) migrator() *migrations.Migrator {
+	migrator, err := migrations.New(a.db())
+	if err != nil {
+		fail("failed to load migrations", err)
+	}
+	return migrator
+}
+
+// printMigrations prints the migrations that were applied or reverted.
+func (a *app) printMigrations(verb string, done []migrations.Migration) {
+	views := make([]*migrationView, len(done))
+	for i, migration := range done {
+		views[i] = &migrationView{Version: migration.Version, Name: migration.Name}
+	}
+	a.print(views, func(w io.Writer) { cli.WriteMigrations(w, verb, done) })
+}
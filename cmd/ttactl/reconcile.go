@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"token-transfer-api/cli"
+	"token-transfer-api/reconcile"
+)
+
+// discrepancyView is a reconciliation discrepancy as ttactl prints it.
+type discrepancyView struct {
+	Kind     string `json:"kind"`
+	Address  string `json:"address,omitempty"`
+	Expected int    `json:"expected"`
+	Actual   int    `json:"actual"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// reportView is a reconciliation report as ttactl prints it.
+type reportView struct {
+	ID            int64              `json:"id"`
+	Wallets       int                `json:"wallets"`
+	Supply        int                `json:"supply"`
+	Minted        int                `json:"minted"`
+	Burned        int                `json:"burned"`
+	OK            bool               `json:"ok"`
+	Discrepancies []*discrepancyView `json:"discrepancies"`
+}
+
+// runReconcile implements the "reconcile" command. Like the server's, it exits with
+// status 1 if the ledger does not reconcile.
+func (a *app) runReconcile(args []string) {
+	a.parse("reconcile", "", args, 0, 0)
+	report, err := reconcile.Run(context.Background(), a.store())
+	if err != nil {
+		fail("failed to reconcile the ledger", err)
+	}
+
+	view := &reportView{
+		ID:            report.ID,
+		Wallets:       report.Wallets,
+		Supply:        report.Supply,
+		Minted:        report.Minted,
+		Burned:        report.Burned,
+		OK:            report.OK,
+		Discrepancies: make([]*discrepancyView, len(report.Discrepancies)),
+	}
+	for i, discrepancy := range report.Discrepancies {
+		view.Discrepancies[i] = &discrepancyView{
+			Kind:     discrepancy.Kind,
+			Address:  discrepancy.Address,
+			Expected: discrepancy.Expected,
+			Actual:   discrepancy.Actual,
+			Detail:   discrepancy.Detail,
+		}
+	}
+	a.print(view, func(w io.Writer) { cli.WriteReconciliation(w, report) })
+	if !report.OK {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// runTransfer implements the "transfer" command. It goes through the resolver, so the
+// transfer is checked, recorded and audited exactly like one made through the API.
+func (a *app) runTransfer(args []string) {
+	rest := a.parse("transfer", "FROM TO AMOUNT", args, 3, 3)
+	from, to, amount := rest[0], rest[1], parseAmount(rest[2])
+
+	a.confirm(fmt.Sprintf("Transfer %d tokens from %s to %s", amount, from, to))
+	ctx := context.Background()
+	wallet, err := a.resolver().Transfer(ctx, from, to, amount)
+	if err != nil {
+		fail("failed to transfer", err)
+	}
+	view := viewOf(wallet)
+	a.print(view, func(w io.Writer) {
+		fmt.Fprintf(w, "transferred %d tokens from %s to %s; %s now holds %d\n", amount, from, to, view.Address, view.Balance)
+	})
+}
+
+// runMint implements the "mint" command, which increases the supply.
+func (a *app) runMint(args []string) {
+	rest := a.parse("mint", "ADDRESS AMOUNT", args, 2, 2)
+	address, amount := rest[0], parseAmount(rest[1])
+
+	a.confirm(fmt.Sprintf("Mint %d new tokens to %s", amount, address))
+	wallet, err := a.resolver().Mint(context.Background(), address, amount)
+	if err != nil {
+		fail("failed to mint", err)
+	}
+	view := viewOf(wallet)
+	a.print(view, func(w io.Writer) {
+		fmt.Fprintf(w, "minted %d tokens to %s; it now holds %d\n", amount, view.Address, view.Balance)
+	})
+}
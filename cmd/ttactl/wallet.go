@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"token-transfer-api/models"
+)
+
+// walletView is a wallet as ttactl prints it. Balances include the shards of hot wallets.
+type walletView struct {
+	Address       string      `json:"address"`
+	Balance       int         `json:"balance"`
+	LockedBalance *int        `json:"lockedBalance,omitempty"`
+	Verified      bool        `json:"verified"`
+	Frozen        bool        `json:"frozen"`
+	Limits        *limitsView `json:"limits,omitempty"`
+}
+
+type limitsView struct {
+	MaxSingleTransfer     int `json:"maxSingleTransfer"`
+	DailyOutbound         int `json:"dailyOutbound"`
+	MonthlyOutbound       int `json:"monthlyOutbound"`
+	MaxTransfersPerWindow int `json:"maxTransfersPerWindow"`
+	WindowMinutes         int `json:"windowMinutes"`
+}
+
+func viewOf(wallet *models.Wallet) *walletView {
+	return &walletView{Address: wallet.Address, Balance: wallet.Balance, Verified: wallet.Verified, Frozen: wallet.Frozen}
+}
+
+// runWallet implements the "wallet" commands.
+func (a *app) runWallet(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ttactl wallet list | show ADDRESS | create ADDRESS [BALANCE] | freeze ADDRESS | unfreeze ADDRESS")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+		a.parse("wallet list", "", args[1:], 0, 0)
+		a.listWallets(ctx)
+	case "show":
+		rest := a.parse("wallet show", "ADDRESS", args[1:], 1, 1)
+		a.showWallet(ctx, rest[0])
+	case "create":
+		rest := a.parse("wallet create", "ADDRESS [BALANCE]", args[1:], 1, 2)
+		balance := 0
+		if len(rest) == 2 {
+			balance = parseAmount(rest[1])
+		}
+		a.createWallet(ctx, rest[0], balance)
+	case "freeze", "unfreeze":
+		rest := a.parse("wallet "+args[0], "ADDRESS", args[1:], 1, 1)
+		frozen := args[0] == "freeze"
+		if frozen {
+			a.confirm(fmt.Sprintf("Freeze wallet %s", rest[0]))
+		} else {
+			a.confirm(fmt.Sprintf("Unfreeze wallet %s", rest[0]))
+		}
+		wallet, err := a.store().SetWalletFrozen(ctx, rest[0], frozen)
+		if err != nil {
+			fail("failed to "+args[0]+" the wallet", err)
+		}
+		a.showWallet(ctx, wallet.Address)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown wallet command %q\n", args[0])
+		os.Exit(2)
+	}
+}
+
+func (a *app) listWallets(ctx context.Context) {
+	views := a.walletViews(ctx)
+	a.print(views, func(w io.Writer) {
+		fmt.Fprintln(w, "ADDRESS\tBALANCE\tVERIFIED\tFROZEN")
+		for _, view := range views {
+			fmt.Fprintf(w, "%s\t%d\t%t\t%t\n", view.Address, view.Balance, view.Verified, view.Frozen)
+		}
+	})
+}
+
+// walletViews returns every wallet ordered by address, adding the shards of hot wallets
+// to their balances.
+func (a *app) walletViews(ctx context.Context) []*walletView {
+	s := a.store()
+	wallets, err := s.Wallets(ctx)
+	if err != nil {
+		fail("failed to list wallets", err)
+	}
+	sharded, err := s.ShardedWallets(ctx)
+	if err != nil {
+		fail("failed to list wallets", err)
+	}
+
+	views := make([]*walletView, len(wallets))
+	for i, wallet := range wallets {
+		if slices.Contains(sharded, wallet.Address) {
+			shards, err := s.WalletShards(ctx, wallet.Address)
+			if err != nil {
+				fail("failed to list wallets", err)
+			}
+			for _, shard := range shards {
+				wallet.Balance += shard.Balance
+			}
+		}
+		views[i] = viewOf(wallet)
+	}
+	return views
+}
+
+func (a *app) showWallet(ctx context.Context, address string) {
+	resolver := a.resolver()
+	wallet, err := resolver.Query().Wallet(ctx, address, nil)
+	if err != nil {
+		fail("failed to read the wallet", err)
+	}
+	locked, err := resolver.Wallet().LockedBalance(ctx, wallet)
+	if err != nil {
+		fail("failed to read the locked balance", err)
+	}
+	limits, err := resolver.Store.WalletLimits(ctx, address)
+	if err != nil {
+		fail("failed to read the wallet limits", err)
+	}
+
+	view := viewOf(wallet)
+	view.LockedBalance = &locked
+	if limits != nil {
+		view.Limits = &limitsView{
+			MaxSingleTransfer:     limits.MaxSingleTransfer,
+			DailyOutbound:         limits.DailyOutbound,
+			MonthlyOutbound:       limits.MonthlyOutbound,
+			MaxTransfersPerWindow: limits.MaxTransfersPerWindow,
+			WindowMinutes:         limits.WindowMinutes,
+		}
+	}
+	a.print(view, func(w io.Writer) {
+		fmt.Fprintf(w, "address\t%s\n", view.Address)
+		fmt.Fprintf(w, "balance\t%d\n", view.Balance)
+		fmt.Fprintf(w, "locked balance\t%d\n", locked)
+		fmt.Fprintf(w, "verified\t%t\n", view.Verified)
+		fmt.Fprintf(w, "frozen\t%t\n", view.Frozen)
+		if view.Limits != nil {
+			fmt.Fprintf(w, "max single transfer\t%d\n", view.Limits.MaxSingleTransfer)
+			fmt.Fprintf(w, "daily outbound\t%d\n", view.Limits.DailyOutbound)
+			fmt.Fprintf(w, "monthly outbound\t%d\n", view.Limits.MonthlyOutbound)
+			fmt.Fprintf(w, "max transfers per window\t%d\n", view.Limits.MaxTransfersPerWindow)
+			fmt.Fprintf(w, "window minutes\t%d\n", view.Limits.WindowMinutes)
+		}
+	})
+}
+
+func (a *app) createWallet(ctx context.Context, address string, balance int) {
+	if err := models.ValidateWallet(address, balance); err != nil {
+		fail("invalid wallet", err)
+	}
+	s := a.store()
+	_, err := s.Wallet(ctx, address)
+	if err == nil {
+		fail("failed to create the wallet", fmt.Errorf("wallet %s already exists", address))
+	}
+	if !errors.Is(err, models.ErrWalletNotFound) {
+		fail("failed to create the wallet", err)
+	}
+	if balance > 0 {
+		a.confirm(fmt.Sprintf("Create wallet %s holding %d new tokens", address, balance))
+	}
+	if err := s.CreateWallet(ctx, address, balance); err != nil {
+		fail("failed to create the wallet", err)
+	}
+	a.showWallet(ctx, address)
+}
+
+// parseAmount parses a token amount argument, exiting if it is not a non-negative integer.
+func parseAmount(arg string) int {
+	amount, err := strconv.Atoi(arg)
+	if err != nil || amount < 0 {
+		fmt.Fprintf(os.Stderr, "invalid amount %q\n", arg)
+		os.Exit(2)
+	}
+	return amount
+}
@@ -0,0 +1,498 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config is the complete configuration of the server.
+type Config struct {
+	Server   ServerConfig
+	Database DatabaseConfig
+	Features FeaturesConfig
+	Engine   EngineConfig
+	Blocks   BlocksConfig
+	Tracing  TracingConfig
+	Log      LogConfig
+}
+
+type ServerConfig struct {
+	Addr        string
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile verifies the certificates clients present. The common name of a
+	// verified certificate is the caller's principal, see logging.Principal.
+	TLSClientCAFile string
+	// AdminPrincipals are the principals allowed to run admin mutations, such as
+	// deciding transfer reviews. Without any, admin mutations are refused.
+	AdminPrincipals []string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+type DatabaseConfig struct {
+	// Driver is "postgres" or "sqlite".
+	Driver          string
+	Host            string
+	Port            int
+	User            string
+	Password        string
+	Name            string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// SlowQueryThreshold is the duration above which statements are logged as slow.
+	SlowQueryThreshold time.Duration
+	// Isolation is the transaction isolation level: "read-committed" or "serializable".
+	Isolation string
+	// RetryAttempts is how many times a transaction that failed with a serialization
+	// failure or deadlock runs at most; 1 disables retries.
+	RetryAttempts  int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// RetryBudget bounds the time spent on one transaction across its attempts.
+	RetryBudget time.Duration
+	// SQLitePath is the database file used by the sqlite driver.
+	SQLitePath string
+	// SQLiteBusyTimeout is how long a SQLite transaction waits for the write lock.
+	SQLiteBusyTimeout time.Duration
+}
+
+type FeaturesConfig struct {
+	// Playground serves the GraphQL playground on "/".
+	Playground bool
+	// RulesFile is the fraud and compliance rules file; empty disables the rules engine.
+	RulesFile string
+	// UnverifiedLimits applies models.DefaultUnverifiedLimits to unverified wallets.
+	UnverifiedLimits bool
+	// ProposalTTL is how long multisig proposals collect approvals.
+	ProposalTTL time.Duration
+	// HotWallets are the addresses whose balance is split across HotWalletShards shards.
+	// Hot wallets do not track spend, so they cannot have limits: they must be verified
+	// when UnverifiedLimits is set, and have no limits of their own. The server refuses to
+	// shard others, and fails their transfers if limits come to apply later.
+	HotWallets      []string
+	HotWalletShards int
+	// HotWalletRebalanceInterval is how often the shards of hot wallets are evened out.
+	HotWalletRebalanceInterval time.Duration
+	// SnapshotInterval is how often wallets are snapshotted from their event stream.
+	SnapshotInterval time.Duration
+	// AuditKey is the base64 seed of the ed25519 key that signs audit checkpoints; empty
+	// disables checkpoints. AuditPublicKey checks their signatures and defaults to the
+	// public half of AuditKey.
+	AuditKey       string
+	AuditPublicKey string
+	// AuditCheckpointInterval is how often the audit chains are checkpointed.
+	AuditCheckpointInterval time.Duration
+	// BalanceRootInterval is how often the Merkle root of all balances is committed.
+	BalanceRootInterval time.Duration
+	// ReconciliationInterval is how often the ledger is reconciled.
+	ReconciliationInterval time.Duration
+}
+
+// EngineConfig configures the group-commit transfer engine. See package engine.
+type EngineConfig struct {
+	// Enabled routes the transfer mutation through the engine instead of running each
+	// transfer in a transaction of its own.
+	Enabled bool
+	// MaxBatch is the most transfers committed in one transaction.
+	MaxBatch int
+	// MaxDelay is how long the first transfer of a batch waits for others to join it.
+	MaxDelay time.Duration
+	// QueueSize is how many transfers can wait for the engine before callers block.
+	QueueSize int
+}
+
+// BlocksConfig configures the sealing of committed transfers into blocks. See package
+// blocks.
+type BlocksConfig struct {
+	// Enabled seals blocks in the background.
+	Enabled bool
+	// Interval is how often a block is sealed from the transfers committed since the
+	// previous one.
+	Interval time.Duration
+	// MaxTransfers is how many committed transfers seal a block before Interval is up.
+	// A block holds every transfer committed since the previous one, so it can hold more
+	// when transfers commit faster than a block is sealed.
+	MaxTransfers int
+}
+
+type LogConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string
+}
+
+type TracingConfig struct {
+	// Exporter is "none", "stdout" or "otlp". The OTLP exporter is configured through the
+	// standard OTEL_EXPORTER_OTLP_* environment variables.
+	Exporter    string
+	ServiceName string
+	// SampleRatio is the fraction of new traces that are sampled; incoming sampled
+	// traces are always continued.
+	SampleRatio float64
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:             "postgres",
+			Host:               "localhost",
+			Port:               5432,
+			SSLMode:            "prefer",
+			MaxOpenConns:       25,
+			MaxIdleConns:       10,
+			ConnMaxLifetime:    30 * time.Minute,
+			SlowQueryThreshold: 200 * time.Millisecond,
+			Isolation:          "read-committed",
+			RetryAttempts:      5,
+			RetryBaseDelay:     10 * time.Millisecond,
+			RetryMaxDelay:      500 * time.Millisecond,
+			RetryBudget:        5 * time.Second,
+			SQLitePath:         "token-transfer.db",
+			SQLiteBusyTimeout:  5 * time.Second,
+		},
+		Features: FeaturesConfig{
+			Playground:                 true,
+			UnverifiedLimits:           true,
+			ProposalTTL:                24 * time.Hour,
+			HotWalletShards:            16,
+			HotWalletRebalanceInterval: time.Minute,
+			SnapshotInterval:           time.Hour,
+			AuditCheckpointInterval:    time.Hour,
+			BalanceRootInterval:        time.Hour,
+			ReconciliationInterval:     24 * time.Hour,
+		},
+		Engine: EngineConfig{
+			MaxBatch:  100,
+			MaxDelay:  2 * time.Millisecond,
+			QueueSize: 1000,
+		},
+		Blocks: BlocksConfig{
+			Interval:     10 * time.Second,
+			MaxTransfers: 1000,
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "token-transfer-api",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// setting binds one configuration value to its environment variable and flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(value string) error
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"SERVER_ADDR", "addr", "address the HTTP server listens on", stringValue(&c.Server.Addr)},
+		{"SERVER_TLS_CERT_FILE", "tls-cert", "TLS certificate file; enables HTTPS together with the key", stringValue(&c.Server.TLSCertFile)},
+		{"SERVER_TLS_KEY_FILE", "tls-key", "TLS private key file", stringValue(&c.Server.TLSKeyFile)},
+		{"SERVER_TLS_CLIENT_CA_FILE", "tls-client-ca", "CA certificates that verify client certificates", stringValue(&c.Server.TLSClientCAFile)},
+		{"ADMIN_PRINCIPALS", "admin-principals", "comma-separated client certificate common names allowed to run admin mutations", listValue(&c.Server.AdminPrincipals)},
+		{"SERVER_READ_TIMEOUT", "read-timeout", "maximum duration for reading a request", durationValue(&c.Server.ReadTimeout)},
+		{"SERVER_WRITE_TIMEOUT", "write-timeout", "maximum duration for writing a response", durationValue(&c.Server.WriteTimeout)},
+		{"SERVER_IDLE_TIMEOUT", "idle-timeout", "how long idle keep-alive connections stay open", durationValue(&c.Server.IdleTimeout)},
+		{"SERVER_SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long in-flight requests may run after a shutdown signal", durationValue(&c.Server.ShutdownTimeout)},
+		{"DATABASE_DRIVER", "db-driver", "database driver: postgres or sqlite", stringValue(&c.Database.Driver)},
+		{"POSTGRES_HOST", "db-host", "database host", stringValue(&c.Database.Host)},
+		{"POSTGRES_PORT", "db-port", "database port", intValue(&c.Database.Port)},
+		{"POSTGRES_USER", "db-user", "database user", stringValue(&c.Database.User)},
+		{"POSTGRES_PASSWORD", "db-password", "database password", stringValue(&c.Database.Password)},
+		{"POSTGRES_DB", "db-name", "database name", stringValue(&c.Database.Name)},
+		{"POSTGRES_SSLMODE", "db-sslmode", "database SSL mode", stringValue(&c.Database.SSLMode)},
+		{"POSTGRES_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections (0 is unlimited)", intValue(&c.Database.MaxOpenConns)},
+		{"POSTGRES_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", intValue(&c.Database.MaxIdleConns)},
+		{"POSTGRES_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum lifetime of a database connection", durationValue(&c.Database.ConnMaxLifetime)},
+		{"POSTGRES_SLOW_QUERY_THRESHOLD", "db-slow-query-threshold", "log statements slower than this as slow queries", durationValue(&c.Database.SlowQueryThreshold)},
+		{"DATABASE_ISOLATION", "db-isolation", "transaction isolation level: read-committed or serializable", stringValue(&c.Database.Isolation)},
+		{"DATABASE_RETRY_ATTEMPTS", "db-retry-attempts", "maximum attempts of a transaction that hits a serialization failure or deadlock (1 disables retries)", intValue(&c.Database.RetryAttempts)},
+		{"DATABASE_RETRY_BASE_DELAY", "db-retry-base-delay", "backoff before the first transaction retry, doubled for every further one", durationValue(&c.Database.RetryBaseDelay)},
+		{"DATABASE_RETRY_MAX_DELAY", "db-retry-max-delay", "maximum backoff between transaction retries", durationValue(&c.Database.RetryMaxDelay)},
+		{"DATABASE_RETRY_BUDGET", "db-retry-budget", "maximum time spent on one transaction across its retries (0 is unlimited)", durationValue(&c.Database.RetryBudget)},
+		{"SQLITE_PATH", "sqlite-path", "SQLite database file", stringValue(&c.Database.SQLitePath)},
+		{"SQLITE_BUSY_TIMEOUT", "sqlite-busy-timeout", "how long SQLite transactions wait for the write lock", durationValue(&c.Database.SQLiteBusyTimeout)},
+		{"PLAYGROUND_ENABLED", "playground", "serve the GraphQL playground", boolValue(&c.Features.Playground)},
+		{"RULES_FILE", "rules-file", "fraud and compliance rules file", stringValue(&c.Features.RulesFile)},
+		{"UNVERIFIED_LIMITS_ENABLED", "unverified-limits", "apply default limits to unverified wallets", boolValue(&c.Features.UnverifiedLimits)},
+		{"PROPOSAL_TTL", "proposal-ttl", "how long multisig proposals collect approvals", durationValue(&c.Features.ProposalTTL)},
+		{"HOT_WALLETS", "hot-wallets", "comma-separated addresses whose balance is sharded", listValue(&c.Features.HotWallets)},
+		{"HOT_WALLET_SHARDS", "hot-wallet-shards", "number of shards of each hot wallet", intValue(&c.Features.HotWalletShards)},
+		{"HOT_WALLET_REBALANCE_INTERVAL", "hot-wallet-rebalance-interval", "how often the shards of hot wallets are evened out", durationValue(&c.Features.HotWalletRebalanceInterval)},
+		{"WALLET_SNAPSHOT_INTERVAL", "wallet-snapshot-interval", "how often wallets are snapshotted from their event stream", durationValue(&c.Features.SnapshotInterval)},
+		{"AUDIT_KEY", "audit-key", "base64 ed25519 seed that signs audit checkpoints", stringValue(&c.Features.AuditKey)},
+		{"AUDIT_PUBLIC_KEY", "audit-public-key", "base64 ed25519 public key that checks audit checkpoints", stringValue(&c.Features.AuditPublicKey)},
+		{"AUDIT_CHECKPOINT_INTERVAL", "audit-checkpoint-interval", "how often the audit log is checkpointed", durationValue(&c.Features.AuditCheckpointInterval)},
+		{"BALANCE_ROOT_INTERVAL", "balance-root-interval", "how often the Merkle root of all balances is committed", durationValue(&c.Features.BalanceRootInterval)},
+		{"RECONCILIATION_INTERVAL", "reconciliation-interval", "how often the ledger is reconciled", durationValue(&c.Features.ReconciliationInterval)},
+		{"TRANSFER_ENGINE_ENABLED", "transfer-engine", "execute transfers in batches through the group-commit engine", boolValue(&c.Engine.Enabled)},
+		{"TRANSFER_ENGINE_MAX_BATCH", "transfer-engine-max-batch", "maximum transfers committed in one transaction by the engine", intValue(&c.Engine.MaxBatch)},
+		{"TRANSFER_ENGINE_MAX_DELAY", "transfer-engine-max-delay", "how long the engine waits for a batch to fill", durationValue(&c.Engine.MaxDelay)},
+		{"TRANSFER_ENGINE_QUEUE_SIZE", "transfer-engine-queue-size", "transfers that can wait for the engine before callers block", intValue(&c.Engine.QueueSize)},
+		{"BLOCKS_ENABLED", "blocks", "seal committed transfers into blocks", boolValue(&c.Blocks.Enabled)},
+		{"BLOCK_INTERVAL", "block-interval", "how often a block is sealed", durationValue(&c.Blocks.Interval)},
+		{"BLOCK_MAX_TRANSFERS", "block-max-transfers", "committed transfers that seal a block early", intValue(&c.Blocks.MaxTransfers)},
+		{"LOG_LEVEL", "log-level", "minimum log level: debug, info, warn or error", stringValue(&c.Log.Level)},
+		{"TRACING_EXPORTER", "tracing-exporter", "trace exporter: none, stdout or otlp", stringValue(&c.Tracing.Exporter)},
+		{"TRACING_SERVICE_NAME", "tracing-service-name", "service name reported in traces", stringValue(&c.Tracing.ServiceName)},
+		{"TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "fraction of new traces to sample", floatValue(&c.Tracing.SampleRatio)},
+	}
+}
+
+func stringValue(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+func intValue(p *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func boolValue(p *bool) func(string) error {
+	return func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*p = b
+		return nil
+	}
+}
+
+func floatValue(p *float64) func(string) error {
+	return func(value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*p = f
+		return nil
+	}
+}
+
+func listValue(p *[]string) func(string) error {
+	return func(value string) error {
+		*p = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+		return nil
+	}
+}
+
+func durationValue(p *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*p = d
+		return nil
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence, the defaults,
+// a configuration file in .env format, environment variables and command line flags.
+// The file is taken from the -config flag or CONFIG_FILE; without either, ".env" is read
+// if it exists. Load returns the arguments remaining after the flags.
+func Load(args []string) (*Config, []string, error) {
+	c := Default()
+	settings := c.settings()
+
+	fs := flag.NewFlagSet("token-transfer-api", flag.ContinueOnError)
+	configFile := fs.String("config", "", "configuration file in .env format")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flag] = fs.String(s.flag, "", s.usage+" ($"+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	path, required := *configFile, true
+	if path == "" {
+		path, required = os.Getenv("CONFIG_FILE"), true
+	}
+	if path == "" {
+		path, required = ".env", false
+	}
+	fileValues, err := godotenv.Read(path)
+	if err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := fileValues[s.env]; ok {
+			if err := s.set(value); err != nil {
+				return nil, nil, fmt.Errorf("%s in %s: %w", s.env, path, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(value); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.set(*flagValues[s.flag]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// decodedLength returns the length of a base64 value, or -1 if it is not valid base64.
+func decodedLength(value string) int {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return -1
+	}
+	return len(decoded)
+}
+
+// Validate reports every invalid value of the configuration.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(c.Server.Addr != "", "server address is required")
+	check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""), "TLS certificate and key must be set together")
+	check(c.Server.TLSClientCAFile == "" || c.Server.TLSCertFile != "", "TLS client CA requires a TLS certificate and key")
+	check(c.Server.ReadTimeout > 0, "read timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "write timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "idle timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "shutdown timeout must be positive")
+
+	switch c.Database.Driver {
+	case "postgres":
+		check(c.Database.Host != "", "database host is required")
+		check(c.Database.Port > 0 && c.Database.Port < 65536, "database port must be between 1 and 65535")
+		check(c.Database.User != "", "database user is required")
+		check(c.Database.Name != "", "database name is required")
+		check(sslModes[c.Database.SSLMode], fmt.Sprintf("unknown database SSL mode %q", c.Database.SSLMode))
+	case "sqlite":
+		check(c.Database.SQLitePath != "", "SQLite path is required")
+		check(c.Database.SQLiteBusyTimeout >= 0, "SQLite busy timeout cannot be negative")
+	default:
+		check(false, fmt.Sprintf("unknown database driver %q", c.Database.Driver))
+	}
+	check(c.Database.MaxOpenConns >= 0, "max open connections cannot be negative")
+	check(c.Database.MaxIdleConns >= 0, "max idle connections cannot be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"max idle connections cannot exceed max open connections")
+	check(c.Database.ConnMaxLifetime >= 0, "connection lifetime cannot be negative")
+	check(c.Database.SlowQueryThreshold >= 0, "slow query threshold cannot be negative")
+	check(c.Database.Isolation == "read-committed" || c.Database.Isolation == "serializable",
+		fmt.Sprintf("unknown isolation level %q", c.Database.Isolation))
+	check(c.Database.RetryAttempts >= 1, "retry attempts must be at least 1")
+	check(c.Database.RetryBaseDelay >= 0 && c.Database.RetryMaxDelay >= c.Database.RetryBaseDelay,
+		"retry delays cannot be negative and the maximum cannot be below the base delay")
+	check(c.Database.RetryBudget >= 0, "retry budget cannot be negative")
+
+	check(c.Features.ProposalTTL > 0, "proposal TTL must be positive")
+	check(c.Features.HotWalletShards > 0, "hot wallet shards must be positive")
+	check(c.Features.HotWalletRebalanceInterval > 0, "hot wallet rebalance interval must be positive")
+	check(c.Features.SnapshotInterval > 0, "wallet snapshot interval must be positive")
+	check(c.Features.AuditKey == "" || decodedLength(c.Features.AuditKey) == 32, "audit key must be a base64 32-byte seed")
+	check(c.Features.AuditPublicKey == "" || decodedLength(c.Features.AuditPublicKey) == 32, "audit public key must be a base64 32-byte key")
+	check(c.Features.AuditCheckpointInterval > 0, "audit checkpoint interval must be positive")
+	check(c.Features.BalanceRootInterval > 0, "balance root interval must be positive")
+	check(c.Features.ReconciliationInterval > 0, "reconciliation interval must be positive")
+
+	check(c.Engine.MaxBatch > 0, "transfer engine batch size must be positive")
+	check(c.Engine.MaxDelay >= 0, "transfer engine delay cannot be negative")
+	check(c.Engine.QueueSize >= 0, "transfer engine queue size cannot be negative")
+	check(c.Blocks.Interval > 0, "block interval must be positive")
+	check(c.Blocks.MaxTransfers > 0, "block max transfers must be positive")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, fmt.Sprintf("unknown log level %q", c.Log.Level))
+
+	check(c.Tracing.Exporter == "none" || c.Tracing.Exporter == "stdout" || c.Tracing.Exporter == "otlp",
+		fmt.Sprintf("unknown tracing exporter %q", c.Tracing.Exporter))
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing sample ratio must be between 0 and 1")
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// DSN returns the keyword/value connection string for the database.
+func (d DatabaseConfig) DSN() string {
+	quote := func(value string) string {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `'`, `\'`)
+		return "'" + value + "'"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(d.Host), d.Port, quote(d.User), quote(d.Password), quote(d.Name), quote(d.SSLMode))
+}
@@ -1,42 +1,63 @@
 package db
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"log/slog"
 
-	"token-transfer-api/models"
+	"token-transfer-api/config"
+	"token-transfer-api/db/migrations"
+	"token-transfer-api/logging"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func Connect() (*gorm.DB, error) {
-	err := godotenv.Load()
-	if err != nil {
-		return nil, fmt.Errorf("error loading .env file: %w", err)
+// Connect opens the database described by cfg and configures its connection pool.
+func Connect(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector := postgres.Open(cfg.DSN())
+	if cfg.Driver == "sqlite" {
+		dialector = openSQLite(cfg)
 	}
 
-	user := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	dbname := os.Getenv("POSTGRES_DB")
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
-
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", host, user, password, dbname, port)
-
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	var err error
+	DB, err = gorm.Open(dialector, &gorm.Config{
+		Logger: logging.GORM{SlowThreshold: cfg.SlowQueryThreshold},
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to the database: %w", err)
 	}
 
-	err = DB.AutoMigrate(&models.Wallet{})
+	sqlDB, err := DB.DB()
 	if err != nil {
-		return nil, fmt.Errorf("error auto-migrating Wallet model: %w", err)
+		return nil, fmt.Errorf("error configuring the connection pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	if cfg.Driver == "sqlite" {
+		slog.Info("connected to the database", "driver", cfg.Driver, "path", cfg.SQLitePath)
+	} else {
+		slog.Info("connected to the database", "driver", cfg.Driver, "host", cfg.Host, "database", cfg.Name)
 	}
-
-	fmt.Println("Successfully connected to the database and auto-migrated models.")
 	return DB, nil
 }
+
+// Migrate applies all pending schema migrations to database.
+func Migrate(database *gorm.DB) error {
+	migrator, err := migrations.New(database)
+	if err != nil {
+		return fmt.Errorf("error loading migrations: %w", err)
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, migration := range applied {
+		slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
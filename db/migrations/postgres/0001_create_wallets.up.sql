@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS wallets (
+    id uuid PRIMARY KEY,
+    address text NOT NULL,
+    balance bigint NOT NULL,
+    version bigint DEFAULT 1,
+    CONSTRAINT uni_wallets_address UNIQUE (address)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS spend_counters;
+DROP TABLE IF EXISTS wallet_limits;
+ALTER TABLE wallets DROP COLUMN IF EXISTS verified;
@@ -0,0 +1,21 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS verified boolean NOT NULL DEFAULT false;
+-- Only wallets created from now on start unverified: the limits of unverified wallets
+-- must not suddenly apply to those of an existing deployment.
+UPDATE wallets SET verified = true;
+
+CREATE TABLE IF NOT EXISTS wallet_limits (
+    address text PRIMARY KEY,
+    max_single_transfer bigint NOT NULL DEFAULT 0,
+    daily_outbound bigint NOT NULL DEFAULT 0,
+    monthly_outbound bigint NOT NULL DEFAULT 0,
+    max_transfers_per_window bigint NOT NULL DEFAULT 0,
+    window_minutes bigint NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS spend_counters (
+    address text NOT NULL,
+    bucket_start timestamptz NOT NULL,
+    amount bigint NOT NULL DEFAULT 0,
+    transfers bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (address, bucket_start)
+);
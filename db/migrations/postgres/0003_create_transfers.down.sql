@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transfer_reviews;
+DROP TABLE IF EXISTS transfers;
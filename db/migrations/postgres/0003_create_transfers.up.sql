@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS transfers (
+    id uuid PRIMARY KEY,
+    from_address text NOT NULL,
+    to_address text NOT NULL,
+    amount bigint NOT NULL,
+    created_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_transfers_from_to ON transfers (from_address, to_address);
+CREATE INDEX IF NOT EXISTS idx_transfers_from_created ON transfers (from_address, created_at);
+
+CREATE TABLE IF NOT EXISTS transfer_reviews (
+    id uuid PRIMARY KEY,
+    from_address text NOT NULL,
+    to_address text NOT NULL,
+    amount bigint NOT NULL,
+    reasons text NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    note text,
+    created_at timestamptz,
+    decided_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_transfer_reviews_status ON transfer_reviews (status);
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS multisig_wallets (
+    address text PRIMARY KEY,
+    threshold bigint NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS multisig_signers (
+    address text NOT NULL,
+    signer text NOT NULL,
+    public_key text NOT NULL,
+    PRIMARY KEY (address, signer)
+);
+
+CREATE TABLE IF NOT EXISTS transfer_proposals (
+    id uuid PRIMARY KEY,
+    from_address text NOT NULL,
+    to_address text NOT NULL,
+    amount bigint NOT NULL,
+    proposer text NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    executed_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_transfer_proposals_from_address ON transfer_proposals (from_address);
+CREATE INDEX IF NOT EXISTS idx_transfer_proposals_status ON transfer_proposals (status);
+
+CREATE TABLE IF NOT EXISTS proposal_approvals (
+    proposal_id uuid NOT NULL,
+    signer text NOT NULL,
+    signature text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (proposal_id, signer)
+);
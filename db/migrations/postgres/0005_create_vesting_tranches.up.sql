@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS vesting_tranches (
+    id uuid PRIMARY KEY,
+    address text NOT NULL,
+    amount bigint NOT NULL,
+    starts_at timestamptz NOT NULL,
+    cliff_at timestamptz NOT NULL,
+    ends_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_vesting_tranches_address ON vesting_tranches (address);
+CREATE INDEX IF NOT EXISTS idx_vesting_tranches_ends_at ON vesting_tranches (ends_at);
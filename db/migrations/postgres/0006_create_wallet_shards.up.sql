@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS wallet_shards (
+    address text NOT NULL,
+    shard bigint NOT NULL,
+    balance bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (address, shard)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS wallet_snapshots;
+DROP TABLE IF EXISTS wallet_events;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS wallet_events (
+    seq bigserial PRIMARY KEY,
+    address text NOT NULL,
+    type text NOT NULL,
+    amount bigint NOT NULL DEFAULT 0,
+    transfer_id uuid,
+    created_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_wallet_events_address_seq ON wallet_events (address, seq);
+
+CREATE TABLE IF NOT EXISTS wallet_snapshots (
+    address text NOT NULL,
+    seq bigint NOT NULL,
+    balance bigint NOT NULL,
+    verified boolean NOT NULL DEFAULT false,
+    created_at timestamptz NOT NULL,
+    PRIMARY KEY (address, seq)
+);
+
+-- Wallets that predate the event stream open with the balance they have now.
+INSERT INTO wallet_events (address, type, amount, created_at)
+SELECT w.address, 'wallet_created',
+       w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.address = w.address), 0),
+       now()
+FROM wallets w
+ORDER BY w.address;
+INSERT INTO wallet_events (address, type, created_at)
+SELECT address, 'verified', now() FROM wallets WHERE verified ORDER BY address;
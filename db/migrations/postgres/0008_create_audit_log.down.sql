@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS audit_checkpoint_entries;
+DROP TABLE IF EXISTS audit_checkpoints;
+DROP TABLE IF EXISTS audit_records;
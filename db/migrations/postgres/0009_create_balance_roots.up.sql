@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS balance_roots (
+    seq bigserial PRIMARY KEY,
+    root text NOT NULL,
+    wallets integer NOT NULL,
+    total bigint NOT NULL,
+    created_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_balance_roots_root ON balance_roots (root);
+
+CREATE TABLE IF NOT EXISTS balance_leaves (
+    root_seq bigint NOT NULL REFERENCES balance_roots (seq),
+    address text NOT NULL,
+    balance bigint NOT NULL,
+    PRIMARY KEY (root_seq, address)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reconciliation_discrepancies;
+DROP TABLE IF EXISTS reconciliation_reports;
+DROP INDEX IF EXISTS idx_transfers_to_created;
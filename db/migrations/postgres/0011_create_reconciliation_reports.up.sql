@@ -0,0 +1,23 @@
+CREATE INDEX IF NOT EXISTS idx_transfers_to_created ON transfers (to_address, created_at);
+
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id bigserial PRIMARY KEY,
+    started_at timestamptz NOT NULL,
+    finished_at timestamptz NOT NULL,
+    wallets integer NOT NULL,
+    supply bigint NOT NULL,
+    minted bigint NOT NULL,
+    burned bigint NOT NULL,
+    ok boolean NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS reconciliation_discrepancies (
+    report_id bigint NOT NULL REFERENCES reconciliation_reports (id),
+    position integer NOT NULL,
+    kind text NOT NULL,
+    address text NOT NULL DEFAULT '',
+    expected bigint NOT NULL,
+    actual bigint NOT NULL,
+    detail text NOT NULL DEFAULT '',
+    PRIMARY KEY (report_id, position)
+);
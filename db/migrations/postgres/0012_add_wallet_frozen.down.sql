@@ -0,0 +1,2 @@
+ALTER TABLE wallet_snapshots DROP COLUMN IF EXISTS frozen;
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen;
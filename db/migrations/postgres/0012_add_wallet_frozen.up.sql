@@ -0,0 +1,2 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS frozen boolean NOT NULL DEFAULT false;
+ALTER TABLE wallet_snapshots ADD COLUMN IF NOT EXISTS frozen boolean NOT NULL DEFAULT false;
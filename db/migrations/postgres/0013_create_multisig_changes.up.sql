@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS multisig_changes (
+    id uuid PRIMARY KEY,
+    address text NOT NULL,
+    signers text NOT NULL,
+    threshold integer NOT NULL,
+    proposer text NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    executed_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_multisig_changes_address ON multisig_changes (address);
+CREATE INDEX IF NOT EXISTS idx_multisig_changes_status ON multisig_changes (status);
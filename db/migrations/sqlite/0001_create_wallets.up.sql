@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS wallets (
+    id text PRIMARY KEY,
+    address text NOT NULL,
+    balance integer NOT NULL,
+    version integer DEFAULT 1,
+    CONSTRAINT uni_wallets_address UNIQUE (address)
+);
@@ -0,0 +1,21 @@
+ALTER TABLE wallets ADD COLUMN verified boolean NOT NULL DEFAULT false;
+-- Only wallets created from now on start unverified: the limits of unverified wallets
+-- must not suddenly apply to those of an existing deployment.
+UPDATE wallets SET verified = true;
+
+CREATE TABLE IF NOT EXISTS wallet_limits (
+    address text PRIMARY KEY,
+    max_single_transfer integer NOT NULL DEFAULT 0,
+    daily_outbound integer NOT NULL DEFAULT 0,
+    monthly_outbound integer NOT NULL DEFAULT 0,
+    max_transfers_per_window integer NOT NULL DEFAULT 0,
+    window_minutes integer NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS spend_counters (
+    address text NOT NULL,
+    bucket_start datetime NOT NULL,
+    amount integer NOT NULL DEFAULT 0,
+    transfers integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (address, bucket_start)
+);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS transfers (
+    id text PRIMARY KEY,
+    from_address text NOT NULL,
+    to_address text NOT NULL,
+    amount integer NOT NULL,
+    created_at datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_transfers_from_to ON transfers (from_address, to_address);
+CREATE INDEX IF NOT EXISTS idx_transfers_from_created ON transfers (from_address, created_at);
+
+CREATE TABLE IF NOT EXISTS transfer_reviews (
+    id text PRIMARY KEY,
+    from_address text NOT NULL,
+    to_address text NOT NULL,
+    amount integer NOT NULL,
+    reasons text NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    note text,
+    created_at datetime,
+    decided_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_transfer_reviews_status ON transfer_reviews (status);
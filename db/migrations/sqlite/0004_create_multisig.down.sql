@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS proposal_approvals;
+DROP TABLE IF EXISTS transfer_proposals;
+DROP TABLE IF EXISTS multisig_signers;
+DROP TABLE IF EXISTS multisig_wallets;
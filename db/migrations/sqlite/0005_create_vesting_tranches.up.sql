@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS vesting_tranches (
+    id text PRIMARY KEY,
+    address text NOT NULL,
+    amount integer NOT NULL,
+    starts_at datetime NOT NULL,
+    cliff_at datetime NOT NULL,
+    ends_at datetime NOT NULL,
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_vesting_tranches_address ON vesting_tranches (address);
+CREATE INDEX IF NOT EXISTS idx_vesting_tranches_ends_at ON vesting_tranches (ends_at);
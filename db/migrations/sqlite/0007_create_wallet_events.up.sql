@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS wallet_events (
+    seq integer PRIMARY KEY AUTOINCREMENT,
+    address text NOT NULL,
+    type text NOT NULL,
+    amount integer NOT NULL DEFAULT 0,
+    transfer_id text,
+    created_at datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_wallet_events_address_seq ON wallet_events (address, seq);
+
+CREATE TABLE IF NOT EXISTS wallet_snapshots (
+    address text NOT NULL,
+    seq integer NOT NULL,
+    balance integer NOT NULL,
+    verified boolean NOT NULL DEFAULT false,
+    created_at datetime NOT NULL,
+    PRIMARY KEY (address, seq)
+);
+
+-- Wallets that predate the event stream open with the balance they have now.
+INSERT INTO wallet_events (address, type, amount, created_at)
+SELECT w.address, 'wallet_created',
+       w.balance + COALESCE((SELECT SUM(s.balance) FROM wallet_shards s WHERE s.address = w.address), 0),
+       CURRENT_TIMESTAMP
+FROM wallets w
+ORDER BY w.address;
+INSERT INTO wallet_events (address, type, created_at)
+SELECT address, 'verified', CURRENT_TIMESTAMP FROM wallets WHERE verified ORDER BY address;
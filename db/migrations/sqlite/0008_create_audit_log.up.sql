@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS audit_records (
+    seq integer PRIMARY KEY AUTOINCREMENT,
+    account text NOT NULL,
+    address text NOT NULL,
+    operation text NOT NULL,
+    transfer_id text,
+    balance integer NOT NULL,
+    prev_hash text NOT NULL,
+    hash text NOT NULL,
+    created_at datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_records_account_seq ON audit_records (account, seq);
+CREATE INDEX IF NOT EXISTS idx_audit_records_address_seq ON audit_records (address, seq);
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    prev_hash text NOT NULL,
+    hash text NOT NULL,
+    signature text NOT NULL,
+    created_at datetime NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_checkpoints_prev_hash ON audit_checkpoints (prev_hash);
+
+CREATE TABLE IF NOT EXISTS audit_checkpoint_entries (
+    checkpoint_id integer NOT NULL REFERENCES audit_checkpoints (id),
+    account text NOT NULL,
+    seq integer NOT NULL,
+    hash text NOT NULL,
+    PRIMARY KEY (checkpoint_id, account)
+);
+CREATE INDEX IF NOT EXISTS idx_audit_checkpoint_entries_account_seq ON audit_checkpoint_entries (account, seq);
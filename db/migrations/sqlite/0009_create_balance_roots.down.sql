@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS balance_leaves;
+DROP TABLE IF EXISTS balance_roots;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS balance_roots (
+    seq integer PRIMARY KEY AUTOINCREMENT,
+    root text NOT NULL,
+    wallets integer NOT NULL,
+    total integer NOT NULL,
+    created_at datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_balance_roots_root ON balance_roots (root);
+
+CREATE TABLE IF NOT EXISTS balance_leaves (
+    root_seq integer NOT NULL REFERENCES balance_roots (seq),
+    address text NOT NULL,
+    balance integer NOT NULL,
+    PRIMARY KEY (root_seq, address)
+);
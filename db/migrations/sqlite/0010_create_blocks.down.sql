@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS blocks;
+DROP INDEX IF EXISTS idx_transfers_block_number;
+ALTER TABLE transfers DROP COLUMN block_number;
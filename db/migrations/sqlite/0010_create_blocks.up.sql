@@ -0,0 +1,14 @@
+ALTER TABLE transfers ADD COLUMN block_number integer;
+CREATE INDEX IF NOT EXISTS idx_transfers_block_number ON transfers (block_number);
+
+CREATE TABLE IF NOT EXISTS blocks (
+    number integer PRIMARY KEY,
+    parent_hash text NOT NULL,
+    hash text NOT NULL,
+    transfer_root text NOT NULL,
+    state_root text NOT NULL,
+    transfer_count integer NOT NULL,
+    created_at datetime NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blocks_parent_hash ON blocks (parent_hash);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blocks_hash ON blocks (hash);
@@ -0,0 +1,23 @@
+CREATE INDEX IF NOT EXISTS idx_transfers_to_created ON transfers (to_address, created_at);
+
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    started_at datetime NOT NULL,
+    finished_at datetime NOT NULL,
+    wallets integer NOT NULL,
+    supply integer NOT NULL,
+    minted integer NOT NULL,
+    burned integer NOT NULL,
+    ok boolean NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS reconciliation_discrepancies (
+    report_id integer NOT NULL REFERENCES reconciliation_reports (id),
+    position integer NOT NULL,
+    kind text NOT NULL,
+    address text NOT NULL DEFAULT '',
+    expected integer NOT NULL,
+    actual integer NOT NULL,
+    detail text NOT NULL DEFAULT '',
+    PRIMARY KEY (report_id, position)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE wallet_snapshots DROP COLUMN frozen;
+ALTER TABLE wallets DROP COLUMN frozen;
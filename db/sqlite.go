@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"time"
+
+	"token-transfer-api/config"
+
+	"github.com/glebarez/go-sqlite"
+	gormsqlite "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// openSQLite returns the dialector for the SQLite database at cfg.SQLitePath.
+//
+// SQLite has no SELECT ... FOR UPDATE, so every transaction is started with BEGIN
+// IMMEDIATE: it takes the database write lock before its first read, which gives the
+// same read-modify-write guarantees as the Postgres row locks by running writers one
+// at a time. Writers that find the lock taken wait up to cfg.SQLiteBusyTimeout.
+func openSQLite(cfg config.DatabaseConfig) gorm.Dialector {
+	query := url.Values{}
+	query.Set("_txlock", "immediate")
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.SQLiteBusyTimeout.Milliseconds()))
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "foreign_keys(1)")
+
+	connector := sqliteConnector{dsn: "file:" + cfg.SQLitePath + "?" + query.Encode()}
+	return &gormsqlite.Dialector{Conn: sql.OpenDB(connector)}
+}
+
+var sqliteDriver = &sqlite.Driver{}
+
+// sqliteConnector opens SQLite connections that store times in UTC. SQLite has no
+// timestamp type and the driver writes times as text including their offset, so
+// values are only comparable with each other if they share one.
+type sqliteConnector struct {
+	dsn string
+}
+
+func (c sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := sqliteDriver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return utcConn{conn.(sqliteConn)}, nil
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return sqliteDriver
+}
+
+// sqliteConn is the set of driver interfaces implemented by the SQLite connection.
+type sqliteConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+}
+
+type utcConn struct {
+	sqliteConn
+}
+
+// CheckNamedValue converts arguments like database/sql would and then moves times to
+// UTC.
+func (utcConn) CheckNamedValue(arg *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(arg.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+	arg.Value = value
+	return nil
+}
@@ -0,0 +1,374 @@
+// Package dump exports the wallets and transfer history of the ledger to files other
+// tools read, and imports such exports into another ledger. An export is a directory
+// holding a wallets file and a transfers file in one of the formats of Format, for
+// example wallets.csv and transfers.csv. Both are written and read a page at a time, so
+// the size of the ledger does not bound what fits in memory.
+//
+// Every wallet records what it was issued from outside of transfers, so an export
+// carries its own proof that it conserves the supply: each balance is what the wallet was
+// issued plus what it received, minus what it sent. Import checks this before it
+// commits, which catches a sanitized export that dropped or altered rows.
+package dump
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+
+	"github.com/google/uuid"
+)
+
+// pageSize is how many rows Export reads per query and Import inserts per batch.
+const pageSize = 1000
+
+// The datasets of an export, which name its files.
+const (
+	datasetWallets   = "wallets"
+	datasetTransfers = "transfers"
+)
+
+// ErrNotConserved is returned by Import for an export whose balances do not add up to
+// what was issued and transferred.
+var ErrNotConserved = errors.New("export does not conserve supply")
+
+// Wallet is a row of the wallets file. Balance includes the shards of hot wallets.
+// Issued is what the wallet holds from outside of transfers: its opening balance and the
+// tokens minted to it.
+type Wallet struct {
+	Address  string `json:"address" parquet:"address"`
+	Balance  int    `json:"balance" parquet:"balance"`
+	Issued   int    `json:"issued" parquet:"issued"`
+	Verified bool   `json:"verified" parquet:"verified"`
+	Frozen   bool   `json:"frozen" parquet:"frozen"`
+}
+
+// Transfer is a row of the transfers file, in block order. Times are in UTC with the
+// microsecond precision the database stores. BlockNumber is nil for a transfer not
+// sealed yet; Import leaves every transfer unsealed, since blocks are not exported.
+type Transfer struct {
+	ID          string    `json:"id" parquet:"id"`
+	FromAddress string    `json:"from_address" parquet:"from_address"`
+	ToAddress   string    `json:"to_address" parquet:"to_address"`
+	Amount      int       `json:"amount" parquet:"amount"`
+	CreatedAt   time.Time `json:"created_at" parquet:"created_at,timestamp(microsecond)"`
+	BlockNumber *int64    `json:"block_number" parquet:"block_number,optional"`
+}
+
+// Summary counts what was exported or imported. Supply is the sum of the balances.
+type Summary struct {
+	Wallets   int
+	Transfers int
+	Supply    int
+}
+
+// Export writes every wallet and transfer of s to dir in format, creating dir if needed
+// and replacing the files of an earlier export. It reads from a single snapshot, so the
+// export is consistent and transfers are not held up while it runs.
+func Export(ctx context.Context, s store.Store, dir string, format Format) (*Summary, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var summary *Summary
+	err := s.Snapshot(ctx, func(tx store.Tx) error {
+		summary = &Summary{}
+		if err := exportWallets(ctx, tx, dir, format, summary); err != nil {
+			return err
+		}
+		return exportTransfers(ctx, tx, dir, format, summary)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func exportWallets(ctx context.Context, tx store.Tx, dir string, format Format, summary *Summary) (err error) {
+	w, err := create[Wallet](format, filepath.Join(dir, format.file(datasetWallets)))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := w.close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	sharded, err := tx.ShardedWallets(ctx)
+	if err != nil {
+		return err
+	}
+	after := ""
+	for {
+		wallets, err := tx.WalletsAfter(ctx, after, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(wallets) == 0 {
+			return nil
+		}
+
+		rows := make([]Wallet, len(wallets))
+		for i, wallet := range wallets {
+			balance := wallet.Balance
+			if slices.Contains(sharded, wallet.Address) {
+				shards, err := tx.WalletShards(ctx, wallet.Address)
+				if err != nil {
+					return err
+				}
+				for _, shard := range shards {
+					balance += shard.Balance
+				}
+			}
+			received, sent, err := tx.TransferTotals(ctx, wallet.Address, time.Time{})
+			if err != nil {
+				return err
+			}
+			rows[i] = Wallet{
+				Address:  wallet.Address,
+				Balance:  balance,
+				Issued:   balance - received + sent,
+				Verified: wallet.Verified,
+				Frozen:   wallet.Frozen,
+			}
+			summary.Supply += balance
+		}
+		if err := w.write(rows); err != nil {
+			return err
+		}
+		summary.Wallets += len(rows)
+		after = wallets[len(wallets)-1].Address
+	}
+}
+
+func exportTransfers(ctx context.Context, tx store.Tx, dir string, format Format, summary *Summary) (err error) {
+	w, err := create[Transfer](format, filepath.Join(dir, format.file(datasetTransfers)))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := w.close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var after *models.Transfer
+	for {
+		transfers, err := tx.TransfersAfter(ctx, after, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(transfers) == 0 {
+			return nil
+		}
+
+		rows := make([]Transfer, len(transfers))
+		for i, transfer := range transfers {
+			rows[i] = Transfer{
+				ID:          transfer.ID.String(),
+				FromAddress: transfer.FromAddress,
+				ToAddress:   transfer.ToAddress,
+				Amount:      transfer.Amount,
+				CreatedAt:   transfer.CreatedAt.UTC().Truncate(time.Microsecond),
+				BlockNumber: transfer.BlockNumber,
+			}
+		}
+		if err := w.write(rows); err != nil {
+			return err
+		}
+		summary.Transfers += len(rows)
+		after = transfers[len(transfers)-1]
+	}
+}
+
+// issuance is what Import knows of a wallet of the export while it checks conservation.
+type issuance struct {
+	balance int
+	issued  int
+	// net is what the wallet received minus what it sent in the transfers read so far.
+	net int
+}
+
+// Import adds the wallets and transfers of the export in dir to s, in a single
+// transaction that it only commits if the export conserves supply. None of the wallets
+// may exist in s yet. They are created with their balance, so their history in s starts
+// with the import and the imported transfers precede it. Import holds an entry per wallet
+// in memory; transfers are streamed.
+func Import(ctx context.Context, s store.Store, dir string, format Format) (*Summary, error) {
+	var summary *Summary
+	err := s.Transaction(ctx, func(tx store.Tx) error {
+		summary = &Summary{}
+		wallets, err := importWallets(ctx, tx, dir, format, summary)
+		if err != nil {
+			return err
+		}
+		if err := importTransfers(ctx, tx, dir, format, wallets, summary); err != nil {
+			return err
+		}
+		return conserved(wallets)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func importWallets(ctx context.Context, tx store.Tx, dir string, format Format, summary *Summary) (map[string]*issuance, error) {
+	path := filepath.Join(dir, format.file(datasetWallets))
+	r, err := open[Wallet](format, path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close()
+
+	wallets := map[string]*issuance{}
+	rows := make([]Wallet, pageSize)
+	for {
+		n, readErr := r.read(rows)
+		for _, row := range rows[:n] {
+			if err := models.ValidateWallet(row.Address, row.Balance); err != nil {
+				return nil, fmt.Errorf("%s: wallet %q: %w", path, row.Address, err)
+			}
+			if _, ok := wallets[row.Address]; ok {
+				return nil, fmt.Errorf("%s: wallet %s appears twice", path, row.Address)
+			}
+			if err := importWallet(ctx, tx, row); err != nil {
+				return nil, err
+			}
+			wallets[row.Address] = &issuance{balance: row.Balance, issued: row.Issued}
+			summary.Wallets++
+			summary.Supply += row.Balance
+		}
+		if errors.Is(readErr, io.EOF) {
+			return wallets, nil
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("%s: %w", path, readErr)
+		}
+	}
+}
+
+func importWallet(ctx context.Context, tx store.Tx, row Wallet) error {
+	_, err := tx.Wallet(ctx, row.Address)
+	if err == nil {
+		return fmt.Errorf("wallet %s already exists", row.Address)
+	}
+	if !errors.Is(err, models.ErrWalletNotFound) {
+		return err
+	}
+	if err := tx.CreateWallet(ctx, row.Address, row.Balance); err != nil {
+		return err
+	}
+	if row.Verified {
+		if _, err := tx.VerifyWallet(ctx, row.Address); err != nil {
+			return err
+		}
+	}
+	if row.Frozen {
+		if _, err := tx.SetWalletFrozen(ctx, row.Address, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importTransfers(ctx context.Context, tx store.Tx, dir string, format Format, wallets map[string]*issuance, summary *Summary) error {
+	path := filepath.Join(dir, format.file(datasetTransfers))
+	r, err := open[Transfer](format, path)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	rows := make([]Transfer, pageSize)
+	for {
+		n, readErr := r.read(rows)
+		transfers := make([]*models.Transfer, n)
+		for i, row := range rows[:n] {
+			transfer, err := transferOf(row, wallets)
+			if err != nil {
+				return fmt.Errorf("%s: transfer %s: %w", path, row.ID, err)
+			}
+			wallets[row.FromAddress].net -= row.Amount
+			wallets[row.ToAddress].net += row.Amount
+			transfers[i] = transfer
+		}
+		if n > 0 {
+			if err := tx.ImportTransfers(ctx, transfers); err != nil {
+				return err
+			}
+			summary.Transfers += n
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("%s: %w", path, readErr)
+		}
+	}
+}
+
+// transferOf checks a row of the transfers file and returns the transfer it describes.
+// Both of its wallets must be part of the export.
+func transferOf(row Transfer, wallets map[string]*issuance) (*models.Transfer, error) {
+	id, err := uuid.Parse(row.ID)
+	if err != nil {
+		return nil, err
+	}
+	if row.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if row.FromAddress == row.ToAddress {
+		return nil, errors.New("sender and receiver are the same wallet")
+	}
+	for _, address := range []string{row.FromAddress, row.ToAddress} {
+		if _, ok := wallets[address]; !ok {
+			return nil, fmt.Errorf("wallet %s is not part of the export", address)
+		}
+	}
+	if row.CreatedAt.IsZero() {
+		return nil, errors.New("time is missing")
+	}
+	return &models.Transfer{
+		ID:          id,
+		FromAddress: row.FromAddress,
+		ToAddress:   row.ToAddress,
+		Amount:      row.Amount,
+		CreatedAt:   row.CreatedAt.UTC(),
+	}, nil
+}
+
+// conserved checks that the balances add up to what was issued, and that each wallet
+// holds what it was issued plus the transfers it took part in.
+func conserved(wallets map[string]*issuance) error {
+	supply, issued := 0, 0
+	var mismatched []string
+	for address, wallet := range wallets {
+		supply += wallet.balance
+		issued += wallet.issued
+		if wallet.balance != wallet.issued+wallet.net {
+			mismatched = append(mismatched, address)
+		}
+	}
+	if supply != issued {
+		return fmt.Errorf("%w: the wallets hold %d but were issued %d", ErrNotConserved, supply, issued)
+	}
+	if len(mismatched) == 0 {
+		return nil
+	}
+	slices.Sort(mismatched)
+	wallet := wallets[mismatched[0]]
+	err := fmt.Errorf("%w: wallet %s holds %d but was issued %d and received %d net in transfers",
+		ErrNotConserved, mismatched[0], wallet.balance, wallet.issued, wallet.net)
+	if len(mismatched) > 1 {
+		err = fmt.Errorf("%w, and %d more wallets do not add up", err, len(mismatched)-1)
+	}
+	return err
+}
@@ -0,0 +1,335 @@
+package dump
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Format is the file format of an export. Every format has the same columns, named
+// after those of the database: address, balance, issued, verified and frozen for
+// wallets, and id, from_address, to_address, amount, created_at and block_number for
+// transfers.
+type Format string
+
+const (
+	// CSV writes a header row, then a row per record. Times are RFC 3339 and a transfer
+	// without a block has an empty block_number.
+	CSV Format = "csv"
+	// JSONL writes a JSON object per line, with the same fields as CSV.
+	JSONL Format = "jsonl"
+	// Parquet writes a Snappy-compressed Parquet file, which columnar tools such as
+	// DuckDB, Spark and pandas read directly. created_at is a microsecond timestamp and
+	// block_number is optional.
+	Parquet Format = "parquet"
+)
+
+// Formats lists every format.
+var Formats = []Format{CSV, JSONL, Parquet}
+
+// rowGroupSize bounds the rows a Parquet writer buffers before writing a row group.
+const rowGroupSize = 64 * pageSize
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	format := Format(name)
+	if !slices.Contains(Formats, format) {
+		return "", fmt.Errorf("unknown format %q: must be csv, jsonl or parquet", name)
+	}
+	return format, nil
+}
+
+// file returns the name of the file holding dataset.
+func (f Format) file(dataset string) string {
+	return dataset + "." + string(f)
+}
+
+// row is a row type of an export that has a CSV form.
+type row[T any] interface {
+	*T
+	// columns returns the names of the columns.
+	columns() []string
+	// fields returns the columns of the row as text.
+	fields() []string
+	// parse sets the row from the columns as text.
+	parse(fields []string) error
+}
+
+func (w *Wallet) columns() []string {
+	return []string{"address", "balance", "issued", "verified", "frozen"}
+}
+
+func (w *Wallet) fields() []string {
+	return []string{w.Address, strconv.Itoa(w.Balance), strconv.Itoa(w.Issued), strconv.FormatBool(w.Verified), strconv.FormatBool(w.Frozen)}
+}
+
+func (w *Wallet) parse(fields []string) error {
+	var err error
+	w.Address = fields[0]
+	if w.Balance, err = strconv.Atoi(fields[1]); err != nil {
+		return fmt.Errorf("invalid balance %q", fields[1])
+	}
+	if w.Issued, err = strconv.Atoi(fields[2]); err != nil {
+		return fmt.Errorf("invalid issued %q", fields[2])
+	}
+	if w.Verified, err = strconv.ParseBool(fields[3]); err != nil {
+		return fmt.Errorf("invalid verified %q", fields[3])
+	}
+	if w.Frozen, err = strconv.ParseBool(fields[4]); err != nil {
+		return fmt.Errorf("invalid frozen %q", fields[4])
+	}
+	return nil
+}
+
+func (t *Transfer) columns() []string {
+	return []string{"id", "from_address", "to_address", "amount", "created_at", "block_number"}
+}
+
+func (t *Transfer) fields() []string {
+	block := ""
+	if t.BlockNumber != nil {
+		block = strconv.FormatInt(*t.BlockNumber, 10)
+	}
+	return []string{t.ID, t.FromAddress, t.ToAddress, strconv.Itoa(t.Amount), t.CreatedAt.Format(time.RFC3339Nano), block}
+}
+
+func (t *Transfer) parse(fields []string) error {
+	var err error
+	t.ID, t.FromAddress, t.ToAddress = fields[0], fields[1], fields[2]
+	if t.Amount, err = strconv.Atoi(fields[3]); err != nil {
+		return fmt.Errorf("invalid amount %q", fields[3])
+	}
+	if t.CreatedAt, err = time.Parse(time.RFC3339Nano, fields[4]); err != nil {
+		return fmt.Errorf("invalid created_at %q", fields[4])
+	}
+	t.BlockNumber = nil
+	if fields[5] != "" {
+		block, err := strconv.ParseInt(fields[5], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block_number %q", fields[5])
+		}
+		t.BlockNumber = &block
+	}
+	return nil
+}
+
+// writer writes the rows of a dataset to its file.
+type writer[T any] interface {
+	write(rows []T) error
+	// close flushes what is buffered and closes the file.
+	close() error
+}
+
+// reader reads the rows of a dataset from its file.
+type reader[T any] interface {
+	// read fills rows and returns how many it read, and io.EOF once there are no more.
+	read(rows []T) (int, error)
+	close() error
+}
+
+func create[T any, P row[T]](format Format, path string) (writer[T], error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case CSV:
+		w := &csvWriter[T, P]{file: file, csv: csv.NewWriter(file)}
+		if err := w.csv.Write(P(new(T)).columns()); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return w, nil
+	case JSONL:
+		buffered := bufio.NewWriter(file)
+		return &jsonWriter[T]{file: file, buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	default:
+		return &parquetWriter[T]{
+			file:    file,
+			parquet: parquet.NewGenericWriter[T](file, parquet.Compression(&parquet.Snappy), parquet.MaxRowsPerRowGroup(rowGroupSize)),
+		}, nil
+	}
+}
+
+func open[T any, P row[T]](format Format, path string) (reader[T], error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case CSV:
+		r := &csvReader[T, P]{file: file, csv: csv.NewReader(bufio.NewReader(file))}
+		if err := r.header(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return r, nil
+	case JSONL:
+		decoder := json.NewDecoder(bufio.NewReader(file))
+		decoder.DisallowUnknownFields()
+		return &jsonReader[T]{file: file, decoder: decoder}, nil
+	default:
+		r, err := openParquet[T](file, P(new(T)).columns())
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return r, nil
+	}
+}
+
+type csvWriter[T any, P row[T]] struct {
+	file *os.File
+	csv  *csv.Writer
+}
+
+func (w *csvWriter[T, P]) write(rows []T) error {
+	for i := range rows {
+		if err := w.csv.Write(P(&rows[i]).fields()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvWriter[T, P]) close() error {
+	w.csv.Flush()
+	return errors.Join(w.csv.Error(), w.file.Close())
+}
+
+type csvReader[T any, P row[T]] struct {
+	file *os.File
+	csv  *csv.Reader
+}
+
+// header checks that the first line names the columns of the rows, in order.
+func (r *csvReader[T, P]) header() error {
+	columns, err := r.csv.Read()
+	if errors.Is(err, io.EOF) {
+		return errors.New("header is missing")
+	}
+	if err != nil {
+		return err
+	}
+	if expected := P(new(T)).columns(); !slices.Equal(columns, expected) {
+		return fmt.Errorf("header has columns %v, expected %v", columns, expected)
+	}
+	return nil
+}
+
+func (r *csvReader[T, P]) read(rows []T) (int, error) {
+	for i := range rows {
+		fields, err := r.csv.Read()
+		if err != nil {
+			return i, err
+		}
+		if err := P(&rows[i]).parse(fields); err != nil {
+			line, _ := r.csv.FieldPos(0)
+			return i, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return len(rows), nil
+}
+
+func (r *csvReader[T, P]) close() error {
+	return r.file.Close()
+}
+
+type jsonWriter[T any] struct {
+	file     *os.File
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (w *jsonWriter[T]) write(rows []T) error {
+	for i := range rows {
+		if err := w.encoder.Encode(&rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *jsonWriter[T]) close() error {
+	return errors.Join(w.buffered.Flush(), w.file.Close())
+}
+
+type jsonReader[T any] struct {
+	file    *os.File
+	decoder *json.Decoder
+	line    int
+}
+
+func (r *jsonReader[T]) read(rows []T) (int, error) {
+	for i := range rows {
+		var row T
+		if err := r.decoder.Decode(&row); err != nil {
+			if errors.Is(err, io.EOF) {
+				return i, err
+			}
+			return i, fmt.Errorf("record %d: %w", r.line+1, err)
+		}
+		rows[i] = row
+		r.line++
+	}
+	return len(rows), nil
+}
+
+func (r *jsonReader[T]) close() error {
+	return r.file.Close()
+}
+
+type parquetWriter[T any] struct {
+	file    *os.File
+	parquet *parquet.GenericWriter[T]
+}
+
+func (w *parquetWriter[T]) write(rows []T) error {
+	_, err := w.parquet.Write(rows)
+	return err
+}
+
+func (w *parquetWriter[T]) close() error {
+	return errors.Join(w.parquet.Close(), w.file.Close())
+}
+
+type parquetReader[T any] struct {
+	file    *os.File
+	parquet *parquet.GenericReader[T]
+}
+
+// openParquet reads the metadata of file and fails unless it has every column of the
+// rows, which would otherwise read as zero values.
+func openParquet[T any](file *os.File, columns []string) (*parquetReader[T], error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	f, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		if _, ok := f.Schema().Lookup(column); !ok {
+			return nil, fmt.Errorf("column %s is missing", column)
+		}
+	}
+	return &parquetReader[T]{file: file, parquet: parquet.NewGenericReader[T](f)}, nil
+}
+
+func (r *parquetReader[T]) read(rows []T) (int, error) {
+	return r.parquet.Read(rows)
+}
+
+func (r *parquetReader[T]) close() error {
+	return errors.Join(r.parquet.Close(), r.file.Close())
+}
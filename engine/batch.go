@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// batchTx is the transaction of a batch as the executor sees it. Wallets locked through
+// it stay locked for the rest of the batch and are kept in memory: a transfer reads the
+// balances left by the transfers before it, and each wallet is written once, by flush.
+// Executors must change locked wallets through SaveWallet only.
+type batchTx struct {
+	store.Tx
+	wallets *walletCache
+}
+
+// walletCache holds the wallets a batch has locked and the undo log that rolls them back
+// with a savepoint.
+type walletCache struct {
+	rows  map[string]*models.Wallet
+	dirty map[string]bool
+	undo  []undo
+}
+
+// undo restores the cached state of one wallet. A nil row means it was not cached.
+type undo struct {
+	address string
+	row     *models.Wallet
+	dirty   bool
+}
+
+func newBatchTx(tx store.Tx) *batchTx {
+	return &batchTx{Tx: tx, wallets: &walletCache{rows: map[string]*models.Wallet{}, dirty: map[string]bool{}}}
+}
+
+func (b *batchTx) Wallet(ctx context.Context, address string) (*models.Wallet, error) {
+	if row, ok := b.wallets.rows[address]; ok {
+		wallet := *row
+		return &wallet, nil
+	}
+	return b.Tx.Wallet(ctx, address)
+}
+
+func (b *batchTx) LockWallet(ctx context.Context, address string) (*models.Wallet, error) {
+	if row, ok := b.wallets.rows[address]; ok {
+		wallet := *row
+		return &wallet, nil
+	}
+	wallet, err := b.Tx.LockWallet(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	row := *wallet
+	b.wallets.undo = append(b.wallets.undo, undo{address: address})
+	b.wallets.rows[address] = &row
+	return wallet, nil
+}
+
+func (b *batchTx) SaveWallet(ctx context.Context, wallet *models.Wallet) error {
+	row, ok := b.wallets.rows[wallet.Address]
+	if !ok {
+		return b.Tx.SaveWallet(ctx, wallet)
+	}
+	previous := *row
+	b.wallets.undo = append(b.wallets.undo, undo{address: wallet.Address, row: &previous, dirty: b.wallets.dirty[wallet.Address]})
+	*row = *wallet
+	b.wallets.dirty[wallet.Address] = true
+	return nil
+}
+
+// Transaction runs fn in a savepoint and rolls the cached wallets back with it. Wallets
+// first locked inside a rolled back savepoint are dropped from the cache, since the
+// database may have released their lock.
+func (b *batchTx) Transaction(ctx context.Context, fn func(tx store.Tx) error) error {
+	mark := len(b.wallets.undo)
+	err := b.Tx.Transaction(ctx, func(tx store.Tx) error {
+		return fn(&batchTx{Tx: tx, wallets: b.wallets})
+	})
+	if err != nil {
+		b.wallets.rollback(mark)
+	}
+	return err
+}
+
+func (c *walletCache) rollback(mark int) {
+	for i := len(c.undo) - 1; i >= mark; i-- {
+		entry := c.undo[i]
+		if entry.row == nil {
+			delete(c.rows, entry.address)
+			delete(c.dirty, entry.address)
+			continue
+		}
+		c.rows[entry.address] = entry.row
+		c.dirty[entry.address] = entry.dirty
+	}
+	c.undo = c.undo[:mark]
+}
+
+// flush writes the wallets changed by the batch, in address order.
+func (b *batchTx) flush(ctx context.Context) error {
+	addresses := make([]string, 0, len(b.wallets.dirty))
+	for address, dirty := range b.wallets.dirty {
+		if dirty {
+			addresses = append(addresses, address)
+		}
+	}
+	slices.Sort(addresses)
+	for _, address := range addresses {
+		if err := b.Tx.SaveWallet(ctx, b.wallets.rows[address]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
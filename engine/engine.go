@@ -0,0 +1,232 @@
+// Package engine executes transfers in batches. Callers queue transfers with Submit; a
+// single goroutine takes them off the queue, applies a batch of them one after another
+// in one database transaction and commits them together. The wallets of a batch are
+// locked, read and written once instead of once per transfer, which is what limits the
+// throughput of many small transfers between the same wallets.
+//
+// Each transfer runs in a savepoint, so one that fails is rolled back on its own and
+// only reported to its caller. If the batch itself fails to commit, every transfer in it
+// fails with that error.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"token-transfer-api/config"
+	"token-transfer-api/metrics"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+	"token-transfer-api/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrStopped is returned by Submit once the engine has stopped.
+var ErrStopped = errors.New("transfer engine stopped")
+
+// Request is a transfer waiting to be executed.
+type Request struct {
+	FromAddress string
+	ToAddress   string
+	Amount      int
+}
+
+// Executor applies transfers inside the transaction of a batch.
+type Executor interface {
+	// Prepare runs at the start of every batch. It locks the rows the transfers of the
+	// batch are going to lock, in a consistent order, so that batches cannot deadlock
+	// with each other or with other transactions.
+	Prepare(ctx context.Context, tx store.Tx, batch []Request) error
+	// Execute applies one transfer and returns the sender's wallet. An error rolls back
+	// what it did and is returned to the caller of Submit.
+	Execute(ctx context.Context, tx store.Tx, req Request) (*models.Wallet, error)
+}
+
+// Engine is the single writer executing queued transfers. Run must be running for
+// Submit to make progress.
+type Engine struct {
+	store    store.Store
+	cfg      config.EngineConfig
+	executor Executor
+
+	queue chan *pending
+	// stopped is closed when Run stops taking new transfers, done when it has returned.
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+// pending is a queued transfer and the channel its caller waits on.
+type pending struct {
+	ctx    context.Context
+	req    Request
+	queued time.Time
+	result chan result
+}
+
+type result struct {
+	wallet *models.Wallet
+	err    error
+}
+
+func New(s store.Store, cfg config.EngineConfig, executor Executor) *Engine {
+	return &Engine{
+		store:    s,
+		cfg:      cfg,
+		executor: executor,
+		queue:    make(chan *pending, cfg.QueueSize),
+		stopped:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Submit queues a transfer and waits until its batch has committed or failed. ctx
+// bounds the wait for room in the queue; a transfer whose context is done by the time
+// its batch starts is dropped. Once the transfer is part of a batch, Submit waits for
+// the outcome even if ctx is cancelled, so that it never reports a committed transfer
+// as failed.
+func (e *Engine) Submit(ctx context.Context, req Request) (*models.Wallet, error) {
+	p := &pending{ctx: ctx, req: req, queued: time.Now(), result: make(chan result, 1)}
+
+	select {
+	case <-e.stopped:
+		return nil, ErrStopped
+	default:
+	}
+	select {
+	case e.queue <- p:
+	case <-e.stopped:
+		return nil, ErrStopped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case res := <-p.result:
+		return res.wallet, res.err
+	case <-e.done:
+		// Queued after Run drained the queue for the last time.
+		select {
+		case res := <-p.result:
+			return res.wallet, res.err
+		default:
+			return nil, ErrStopped
+		}
+	}
+}
+
+// Run executes queued transfers until ctx is done. It then stops accepting transfers,
+// executes the ones still queued and returns.
+func (e *Engine) Run(ctx context.Context) {
+	defer close(e.done)
+	for {
+		select {
+		case <-ctx.Done():
+			close(e.stopped)
+			for {
+				batch := e.take(nil)
+				if len(batch) == 0 {
+					return
+				}
+				e.commit(batch)
+			}
+		case first := <-e.queue:
+			e.commit(e.collect(ctx, first))
+		}
+	}
+}
+
+// collect starts a batch with first and adds the transfers queued within MaxDelay, up
+// to MaxBatch.
+func (e *Engine) collect(ctx context.Context, first *pending) []*pending {
+	batch := []*pending{first}
+	timer := time.NewTimer(e.cfg.MaxDelay)
+	defer timer.Stop()
+	for len(batch) < e.cfg.MaxBatch {
+		select {
+		case p := <-e.queue:
+			batch = append(batch, p)
+		case <-timer.C:
+			return e.take(batch)
+		case <-ctx.Done():
+			return batch
+		}
+	}
+	return batch
+}
+
+// take adds the transfers already queued to batch, up to MaxBatch, without waiting.
+func (e *Engine) take(batch []*pending) []*pending {
+	for len(batch) < max(e.cfg.MaxBatch, 1) {
+		select {
+		case p := <-e.queue:
+			batch = append(batch, p)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// commit executes batch in one transaction and hands every caller its result.
+func (e *Engine) commit(batch []*pending) {
+	start := time.Now()
+	var live []*pending
+	for _, p := range batch {
+		metrics.EngineQueueWait.Observe(start.Sub(p.queued).Seconds())
+		if err := p.ctx.Err(); err != nil {
+			p.result <- result{err: err}
+			continue
+		}
+		live = append(live, p)
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	requests := make([]Request, len(live))
+	for i, p := range live {
+		requests[i] = p.req
+	}
+
+	ctx, span := tracing.Start(context.Background(), "engine.batch", attribute.Int("engine.batch_size", len(live)))
+	var results []result
+	err := e.store.Transaction(ctx, func(tx store.Tx) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("transfer engine: panic: %v", r)
+			}
+		}()
+
+		// Start over if the transaction is retried.
+		results = make([]result, len(live))
+		btx := newBatchTx(tx)
+		if err := e.executor.Prepare(ctx, btx, requests); err != nil {
+			return err
+		}
+		for i, p := range live {
+			// The transfer belongs to the batch now; its caller can no longer cancel it.
+			ctx := context.WithoutCancel(p.ctx)
+			var wallet *models.Wallet
+			err := btx.Transaction(ctx, func(tx store.Tx) error {
+				var err error
+				wallet, err = e.executor.Execute(ctx, tx, p.req)
+				return err
+			})
+			results[i] = result{wallet: wallet, err: err}
+		}
+		return btx.flush(ctx)
+	})
+	tracing.End(span, err)
+	metrics.EngineBatchSize.Observe(float64(len(live)))
+	metrics.EngineBatchDuration.Observe(time.Since(start).Seconds())
+
+	for i, p := range live {
+		if err != nil {
+			p.result <- result{err: err}
+			continue
+		}
+		p.result <- results[i]
+	}
+}
@@ -0,0 +1,20 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"token-transfer-api/logging"
+)
+
+var errForbidden = errors.New("forbidden: admin principal required")
+
+// requireAdmin fails unless the caller's principal, see logging.Principal, is one of
+// r.Admins. Anonymous callers never are.
+func (r *Resolver) requireAdmin(ctx context.Context) error {
+	principal := logging.Principal(ctx)
+	if principal == "" || principal == logging.Anonymous || !slices.Contains(r.Admins, principal) {
+		return errForbidden
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"token-transfer-api/engine"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// BatchExecutor returns the engine.Executor that applies transfers the way the transfer
+// field does, for an engine set as r.Engine.
+func (r *Resolver) BatchExecutor() engine.Executor {
+	return batchExecutor{r}
+}
+
+type batchExecutor struct{ r *Resolver }
+
+// Prepare locks the wallets of the batch in address order, as applyTransfer does for
+// the two wallets of one transfer. Batches hold no transfers of hot wallets, see
+// transfer, as their shards could not be locked in that order. Missing wallets are
+// skipped and left for Execute to report.
+func (e batchExecutor) Prepare(ctx context.Context, tx store.Tx, batch []engine.Request) error {
+	addresses := make([]string, 0, 2*len(batch))
+	for _, req := range batch {
+		addresses = append(addresses, req.FromAddress, req.ToAddress)
+	}
+	slices.Sort(addresses)
+	for _, address := range slices.Compact(addresses) {
+		_, err := lockWallet(ctx, tx, address, models.ErrWalletNotFound)
+		if err != nil && !errors.Is(err, models.ErrWalletNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e batchExecutor) Execute(ctx context.Context, tx store.Tx, req engine.Request) (*models.Wallet, error) {
+	wallet, _, err := e.r.applyTransfer(ctx, tx, req.FromAddress, req.ToAddress, req.Amount, transferOptions{})
+	return wallet, err
+}
@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"context"
+	"time"
+	"token-transfer-api/ledger"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// balancesPageSize is how many wallets the balances field rebuilds at a time.
+const balancesPageSize = 500
+
+// walletAsOf rebuilds a wallet as it stood at the given time from its snapshots and
+// events. The result keeps the ID of the current row.
+func (r *Resolver) walletAsOf(ctx context.Context, current *models.Wallet, at time.Time) (*models.Wallet, error) {
+	wallet, err := ledger.ProjectAsOf(ctx, r.Store, current.Address, at)
+	if err != nil {
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, models.ErrWalletNotFound
+	}
+	wallet.ID = current.ID
+	wallet.AsOf = &at
+	return wallet, nil
+}
+
+// balancesAsOf rebuilds every wallet that existed at the given time, in address order.
+// The wallets are rebuilt a page at a time in one snapshot, so the balances are a
+// consistent cut of the ledger.
+func (r *Resolver) balancesAsOf(ctx context.Context, asOf time.Time) ([]*models.Wallet, error) {
+	var balances []*models.Wallet
+	err := r.Store.Snapshot(ctx, func(tx store.Tx) error {
+		balances = []*models.Wallet{}
+		after := ""
+		for {
+			wallets, err := tx.WalletsAfter(ctx, after, balancesPageSize)
+			if err != nil {
+				return err
+			}
+			addresses := make([]string, len(wallets))
+			for i, wallet := range wallets {
+				addresses[i] = wallet.Address
+			}
+			projected, err := ledger.ProjectAllAsOf(ctx, tx, addresses, asOf)
+			if err != nil {
+				return err
+			}
+			for _, wallet := range wallets {
+				balance := projected[wallet.Address]
+				if balance == nil {
+					continue
+				}
+				balance.ID = wallet.ID
+				balance.AsOf = &asOf
+				balances = append(balances, balance)
+			}
+			if len(wallets) < balancesPageSize {
+				return nil
+			}
+			after = wallets[len(wallets)-1].Address
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// lockedBalance returns the part of the balance of a wallet that is still vesting, at
+// the time the wallet was rebuilt at if it was. Tranches granted after that time are
+// left out.
+func (r *Resolver) lockedBalance(ctx context.Context, wallet *models.Wallet) (int, error) {
+	if wallet.AsOf == nil {
+		return r.Store.LockedBalance(ctx, wallet.Address, time.Now())
+	}
+	tranches, err := r.Store.VestingTranches(ctx, wallet.Address)
+	if err != nil {
+		return 0, err
+	}
+	locked := 0
+	for _, tranche := range tranches {
+		if !tranche.CreatedAt.After(*wallet.AsOf) {
+			locked += tranche.Locked(*wallet.AsOf)
+		}
+	}
+	return locked, nil
+}
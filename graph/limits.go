@@ -0,0 +1,83 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+var (
+	errSingleTransferLimit = errors.New("amount exceeds single transfer limit")
+	errDailyLimit          = errors.New("transfer exceeds daily outbound limit")
+	errMonthlyLimit        = errors.New("transfer exceeds monthly outbound limit")
+	errTransferCountLimit  = errors.New("too many transfers in window")
+)
+
+// limitsFor returns the limits that apply to wallet, or nil when it is unlimited.
+// Limits set on the wallet take precedence over the defaults for unverified wallets.
+func (r *Resolver) limitsFor(ctx context.Context, tx store.Tx, wallet *models.Wallet) (*models.WalletLimits, error) {
+	limits, err := tx.WalletLimits(ctx, wallet.Address)
+	if err != nil {
+		return nil, err
+	}
+	if limits == nil && !wallet.Verified {
+		limits = r.UnverifiedLimits
+	}
+	if limits == nil || limits.Unlimited() {
+		return nil, nil
+	}
+	return limits, nil
+}
+
+// enforceLimits checks an outbound transfer of amount against the limits of wallet and
+// records it in the wallet's spend counters. The wallet must already be locked so that no
+// other transfer from it can read or change the counters before tx ends. Transfers from
+// unlimited wallets are not recorded, so limits set later only count spend from then on.
+func (r *Resolver) enforceLimits(ctx context.Context, tx store.Tx, wallet *models.Wallet, amount int, now time.Time) error {
+	limits, err := r.limitsFor(ctx, tx, wallet)
+	if err != nil || limits == nil {
+		return err
+	}
+
+	if limits.MaxSingleTransfer > 0 && amount > limits.MaxSingleTransfer {
+		return errSingleTransferLimit
+	}
+
+	if limits.DailyOutbound > 0 {
+		spent, _, err := tx.SpentSince(ctx, wallet.Address, now.Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if spent+amount > limits.DailyOutbound {
+			return errDailyLimit
+		}
+	}
+
+	if limits.MonthlyOutbound > 0 {
+		spent, _, err := tx.SpentSince(ctx, wallet.Address, now.Add(-30*24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if spent+amount > limits.MonthlyOutbound {
+			return errMonthlyLimit
+		}
+	}
+
+	if limits.MaxTransfersPerWindow > 0 {
+		window := time.Duration(limits.WindowMinutes) * time.Minute
+		_, transfers, err := tx.SpentSince(ctx, wallet.Address, now.Add(-window))
+		if err != nil {
+			return err
+		}
+		if transfers+1 > limits.MaxTransfersPerWindow {
+			return errTransferCountLimit
+		}
+	}
+
+	if err := tx.RecordSpend(ctx, wallet.Address, amount, now); err != nil {
+		return err
+	}
+	return tx.PruneSpendCounters(ctx, wallet.Address, now)
+}
@@ -0,0 +1,39 @@
+package graph
+
+import (
+	"context"
+	"time"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// Mint credits a wallet, or a shard of a hot wallet, with amount newly created tokens and
+// records its models.EventMinted event and audit records. It increases the supply, so it
+// is an operator command and not part of the API.
+func (r *Resolver) Mint(ctx context.Context, address string, amount int) (*models.Wallet, error) {
+	if amount <= 0 {
+		return nil, errAmountNotPositive
+	}
+
+	var wallet *models.Wallet
+	err := r.Store.Transaction(ctx, func(tx store.Tx) error {
+		to, err := r.lockHolding(ctx, tx, address, 0, models.ErrWalletNotFound)
+		if err != nil {
+			return err
+		}
+		to.credit(amount)
+		wallet, err = to.save(ctx, tx)
+		if err != nil {
+			return err
+		}
+		event := &models.WalletEvent{Address: address, Type: models.EventMinted, Amount: amount, CreatedAt: time.Now()}
+		if err := tx.AppendEvents(ctx, event); err != nil {
+			return err
+		}
+		return tx.AppendAuditRecords(ctx, to.auditRecords(models.AuditMint, nil)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
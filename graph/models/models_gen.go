@@ -2,8 +2,69 @@
 
 package models
 
+import (
+	"token-transfer-api/models"
+)
+
+type AuditProblem struct {
+	Kind    string  `json:"kind"`
+	Account *string `json:"account,omitempty"`
+	Seq     *int    `json:"seq,omitempty"`
+	Detail  string  `json:"detail"`
+}
+
+type AuditReport struct {
+	Ok                 bool            `json:"ok"`
+	Records            int             `json:"records"`
+	Checkpoints        int             `json:"checkpoints"`
+	SignaturesVerified bool            `json:"signaturesVerified"`
+	Problems           []*AuditProblem `json:"problems"`
+}
+
+type BalanceProof struct {
+	Seq      int          `json:"seq"`
+	Root     string       `json:"root"`
+	Address  string       `json:"address"`
+	Balance  int          `json:"balance"`
+	Siblings []*ProofStep `json:"siblings"`
+}
+
+type MultisigSignerInput struct {
+	Signer    string `json:"signer"`
+	PublicKey string `json:"publicKey"`
+}
+
 type Mutation struct {
 }
 
+type ProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
 type Query struct {
 }
+
+type TransferSimulation struct {
+	Ok          bool     `json:"ok"`
+	FromBalance *int     `json:"fromBalance,omitempty"`
+	ToBalance   *int     `json:"toBalance,omitempty"`
+	Fee         int      `json:"fee"`
+	Violations  []string `json:"violations"`
+}
+
+type VestingSchedule struct {
+	Address          string                   `json:"address"`
+	Balance          int                      `json:"balance"`
+	LockedBalance    int                      `json:"lockedBalance"`
+	SpendableBalance int                      `json:"spendableBalance"`
+	Tranches         []*models.VestingTranche `json:"tranches"`
+}
+
+type WalletLimitsInput struct {
+	MaxSingleTransfer     int `json:"maxSingleTransfer"`
+	DailyOutbound         int `json:"dailyOutbound"`
+	MonthlyOutbound       int `json:"monthlyOutbound"`
+	MaxTransfersPerWindow int `json:"maxTransfersPerWindow"`
+	WindowMinutes         int `json:"windowMinutes"`
+}
@@ -0,0 +1,156 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	gqlmodels "token-transfer-api/graph/models"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+var (
+	errMultisigSender  = errors.New("sender is a multisig wallet, propose the transfer instead")
+	errNotMultisig     = errors.New("sender is not a multisig wallet")
+	errProposalExpired = errors.New("proposal expired")
+	errProposalDecided = errors.New("proposal is no longer pending")
+	errAlreadyApproved = errors.New("signer already approved this proposal")
+)
+
+// signerKeys maps the names of signers to their public keys.
+func signerKeys(signers []*gqlmodels.MultisigSignerInput) (map[string]string, error) {
+	keys := make(map[string]string, len(signers))
+	for _, signer := range signers {
+		if _, ok := keys[signer.Signer]; ok {
+			return nil, fmt.Errorf("duplicate signer %s", signer.Signer)
+		}
+		keys[signer.Signer] = signer.PublicKey
+	}
+	return keys, nil
+}
+
+// proposalTTL returns how long new proposals stay open.
+func (r *Resolver) proposalTTL() time.Duration {
+	if r.ProposalTTL > 0 {
+		return r.ProposalTTL
+	}
+	return models.DefaultProposalTTL
+}
+
+// requireSingleSigner fails transfers from multisig wallets that were not approved
+// through a proposal.
+func requireSingleSigner(ctx context.Context, tx store.Tx, fromAddress string) error {
+	multisig, err := tx.MultisigWallet(ctx, fromAddress)
+	if err != nil {
+		return err
+	}
+	if multisig != nil {
+		return errMultisigSender
+	}
+	return nil
+}
+
+// approveProposal records the approval of signer on a locked proposal and executes the
+// transfer once the threshold is reached. It reports whether the transfer was executed.
+func (r *Resolver) approveProposal(ctx context.Context, tx store.Tx, proposal *models.TransferProposal, signer string, signature string, now time.Time) (bool, error) {
+	if proposal.Status != models.ProposalPending {
+		return false, errProposalDecided
+	}
+	if !now.Before(proposal.ExpiresAt) {
+		return false, errProposalExpired
+	}
+
+	multisig, err := tx.MultisigWallet(ctx, proposal.FromAddress)
+	if err != nil {
+		return false, err
+	}
+	if multisig == nil {
+		return false, errNotMultisig
+	}
+
+	key, err := tx.Signer(ctx, proposal.FromAddress, signer)
+	if err != nil {
+		return false, err
+	}
+	if err := key.VerifySignature(models.ApprovalMessage(proposal.ID.String()), signature); err != nil {
+		return false, err
+	}
+
+	added, err := tx.AddApproval(ctx, &models.ProposalApproval{ProposalID: proposal.ID, Signer: signer, Signature: signature})
+	if err != nil {
+		return false, err
+	}
+	if !added {
+		return false, errAlreadyApproved
+	}
+
+	approvals, err := tx.CountApprovals(ctx, proposal.ID)
+	if err != nil {
+		return false, err
+	}
+	if approvals < multisig.Threshold {
+		return false, nil
+	}
+
+	if _, _, err := r.applyTransfer(ctx, tx, proposal.FromAddress, proposal.ToAddress, proposal.Amount, transferOptions{proposal: true}); err != nil {
+		return false, err
+	}
+
+	proposal.Status = models.ProposalExecuted
+	proposal.ExecutedAt = &now
+	if err := tx.SaveProposal(ctx, proposal); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// approveSignerChange records the approval of signer on a locked signer change and
+// replaces the wallet's signers once the threshold of the current signers is reached.
+// The caller must hold the lock of the wallet's multisig settings, which
+// ReplaceMultisigSigners takes, before locking the change.
+func approveSignerChange(ctx context.Context, tx store.Tx, multisig *models.MultisigWallet, change *models.MultisigChange, signer string, signature string, now time.Time) error {
+	if change.Status != models.ProposalPending {
+		return errProposalDecided
+	}
+	if !now.Before(change.ExpiresAt) {
+		return errProposalExpired
+	}
+
+	key, err := tx.Signer(ctx, change.Address, signer)
+	if err != nil {
+		return err
+	}
+	if err := key.VerifySignature(models.ApprovalMessage(change.ID.String()), signature); err != nil {
+		return err
+	}
+
+	added, err := tx.AddApproval(ctx, &models.ProposalApproval{ProposalID: change.ID, Signer: signer, Signature: signature})
+	if err != nil {
+		return err
+	}
+	if !added {
+		return errAlreadyApproved
+	}
+
+	approvals, err := tx.CountApprovals(ctx, change.ID)
+	if err != nil {
+		return err
+	}
+	if approvals < multisig.Threshold {
+		return nil
+	}
+
+	signers, err := change.SignerKeys()
+	if err != nil {
+		return err
+	}
+	// This expires the change along with the wallet's other pending ones, so it is
+	// saved as executed afterwards.
+	if _, err := tx.ReplaceMultisigSigners(ctx, change.Address, signers, change.Threshold); err != nil {
+		return err
+	}
+	change.Status = models.ProposalExecuted
+	change.ExecutedAt = &now
+	return tx.SaveMultisigChange(ctx, change)
+}
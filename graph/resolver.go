@@ -2,106 +2,636 @@ package graph
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"token-transfer-api/audit"
+	"token-transfer-api/blocks"
+	"token-transfer-api/engine"
 	"token-transfer-api/graph/generated"
+	gqlmodels "token-transfer-api/graph/models"
+	"token-transfer-api/metrics"
 	"token-transfer-api/models"
+	"token-transfer-api/reserves"
+	"token-transfer-api/rules"
+	"token-transfer-api/store"
+	"token-transfer-api/tracing"
 
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Resolver struct {
-	DB *gorm.DB
+	Store store.Store
+	// UnverifiedLimits apply to unverified wallets without limits of their own.
+	// Nil leaves such wallets unlimited.
+	UnverifiedLimits *models.WalletLimits
+	// Rules are evaluated before every transfer commits. Nil allows all transfers.
+	Rules *rules.Engine
+	// Admins are the principals, see logging.Principal, allowed to run admin mutations
+	// such as deciding transfer reviews. Without any, admin mutations are refused.
+	Admins []string
+	// ProposalTTL is how long multisig proposals collect approvals.
+	// Zero uses models.DefaultProposalTTL.
+	ProposalTTL time.Duration
+	// HotWallets keep their balance in HotWalletShards shards, so that transfers from
+	// them lock a single shard instead of the wallet row. See RebalanceHotWallets.
+	HotWallets      []string
+	HotWalletShards int
+	// Engine executes the transfers of the transfer field in batches when set; see
+	// BatchExecutor. Other operations always run in transactions of their own.
+	Engine *engine.Engine
+	// AuditKey checks the signatures of audit checkpoints in verifyAuditChain. Nil leaves
+	// them unchecked.
+	AuditKey ed25519.PublicKey
+	// Blocks is told about every committed transfer when set, so it can seal a block as
+	// soon as one is full.
+	Blocks *blocks.Producer
+
+	// lastTransfer is the Unix time in nanoseconds of the last committed transfer.
+	lastTransfer atomic.Int64
+}
+
+// LastTransferAt returns when the last transfer committed, or the zero time if none has
+// since the resolver was created.
+func (r *Resolver) LastTransferAt() time.Time {
+	if nanos := r.lastTransfer.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// transferCommitted records that a transfer was committed.
+func (r *Resolver) transferCommitted() {
+	r.lastTransfer.Store(time.Now().UnixNano())
+	if r.Blocks != nil {
+		r.Blocks.TransferCommitted()
+	}
 }
 
 // Transfer is the resolver for the transfer field.
 func (r *Resolver) Transfer(ctx context.Context, fromAddress string, toAddress string, amount int) (*models.Wallet, error) {
-	if amount <= 0 {
-		return nil, errors.New("amount must be positive")
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "transfer",
+		attribute.String("transfer.from", fromAddress),
+		attribute.String("transfer.to", toAddress),
+		attribute.Int("transfer.amount", amount),
+	)
+	wallet, err := r.transfer(ctx, fromAddress, toAddress, amount)
+	outcome := transferOutcome(err)
+	span.SetAttributes(attribute.String("transfer.outcome", outcome))
+	tracing.End(span, err)
+	latency := time.Since(start)
+	metrics.ObserveTransfer(outcome, latency)
+	logTransfer(ctx, "transfer", fromAddress, toAddress, amount, outcome, latency, err)
+	return wallet, err
+}
+
+// transfer executes a transfer requested through the transfer field.
+func (r *Resolver) transfer(ctx context.Context, fromAddress string, toAddress string, amount int) (*models.Wallet, error) {
+	if err := validateTransfer(fromAddress, toAddress, amount); err != nil {
+		return nil, err
+	}
+
+	var fromWallet *models.Wallet
+	var err error
+	// Transfers of hot wallets bypass the engine: they lock shards, which a batch cannot
+	// lock in address order along with the wallet rows it locks up front.
+	if r.Engine != nil && !r.isHot(fromAddress) && !r.isHot(toAddress) {
+		fromWallet, err = r.Engine.Submit(ctx, engine.Request{FromAddress: fromAddress, ToAddress: toAddress, Amount: amount})
+	} else {
+		err = r.Store.Transaction(ctx, func(tx store.Tx) error {
+			var err error
+			fromWallet, _, err = r.applyTransfer(ctx, tx, fromAddress, toAddress, amount, transferOptions{})
+			return err
+		})
+	}
+	if err != nil {
+		var flagged *reviewRequiredError
+		if errors.As(err, &flagged) {
+			review, err := r.queueReview(ctx, fromAddress, toAddress, amount, flagged.reasons)
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: queued as review %s", errReviewRequired, review.ID)
+		}
+		return nil, err
+	}
+	r.transferCommitted()
+
+	return fromWallet, nil
+}
+
+// SetWalletLimits is the resolver for the setWalletLimits field.
+func (r *Resolver) SetWalletLimits(ctx context.Context, address string, limits gqlmodels.WalletLimitsInput) (*models.WalletLimits, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if r.isHot(address) {
+		return nil, errHotWalletLimited
+	}
+
+	walletLimits := models.WalletLimits{
+		Address:               address,
+		MaxSingleTransfer:     limits.MaxSingleTransfer,
+		DailyOutbound:         limits.DailyOutbound,
+		MonthlyOutbound:       limits.MonthlyOutbound,
+		MaxTransfersPerWindow: limits.MaxTransfersPerWindow,
+		WindowMinutes:         limits.WindowMinutes,
+	}
+	if err := r.Store.SetWalletLimits(ctx, walletLimits); err != nil {
+		return nil, err
 	}
+	return &walletLimits, nil
+}
 
-	if fromAddress == toAddress {
-		return nil, errors.New("cannot transfer to self")
+// VerifyWallet is the resolver for the verifyWallet field.
+func (r *Resolver) VerifyWallet(ctx context.Context, address string) (*models.Wallet, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
 	}
 
-	// Determine lock order (always lock the "lower" address first)
-	firstToLock, secondToLock := fromAddress, toAddress
-	if fromAddress > toAddress {
-		firstToLock, secondToLock = toAddress, fromAddress
+	wallet, err := r.Store.VerifyWallet(ctx, address)
+	if err != nil {
+		return nil, err
 	}
+	return r.withShards(ctx, r.Store, wallet)
+}
 
-	db := r.DB.WithContext(ctx)
+// ApproveTransferReview is the resolver for the approveTransferReview field. It executes
+// the held transfer, skipping the rules that flagged it, and marks the review approved.
+func (r *Resolver) ApproveTransferReview(ctx context.Context, id string) (*models.TransferReview, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
 
-	tx := db.Session(&gorm.Session{
-		SkipDefaultTransaction: true,
-		PrepareStmt:            true,
-	}).Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	start := time.Now()
+	var review *models.TransferReview
+	err := r.Store.Transaction(ctx, func(tx store.Tx) error {
+		var err error
+		review, err = tx.LockPendingReview(ctx, id)
+		if err != nil {
+			return err
 		}
-	}()
-
-	// Lock first wallet
-	var firstWallet models.Wallet
-	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("address = ?", firstToLock).
-		First(&firstWallet).Error; err != nil {
-		tx.Rollback()
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			if firstToLock == fromAddress {
-				return nil, errors.New("sender wallet not found")
-			}
-			return nil, errors.New("receiver wallet not found")
+
+		if _, _, err := r.applyTransfer(ctx, tx, review.FromAddress, review.ToAddress, review.Amount, transferOptions{reviewed: true}); err != nil {
+			logTransfer(ctx, "review", review.FromAddress, review.ToAddress, review.Amount, transferOutcome(err), time.Since(start), err)
+			return err
 		}
+
+		review.Decide(models.ReviewApproved, "")
+		return tx.SaveReview(ctx, review)
+	})
+	if err != nil {
 		return nil, err
 	}
+	r.transferCommitted()
+	logTransfer(ctx, "review", review.FromAddress, review.ToAddress, review.Amount, transferOutcome(nil), time.Since(start), nil)
 
-	// Lock second wallet
-	var secondWallet models.Wallet
-	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("address = ?", secondToLock).
-		First(&secondWallet).Error; err != nil {
-		tx.Rollback()
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			if secondToLock == fromAddress {
-				return nil, errors.New("sender wallet not found")
-			}
-			return nil, errors.New("receiver wallet not found")
+	return review, nil
+}
+
+// RejectTransferReview is the resolver for the rejectTransferReview field.
+func (r *Resolver) RejectTransferReview(ctx context.Context, id string, note *string) (*models.TransferReview, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	reason := ""
+	if note != nil {
+		reason = *note
+	}
+
+	var review *models.TransferReview
+	err := r.Store.Transaction(ctx, func(tx store.Tx) error {
+		var err error
+		review, err = tx.LockPendingReview(ctx, id)
+		if err != nil {
+			return err
 		}
+		review.Decide(models.ReviewRejected, reason)
+		return tx.SaveReview(ctx, review)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// CreateMultisigWallet is the resolver for the createMultisigWallet field. It is an
+// admin mutation, and refuses wallets that already are multisig wallets: only their
+// signers can change their signers, see ProposeSignerChange.
+func (r *Resolver) CreateMultisigWallet(ctx context.Context, address string, signers []*gqlmodels.MultisigSignerInput, threshold int) (*models.MultisigWallet, error) {
+	if err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	keys, err := signerKeys(signers)
+	if err != nil {
+		return nil, err
+	}
+	return r.Store.CreateMultisigWallet(ctx, address, keys, threshold)
+}
+
+// ProposeTransfer is the resolver for the proposeTransfer field. The signature must be
+// made by signer over models.ProposalMessage; it does not count as an approval.
+func (r *Resolver) ProposeTransfer(ctx context.Context, fromAddress string, toAddress string, amount int, signer string, signature string) (*models.TransferProposal, error) {
+	if err := validateTransfer(fromAddress, toAddress, amount); err != nil {
 		return nil, err
 	}
 
-	// Determine which wallet is sender and which is receiver
+	multisig, err := r.Store.MultisigWallet(ctx, fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	if multisig == nil {
+		return nil, errNotMultisig
+	}
+
+	key, err := r.Store.Signer(ctx, fromAddress, signer)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.VerifySignature(models.ProposalMessage(fromAddress, toAddress, amount), signature); err != nil {
+		return nil, err
+	}
+
+	proposal := models.TransferProposal{
+		FromAddress: fromAddress,
+		ToAddress:   toAddress,
+		Amount:      amount,
+		Proposer:    signer,
+		Status:      models.ProposalPending,
+		ExpiresAt:   time.Now().Add(r.proposalTTL()),
+	}
+	if err := r.Store.CreateProposal(ctx, &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// ApproveTransferProposal is the resolver for the approveTransferProposal field. The
+// signature must be made by signer over models.ApprovalMessage. The transfer executes
+// as part of the approval that reaches the wallet's threshold.
+func (r *Resolver) ApproveTransferProposal(ctx context.Context, id string, signer string, signature string) (*models.TransferProposal, error) {
+	start := time.Now()
+	now := time.Now()
+	var proposal *models.TransferProposal
+	var executed bool
+	err := r.Store.Transaction(ctx, func(tx store.Tx) error {
+		var err error
+		proposal, err = tx.LockProposal(ctx, id)
+		if err != nil {
+			return err
+		}
+		executed, err = r.approveProposal(ctx, tx, proposal, signer, signature, now)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errProposalExpired) {
+			r.Store.ExpireProposals(ctx, now)
+		}
+		return nil, err
+	}
+	if executed {
+		r.transferCommitted()
+		logTransfer(ctx, "proposal", proposal.FromAddress, proposal.ToAddress, proposal.Amount, transferOutcome(nil), time.Since(start), nil)
+	}
+
+	return proposal, nil
+}
+
+// ProposeSignerChange is the resolver for the proposeSignerChange field. The signature
+// must be made by signer, a current signer of the wallet, over
+// models.MultisigChangeMessage; it does not count as an approval.
+func (r *Resolver) ProposeSignerChange(ctx context.Context, address string, signers []*gqlmodels.MultisigSignerInput, threshold int, signer string, signature string) (*models.MultisigChange, error) {
+	keys, err := signerKeys(signers)
+	if err != nil {
+		return nil, err
+	}
+	change, err := models.NewMultisigChange(address, keys, threshold, signer, time.Now().Add(r.proposalTTL()))
+	if err != nil {
+		return nil, err
+	}
+
+	multisig, err := r.Store.MultisigWallet(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if multisig == nil {
+		return nil, errNotMultisig
+	}
+
+	key, err := r.Store.Signer(ctx, address, signer)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.VerifySignature(models.MultisigChangeMessage(address, keys, threshold), signature); err != nil {
+		return nil, err
+	}
+
+	if err := r.Store.CreateMultisigChange(ctx, change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// ApproveSignerChange is the resolver for the approveSignerChange field. The signature
+// must be made by signer over models.ApprovalMessage. The signers are replaced as part
+// of the approval that reaches the wallet's threshold.
+func (r *Resolver) ApproveSignerChange(ctx context.Context, id string, signer string, signature string) (*models.MultisigChange, error) {
+	// The change is read first only to learn its wallet, whose multisig settings are
+	// locked before the change itself, in the order ReplaceMultisigSigners locks them.
+	change, err := r.Store.LockMultisigChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = r.Store.Transaction(ctx, func(tx store.Tx) error {
+		multisig, err := tx.LockMultisigWallet(ctx, change.Address)
+		if err != nil {
+			return err
+		}
+		if multisig == nil {
+			return errNotMultisig
+		}
+		change, err = tx.LockMultisigChange(ctx, id)
+		if err != nil {
+			return err
+		}
+		return approveSignerChange(ctx, tx, multisig, change, signer, signature, now)
+	})
+	if err != nil {
+		if errors.Is(err, errProposalExpired) {
+			r.Store.ExpireProposals(ctx, now)
+		}
+		return nil, err
+	}
+	return change, nil
+}
+
+// GrantVesting is the resolver for the grantVesting field. It transfers amount to the
+// receiver and locks it there on the given schedule, in one transaction.
+func (r *Resolver) GrantVesting(ctx context.Context, fromAddress string, toAddress string, amount int, startsAt time.Time, cliffAt time.Time, endsAt time.Time) (*models.VestingTranche, error) {
+	tranche := models.VestingTranche{
+		Address:  toAddress,
+		Amount:   amount,
+		StartsAt: startsAt,
+		CliffAt:  cliffAt,
+		EndsAt:   endsAt,
+	}
+	if err := tranche.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateTransfer(fromAddress, toAddress, amount); err != nil {
+		return nil, err
+	}
+
+	err := r.Store.Transaction(ctx, func(tx store.Tx) error {
+		if _, _, err := r.applyTransfer(ctx, tx, fromAddress, toAddress, amount, transferOptions{}); err != nil {
+			return err
+		}
+		return tx.CreateTranche(ctx, &tranche)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.transferCommitted()
+
+	return &tranche, nil
+}
+
+// SimulateTransfer is the resolver for the simulateTransfer field. It runs the same
+// validation, locking and balance logic as Transfer in a transaction that is always
+// rolled back, and reports the outcome instead of returning it as an error.
+func (r *Resolver) SimulateTransfer(ctx context.Context, fromAddress string, toAddress string, amount int) (*gqlmodels.TransferSimulation, error) {
+	// Transfers are free, so the fee is always zero.
+	simulation := &gqlmodels.TransferSimulation{
+		Fee:        0,
+		Violations: []string{},
+	}
+
+	if err := validateTransfer(fromAddress, toAddress, amount); err != nil {
+		simulation.Violations = append(simulation.Violations, err.Error())
+		return simulation, nil
+	}
+
 	var fromWallet, toWallet *models.Wallet
-	if firstToLock == fromAddress {
-		fromWallet, toWallet = &firstWallet, &secondWallet
-	} else {
-		fromWallet, toWallet = &secondWallet, &firstWallet
+	err := r.Store.Transaction(ctx, func(tx store.Tx) error {
+		var err error
+		fromWallet, toWallet, err = r.applyTransfer(ctx, tx, fromAddress, toAddress, amount, transferOptions{})
+		if err != nil {
+			return err
+		}
+		return errSimulated
+	})
+	if !errors.Is(err, errSimulated) {
+		if !isViolation(err) {
+			return nil, err
+		}
+		simulation.Violations = append(simulation.Violations, err.Error())
+		return simulation, nil
 	}
 
-	if fromWallet.Balance < amount {
-		tx.Rollback()
-		return nil, errors.New("insufficient balance")
+	simulation.Ok = true
+	simulation.FromBalance = &fromWallet.Balance
+	simulation.ToBalance = &toWallet.Balance
+	return simulation, nil
+}
+
+// Wallet is the resolver for the wallet field.
+func (r *queryResolver) Wallet(ctx context.Context, address string, asOf *time.Time) (*models.Wallet, error) {
+	wallet, err := r.Store.Wallet(ctx, address)
+	if err != nil {
+		return nil, err
 	}
+	if asOf != nil {
+		return r.walletAsOf(ctx, wallet, *asOf)
+	}
+	return r.withShards(ctx, r.Store, wallet)
+}
 
-	fromWallet.Balance -= amount
-	toWallet.Balance += amount
+// Balances is the resolver for the balances field.
+func (r *queryResolver) Balances(ctx context.Context, asOf time.Time) ([]*models.Wallet, error) {
+	return r.balancesAsOf(ctx, asOf)
+}
 
-	if err := tx.Save(fromWallet).Error; err != nil {
-		tx.Rollback()
+// TransferReviews is the resolver for the transferReviews field.
+func (r *queryResolver) TransferReviews(ctx context.Context, status *string) ([]*models.TransferReview, error) {
+	if err := r.requireAdmin(ctx); err != nil {
 		return nil, err
 	}
-	if err := tx.Save(toWallet).Error; err != nil {
-		tx.Rollback()
+
+	return r.Store.Reviews(ctx, status)
+}
+
+// TransferProposals is the resolver for the transferProposals field.
+func (r *queryResolver) TransferProposals(ctx context.Context, address string, status *string) ([]*models.TransferProposal, error) {
+	return r.Store.Proposals(ctx, address, status)
+}
+
+// SignerChanges is the resolver for the signerChanges field.
+func (r *queryResolver) SignerChanges(ctx context.Context, address string, status *string) ([]*models.MultisigChange, error) {
+	return r.Store.MultisigChanges(ctx, address, status)
+}
+
+// ID is the resolver for the id field.
+func (r *multisigChangeResolver) ID(ctx context.Context, obj *models.MultisigChange) (string, error) {
+	return obj.ID.String(), nil
+}
+
+// Signers is the resolver for the signers field.
+func (r *multisigChangeResolver) Signers(ctx context.Context, obj *models.MultisigChange) ([]string, error) {
+	return obj.SignerNames()
+}
+
+// Approvals is the resolver for the approvals field.
+func (r *multisigChangeResolver) Approvals(ctx context.Context, obj *models.MultisigChange) ([]string, error) {
+	return r.Store.Approvals(ctx, obj.ID)
+}
+
+// Signers is the resolver for the signers field.
+func (r *multisigWalletResolver) Signers(ctx context.Context, obj *models.MultisigWallet) ([]string, error) {
+	return r.Store.Signers(ctx, obj.Address)
+}
+
+// ID is the resolver for the id field.
+func (r *transferProposalResolver) ID(ctx context.Context, obj *models.TransferProposal) (string, error) {
+	return obj.ID.String(), nil
+}
+
+// Approvals is the resolver for the approvals field.
+func (r *transferProposalResolver) Approvals(ctx context.Context, obj *models.TransferProposal) ([]string, error) {
+	return r.Store.Approvals(ctx, obj.ID)
+}
+
+// VestingSchedule is the resolver for the vestingSchedule field.
+func (r *queryResolver) VestingSchedule(ctx context.Context, address string) (*gqlmodels.VestingSchedule, error) {
+	wallet, err := r.Wallet(ctx, address, nil)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	tranches, err := r.Store.VestingTranches(ctx, address)
+	if err != nil {
 		return nil, err
 	}
 
-	return fromWallet, nil
+	now := time.Now()
+	locked := 0
+	for _, tranche := range tranches {
+		locked += tranche.Locked(now)
+	}
+
+	return &gqlmodels.VestingSchedule{
+		Address:          address,
+		Balance:          wallet.Balance,
+		LockedBalance:    locked,
+		SpendableBalance: wallet.Balance - locked,
+		Tranches:         tranches,
+	}, nil
+}
+
+// VerifyAuditChain is the resolver for the verifyAuditChain field.
+func (r *queryResolver) VerifyAuditChain(ctx context.Context) (*gqlmodels.AuditReport, error) {
+	report, err := audit.Verify(ctx, r.Store, r.AuditKey)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]*gqlmodels.AuditProblem, len(report.Problems))
+	for i, problem := range report.Problems {
+		problems[i] = &gqlmodels.AuditProblem{Kind: problem.Kind, Detail: problem.Detail}
+		if problem.Account != "" {
+			problems[i].Account = &problem.Account
+		}
+		if problem.Seq != 0 {
+			seq := int(problem.Seq)
+			problems[i].Seq = &seq
+		}
+	}
+	return &gqlmodels.AuditReport{
+		Ok:                 report.OK(),
+		Records:            report.Records,
+		Checkpoints:        report.Checkpoints,
+		SignaturesVerified: report.SignaturesVerified,
+		Problems:           problems,
+	}, nil
+}
+
+// BalanceRoot is the resolver for the balanceRoot field.
+func (r *queryResolver) BalanceRoot(ctx context.Context) (*models.BalanceRoot, error) {
+	return r.Store.LatestBalanceRoot(ctx)
+}
+
+// BalanceProof is the resolver for the balanceProof field.
+func (r *queryResolver) BalanceProof(ctx context.Context, address string, root *string) (*gqlmodels.BalanceProof, error) {
+	hash := ""
+	if root != nil {
+		hash = *root
+	}
+	found, proof, err := reserves.Prove(ctx, r.Store, address, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	siblings := make([]*gqlmodels.ProofStep, len(proof.Siblings))
+	for i, sibling := range proof.Siblings {
+		siblings[i] = &gqlmodels.ProofStep{Hash: sibling.Hash, Left: sibling.Left}
+	}
+	return &gqlmodels.BalanceProof{
+		Seq:      int(found.Seq),
+		Root:     found.Root,
+		Address:  proof.Address,
+		Balance:  proof.Balance,
+		Siblings: siblings,
+	}, nil
+}
+
+// Block is the resolver for the block field.
+func (r *queryResolver) Block(ctx context.Context, number int) (*models.Block, error) {
+	return r.Store.Block(ctx, int64(number))
+}
+
+// LatestBlock is the resolver for the latestBlock field.
+func (r *queryResolver) LatestBlock(ctx context.Context) (*models.Block, error) {
+	return r.Store.LatestBlock(ctx)
+}
+
+// ReconciliationReports is the resolver for the reconciliationReports field.
+func (r *queryResolver) ReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+	return r.Store.ReconciliationReports(ctx, limit)
+}
+
+// ID is the resolver for the id field.
+func (r *transferReviewResolver) ID(ctx context.Context, obj *models.TransferReview) (string, error) {
+	return obj.ID.String(), nil
+}
+
+// Reasons is the resolver for the reasons field.
+func (r *transferReviewResolver) Reasons(ctx context.Context, obj *models.TransferReview) ([]string, error) {
+	return strings.Split(obj.Reasons, "\n"), nil
+}
+
+// ID is the resolver for the id field.
+func (r *vestingTrancheResolver) ID(ctx context.Context, obj *models.VestingTranche) (string, error) {
+	return obj.ID.String(), nil
+}
+
+// Unlocked is the resolver for the unlocked field.
+func (r *vestingTrancheResolver) Unlocked(ctx context.Context, obj *models.VestingTranche) (int, error) {
+	return obj.Unlocked(time.Now()), nil
+}
+
+// Locked is the resolver for the locked field.
+func (r *vestingTrancheResolver) Locked(ctx context.Context, obj *models.VestingTranche) (int, error) {
+	return obj.Locked(time.Now()), nil
 }
 
 // ID is the resolver for the id field.
@@ -109,11 +639,79 @@ func (r *walletResolver) ID(ctx context.Context, obj *models.Wallet) (string, er
 	return obj.ID.String(), nil
 }
 
+// LockedBalance is the resolver for the lockedBalance field.
+func (r *walletResolver) LockedBalance(ctx context.Context, obj *models.Wallet) (int, error) {
+	return r.lockedBalance(ctx, obj)
+}
+
+// SpendableBalance is the resolver for the spendableBalance field.
+func (r *walletResolver) SpendableBalance(ctx context.Context, obj *models.Wallet) (int, error) {
+	locked, err := r.lockedBalance(ctx, obj)
+	if err != nil {
+		return 0, err
+	}
+	return obj.Balance - locked, nil
+}
+
+// Transfers is the resolver for the transfers field.
+func (r *blockResolver) Transfers(ctx context.Context, obj *models.Block) ([]*models.Transfer, error) {
+	return r.Store.BlockTransfers(ctx, obj.Number)
+}
+
+// ID is the resolver for the id field.
+func (r *blockTransferResolver) ID(ctx context.Context, obj *models.Transfer) (string, error) {
+	return obj.ID.String(), nil
+}
+
+// Block returns generated.BlockResolver implementation.
+func (r *Resolver) Block() generated.BlockResolver { return &blockResolver{r} }
+
+// BlockTransfer returns generated.BlockTransferResolver implementation.
+func (r *Resolver) BlockTransfer() generated.BlockTransferResolver {
+	return &blockTransferResolver{r}
+}
+
+// MultisigChange returns generated.MultisigChangeResolver implementation.
+func (r *Resolver) MultisigChange() generated.MultisigChangeResolver {
+	return &multisigChangeResolver{r}
+}
+
+// MultisigWallet returns generated.MultisigWalletResolver implementation.
+func (r *Resolver) MultisigWallet() generated.MultisigWalletResolver {
+	return &multisigWalletResolver{r}
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
+// Query returns generated.QueryResolver implementation.
+func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
+
+// TransferProposal returns generated.TransferProposalResolver implementation.
+func (r *Resolver) TransferProposal() generated.TransferProposalResolver {
+	return &transferProposalResolver{r}
+}
+
+// TransferReview returns generated.TransferReviewResolver implementation.
+func (r *Resolver) TransferReview() generated.TransferReviewResolver {
+	return &transferReviewResolver{r}
+}
+
+// VestingTranche returns generated.VestingTrancheResolver implementation.
+func (r *Resolver) VestingTranche() generated.VestingTrancheResolver {
+	return &vestingTrancheResolver{r}
+}
+
 // Wallet returns generated.WalletResolver implementation.
 func (r *Resolver) Wallet() generated.WalletResolver { return &walletResolver{r} }
 
+type blockResolver struct{ *Resolver }
+type blockTransferResolver struct{ *Resolver }
+type multisigChangeResolver struct{ *Resolver }
+type multisigWalletResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type transferProposalResolver struct{ *Resolver }
+type transferReviewResolver struct{ *Resolver }
+type vestingTrancheResolver struct{ *Resolver }
 type walletResolver struct{ *Resolver }
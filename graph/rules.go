@@ -0,0 +1,87 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"token-transfer-api/models"
+	"token-transfer-api/rules"
+	"token-transfer-api/store"
+)
+
+var (
+	errTransferDenied = errors.New("transfer denied")
+	errReviewRequired = errors.New("transfer requires review")
+)
+
+// reviewRequiredError is returned by applyTransfer when the rules engine flags a transfer.
+// Transfer queues such transfers for review instead of failing them outright.
+type reviewRequiredError struct {
+	reasons []string
+}
+
+func (e *reviewRequiredError) Error() string {
+	return errReviewRequired.Error() + ": " + strings.Join(e.reasons, "; ")
+}
+
+func (e *reviewRequiredError) Unwrap() error {
+	return errReviewRequired
+}
+
+// transferHistory answers the history lookups of the rules engine from the transfers
+// recorded so far, using the transaction of the transfer being evaluated.
+type transferHistory struct {
+	ctx context.Context
+	tx  store.Tx
+}
+
+func (h transferHistory) RecipientsSince(address string, since time.Time) (int, error) {
+	return h.tx.CountRecipientsSince(h.ctx, address, since)
+}
+
+func (h transferHistory) HasTransferred(fromAddress string, toAddress string) (bool, error) {
+	return h.tx.HasTransferred(h.ctx, fromAddress, toAddress)
+}
+
+// evaluateRules runs the configured rules against a transfer. The sender must already be
+// locked so that the history the rules see cannot change before tx ends.
+func (r *Resolver) evaluateRules(ctx context.Context, tx store.Tx, fromAddress string, toAddress string, amount int, now time.Time) error {
+	if r.Rules == nil {
+		return nil
+	}
+
+	result, err := r.Rules.Evaluate(rules.Transfer{
+		FromAddress: fromAddress,
+		ToAddress:   toAddress,
+		Amount:      amount,
+		At:          now,
+	}, transferHistory{ctx: ctx, tx: tx})
+	if err != nil {
+		return err
+	}
+
+	switch result.Decision {
+	case rules.Deny:
+		return fmt.Errorf("%w: %s", errTransferDenied, strings.Join(result.Reasons, "; "))
+	case rules.Review:
+		return &reviewRequiredError{reasons: result.Reasons}
+	}
+	return nil
+}
+
+// queueReview stores a flagged transfer in the review queue.
+func (r *Resolver) queueReview(ctx context.Context, fromAddress string, toAddress string, amount int, reasons []string) (*models.TransferReview, error) {
+	review := models.TransferReview{
+		FromAddress: fromAddress,
+		ToAddress:   toAddress,
+		Amount:      amount,
+		Reasons:     strings.Join(reasons, "\n"),
+		Status:      models.ReviewPending,
+	}
+	if err := r.Store.CreateReview(ctx, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
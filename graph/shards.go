@@ -0,0 +1,369 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"time"
+	"token-transfer-api/metrics"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+	"token-transfer-api/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var errHotWalletLimited = errors.New("hot wallets cannot have spending limits")
+
+// errShardDrained rolls back the lock of a shard that no longer holds what a transfer
+// needs, see lockHolding.
+var errShardDrained = errors.New("shard drained")
+
+// isHot reports whether address is a hot wallet whose balance is kept in shards.
+func (r *Resolver) isHot(address string) bool {
+	return slices.Contains(r.HotWallets, address)
+}
+
+// holding is the part of a wallet's balance a transfer has locked: the wallet row for an
+// ordinary wallet, or one or more shards for a hot wallet.
+type holding struct {
+	wallet *models.Wallet
+	shards []*models.WalletShard
+	// unlocked is the balance of a hot wallet outside the locked shards.
+	unlocked int
+}
+
+func (h *holding) sharded() bool {
+	return h.shards != nil
+}
+
+// balance returns the amount the transfer can spend.
+func (h *holding) balance() int {
+	if !h.sharded() {
+		return h.wallet.Balance
+	}
+	balance := 0
+	for _, shard := range h.shards {
+		balance += shard.Balance
+	}
+	return balance
+}
+
+// total returns the balance of the whole wallet.
+func (h *holding) total() int {
+	return h.balance() + h.unlocked
+}
+
+func (h *holding) credit(amount int) {
+	if !h.sharded() {
+		h.wallet.Balance += amount
+		return
+	}
+	h.shards[0].Balance += amount
+}
+
+// debit takes amount from the locked shards in order; the caller has checked the balance.
+func (h *holding) debit(amount int) {
+	if !h.sharded() {
+		h.wallet.Balance -= amount
+		return
+	}
+	for _, shard := range h.shards {
+		taken := min(shard.Balance, amount)
+		shard.Balance -= taken
+		amount -= taken
+	}
+}
+
+// save persists the locked rows and returns the wallet with its resulting balance. The
+// row of a hot wallet is not locked and is left alone.
+func (h *holding) save(ctx context.Context, tx store.Tx) (*models.Wallet, error) {
+	if !h.sharded() {
+		return h.wallet, saveWallet(ctx, tx, h.wallet)
+	}
+	for _, shard := range h.shards {
+		if err := saveShard(ctx, tx, shard); err != nil {
+			return nil, err
+		}
+	}
+	h.wallet.Balance = h.total()
+	return h.wallet, nil
+}
+
+// auditRecords returns the audit records of the rows save writes, with their resulting
+// balances.
+func (h *holding) auditRecords(operation string, transferID *uuid.UUID) []*models.AuditRecord {
+	if !h.sharded() {
+		return []*models.AuditRecord{{
+			Account:    models.WalletAccount(h.wallet.Address),
+			Address:    h.wallet.Address,
+			Operation:  operation,
+			TransferID: transferID,
+			Balance:    h.wallet.Balance,
+		}}
+	}
+	records := make([]*models.AuditRecord, 0, len(h.shards))
+	for _, shard := range h.shards {
+		records = append(records, &models.AuditRecord{
+			Account:    models.ShardAccount(shard.Address, shard.Shard),
+			Address:    shard.Address,
+			Operation:  operation,
+			TransferID: transferID,
+			Balance:    shard.Balance,
+		})
+	}
+	return records
+}
+
+// lockHolding locks the balance of address a transfer needs at least need of: the wallet
+// row, or for a hot wallet a random shard holding need. If no single shard does, every
+// shard is locked. notFound is returned when no such wallet exists.
+func (r *Resolver) lockHolding(ctx context.Context, tx store.Tx, address string, need int, notFound error) (*holding, error) {
+	if !r.isHot(address) {
+		wallet, err := lockWallet(ctx, tx, address, notFound)
+		if err != nil {
+			return nil, err
+		}
+		return &holding{wallet: wallet}, nil
+	}
+
+	wallet, err := tx.Wallet(ctx, address)
+	if errors.Is(err, models.ErrWalletNotFound) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	shards, err := tx.WalletShards(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if len(shards) == 0 {
+		// Not sharded yet; the rebalancer has not run since the wallet became hot.
+		wallet, err := lockWallet(ctx, tx, address, notFound)
+		if err != nil {
+			return nil, err
+		}
+		return &holding{wallet: wallet}, nil
+	}
+
+	total := wallet.Balance
+	var candidates []*models.WalletShard
+	for _, shard := range shards {
+		total += shard.Balance
+		if shard.Balance >= need {
+			candidates = append(candidates, shard)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	for _, candidate := range candidates {
+		// Each candidate is locked in a savepoint, so that rolling it back releases a
+		// shard that was drained in the meantime: holding it while locking another shard
+		// would not be in index order, and could deadlock.
+		var locked *models.WalletShard
+		err := tx.Transaction(ctx, func(tx store.Tx) error {
+			shard, err := lockShard(ctx, tx, address, candidate.Shard)
+			if err != nil {
+				return err
+			}
+			if shard.Balance < need {
+				return errShardDrained
+			}
+			locked = shard
+			return nil
+		})
+		if errors.Is(err, models.ErrShardNotFound) || errors.Is(err, errShardDrained) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &holding{wallet: wallet, shards: []*models.WalletShard{locked}, unlocked: total - candidate.Balance}, nil
+	}
+
+	// No shard holds enough on its own: lock them all, in index order like any other
+	// transaction locking several shards. No candidate is held at this point.
+	var locked []*models.WalletShard
+	for _, shard := range shards {
+		shard, err := lockShard(ctx, tx, address, shard.Shard)
+		if errors.Is(err, models.ErrShardNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		locked = append(locked, shard)
+	}
+	if len(locked) == 0 {
+		// The wallet was unsharded in the meantime.
+		wallet, err := lockWallet(ctx, tx, address, notFound)
+		if err != nil {
+			return nil, err
+		}
+		return &holding{wallet: wallet}, nil
+	}
+	return &holding{wallet: wallet, shards: locked, unlocked: wallet.Balance}, nil
+}
+
+// lockShard locks one shard of a hot wallet inside tx.
+func lockShard(ctx context.Context, tx store.Tx, address string, index int) (shard *models.WalletShard, err error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "transfer.lock_shard",
+		attribute.String("wallet.address", address),
+		attribute.Int("wallet.shard", index),
+	)
+	defer func() {
+		metrics.LockWait.Observe(time.Since(start).Seconds())
+		tracing.End(span, err)
+	}()
+
+	return tx.LockWalletShard(ctx, address, index)
+}
+
+// saveShard persists a shard's new balance inside tx.
+func saveShard(ctx context.Context, tx store.Tx, shard *models.WalletShard) (err error) {
+	ctx, span := tracing.Start(ctx, "transfer.save_shard",
+		attribute.String("wallet.address", shard.Address),
+		attribute.Int("wallet.shard", shard.Shard),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return tx.SaveWalletShard(ctx, shard)
+}
+
+// withShards adds the balance held in shards to a hot wallet read outside a transfer.
+func (r *Resolver) withShards(ctx context.Context, tx store.Tx, wallet *models.Wallet) (*models.Wallet, error) {
+	if !r.isHot(wallet.Address) {
+		return wallet, nil
+	}
+	shards, err := tx.WalletShards(ctx, wallet.Address)
+	if err != nil {
+		return nil, err
+	}
+	for _, shard := range shards {
+		wallet.Balance += shard.Balance
+	}
+	return wallet, nil
+}
+
+// RebalanceHotWallets splits the balance of every hot wallet evenly across
+// HotWalletShards shards, and moves the shards of wallets that are no longer hot back
+// into their wallet row. It runs at startup and periodically, so shards drained by
+// payouts are topped up from the others.
+func (r *Resolver) RebalanceHotWallets(ctx context.Context) error {
+	sharded, err := r.Store.ShardedWallets(ctx)
+	if err != nil {
+		return err
+	}
+	for _, address := range sharded {
+		if !r.isHot(address) {
+			if err := r.rebalanceShards(ctx, address, 0); err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "unsharded wallet", "address", address)
+		}
+	}
+	for _, address := range r.HotWallets {
+		err := r.rebalanceShards(ctx, address, r.HotWalletShards)
+		if errors.Is(err, models.ErrWalletNotFound) {
+			slog.WarnContext(ctx, "hot wallet does not exist", "address", address)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requireUnlimited fails if limits apply to a hot wallet, see limitsFor. Transfers from
+// hot wallets do not track spend, as updating the spend counters of the wallet on every
+// transfer would serialize them again, so they could not enforce the limits.
+func (r *Resolver) requireUnlimited(ctx context.Context, tx store.Tx, wallet *models.Wallet) error {
+	limits, err := r.limitsFor(ctx, tx, wallet)
+	if err != nil {
+		return err
+	}
+	if limits != nil {
+		return fmt.Errorf("%w: %s", errHotWalletLimited, wallet.Address)
+	}
+	return nil
+}
+
+// rebalanceShards spreads the balance of a wallet evenly across count shards, or moves
+// it back into the wallet row if count is zero. It locks the wallet row and then every
+// shard in index order, so it waits for the transfers holding a shard. A wallet with
+// limits is not sharded, see requireUnlimited.
+func (r *Resolver) rebalanceShards(ctx context.Context, address string, count int) error {
+	return r.Store.Transaction(ctx, func(tx store.Tx) error {
+		wallet, err := tx.LockWallet(ctx, address)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			if err := r.requireUnlimited(ctx, tx, wallet); err != nil {
+				return err
+			}
+		}
+		shards, err := tx.WalletShards(ctx, address)
+		if err != nil {
+			return err
+		}
+
+		total := wallet.Balance
+		current := make([]int, 0, len(shards))
+		for _, shard := range shards {
+			locked, err := tx.LockWalletShard(ctx, address, shard.Shard)
+			if err != nil {
+				return err
+			}
+			total += locked.Balance
+			current = append(current, locked.Balance)
+		}
+
+		target := make([]int, count)
+		for i := range target {
+			target[i] = total / count
+			if i < total%count {
+				target[i]++
+			}
+		}
+		if wallet.Balance == total-sum(target) && slices.Equal(current, target) {
+			return nil
+		}
+
+		var records []*models.AuditRecord
+		for i, balance := range target {
+			if err := tx.SaveWalletShard(ctx, &models.WalletShard{Address: address, Shard: i, Balance: balance}); err != nil {
+				return err
+			}
+			records = append(records, &models.AuditRecord{Account: models.ShardAccount(address, i), Address: address, Operation: models.AuditRebalance, Balance: balance})
+		}
+		if err := tx.DeleteWalletShards(ctx, address, count); err != nil {
+			return err
+		}
+		for _, shard := range shards {
+			if shard.Shard >= count {
+				records = append(records, &models.AuditRecord{Account: models.ShardAccount(address, shard.Shard), Address: address, Operation: models.AuditRebalance})
+			}
+		}
+		wallet.Balance = total - sum(target)
+		if err := tx.SaveWallet(ctx, wallet); err != nil {
+			return err
+		}
+		records = append(records, &models.AuditRecord{Account: models.WalletAccount(address), Address: address, Operation: models.AuditRebalance, Balance: wallet.Balance})
+		return tx.AppendAuditRecords(ctx, records...)
+	})
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
@@ -0,0 +1,256 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+	"token-transfer-api/metrics"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+	"token-transfer-api/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	errAmountNotPositive   = errors.New("amount must be positive")
+	errSelfTransfer        = errors.New("cannot transfer to self")
+	errSenderNotFound      = errors.New("sender wallet not found")
+	errReceiverNotFound    = errors.New("receiver wallet not found")
+	errInsufficientBalance = errors.New("insufficient balance")
+	errBalanceLocked       = errors.New("insufficient unlocked balance")
+	errWalletFrozen        = errors.New("wallet is frozen")
+
+	// errSimulated rolls back the transaction of a simulation that would succeed.
+	errSimulated = errors.New("simulated transfer")
+)
+
+// violations are the transfer errors caused by the request itself rather than by the
+// database; a simulation reports them instead of failing.
+var violations = []error{
+	errAmountNotPositive,
+	errSelfTransfer,
+	errSenderNotFound,
+	errReceiverNotFound,
+	errInsufficientBalance,
+	errBalanceLocked,
+	errWalletFrozen,
+	errSingleTransferLimit,
+	errDailyLimit,
+	errMonthlyLimit,
+	errTransferCountLimit,
+	errTransferDenied,
+	errReviewRequired,
+	errMultisigSender,
+}
+
+// isViolation reports whether err is one of the violations.
+func isViolation(err error) bool {
+	for _, violation := range violations {
+		if errors.Is(err, violation) {
+			return true
+		}
+	}
+	return false
+}
+
+// transferOutcome maps the result of a transfer to the bounded set of outcome labels
+// used by the transfer metrics.
+func transferOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, errAmountNotPositive):
+		return "invalid_amount"
+	case errors.Is(err, errSelfTransfer):
+		return "self_transfer"
+	case errors.Is(err, errSenderNotFound):
+		return "sender_not_found"
+	case errors.Is(err, errReceiverNotFound):
+		return "receiver_not_found"
+	case errors.Is(err, errInsufficientBalance):
+		return "insufficient_balance"
+	case errors.Is(err, errBalanceLocked):
+		return "balance_locked"
+	case errors.Is(err, errWalletFrozen):
+		return "frozen"
+	case errors.Is(err, errSingleTransferLimit), errors.Is(err, errDailyLimit),
+		errors.Is(err, errMonthlyLimit), errors.Is(err, errTransferCountLimit):
+		return "limit_exceeded"
+	case errors.Is(err, errTransferDenied):
+		return "denied"
+	case errors.Is(err, errReviewRequired):
+		return "review_required"
+	case errors.Is(err, errMultisigSender):
+		return "multisig_required"
+	}
+	return "error"
+}
+
+// logTransfer writes the audit record of a transfer attempt. via names the operation
+// that moved the funds: transfer, review or proposal.
+func logTransfer(ctx context.Context, via string, fromAddress string, toAddress string, amount int, outcome string, latency time.Duration, err error) {
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("via", via),
+		slog.String("from", fromAddress),
+		slog.String("to", toAddress),
+		slog.Int("amount", amount),
+		slog.String("outcome", outcome),
+		slog.Duration("latency", latency),
+	}
+	if err != nil {
+		level = slog.LevelWarn
+		if !isViolation(err) {
+			level = slog.LevelError
+		}
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.LogAttrs(ctx, level, "transfer", attrs...)
+}
+
+// validateTransfer checks the arguments of a transfer before any wallet is touched.
+func validateTransfer(fromAddress string, toAddress string, amount int) error {
+	if amount <= 0 {
+		return errAmountNotPositive
+	}
+
+	if fromAddress == toAddress {
+		return errSelfTransfer
+	}
+
+	return nil
+}
+
+// lockWallet locks the wallet with the given address inside tx.
+// notFound is returned when no such wallet exists.
+func lockWallet(ctx context.Context, tx store.Tx, address string, notFound error) (wallet *models.Wallet, err error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "transfer.lock_wallet", attribute.String("wallet.address", address))
+	defer func() {
+		metrics.LockWait.Observe(time.Since(start).Seconds())
+		tracing.End(span, err)
+	}()
+
+	wallet, err = tx.LockWallet(ctx, address)
+	if errors.Is(err, models.ErrWalletNotFound) {
+		return nil, notFound
+	}
+	return wallet, err
+}
+
+// saveWallet persists wallet's new balance inside tx.
+func saveWallet(ctx context.Context, tx store.Tx, wallet *models.Wallet) (err error) {
+	ctx, span := tracing.Start(ctx, "transfer.save_wallet", attribute.String("wallet.address", wallet.Address))
+	defer func() { tracing.End(span, err) }()
+
+	return tx.SaveWallet(ctx, wallet)
+}
+
+// transferOptions describe how a transfer was authorized.
+type transferOptions struct {
+	// reviewed transfers were approved by an admin and skip the rules engine.
+	reviewed bool
+	// proposal transfers were approved by the signers of a multisig sender.
+	proposal bool
+}
+
+// applyTransfer locks both wallets, or shards of hot wallets, enforces multisig, the
+// sender's limits and the rules engine, moves amount from the sender to the receiver and
+// records the transfer, its wallet events and its audit records inside tx. Committing or
+// rolling back tx is left to the caller.
+func (r *Resolver) applyTransfer(ctx context.Context, tx store.Tx, fromAddress string, toAddress string, amount int, opts transferOptions) (*models.Wallet, *models.Wallet, error) {
+	// Determine lock order (always lock the "lower" address first)
+	firstToLock, secondToLock := fromAddress, toAddress
+	firstNotFound, secondNotFound := errSenderNotFound, errReceiverNotFound
+	if fromAddress > toAddress {
+		firstToLock, secondToLock = toAddress, fromAddress
+		firstNotFound, secondNotFound = errReceiverNotFound, errSenderNotFound
+	}
+
+	firstNeed, secondNeed := amount, 0
+	if firstToLock != fromAddress {
+		firstNeed, secondNeed = 0, amount
+	}
+
+	first, err := r.lockHolding(ctx, tx, firstToLock, firstNeed, firstNotFound)
+	if err != nil {
+		return nil, nil, err
+	}
+	second, err := r.lockHolding(ctx, tx, secondToLock, secondNeed, secondNotFound)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Determine which wallet is sender and which is receiver
+	from, to := first, second
+	if firstToLock != fromAddress {
+		from, to = second, first
+	}
+
+	if from.wallet.Frozen || to.wallet.Frozen {
+		return nil, nil, errWalletFrozen
+	}
+
+	if !opts.proposal {
+		if err := requireSingleSigner(ctx, tx, fromAddress); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if from.balance() < amount {
+		return nil, nil, errInsufficientBalance
+	}
+
+	// Only the unlocked part of the balance can be spent
+	now := time.Now()
+	locked, err := tx.LockedBalance(ctx, fromAddress, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if from.total()-locked < amount {
+		return nil, nil, errBalanceLocked
+	}
+	// Hot wallets cannot enforce limits, so rather than skip them, transfers from a hot
+	// wallet that limits apply to fail.
+	if from.sharded() {
+		err = r.requireUnlimited(ctx, tx, from.wallet)
+	} else {
+		err = r.enforceLimits(ctx, tx, from.wallet, amount, now)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !opts.reviewed {
+		if err := r.evaluateRules(ctx, tx, fromAddress, toAddress, amount, now); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	from.debit(amount)
+	to.credit(amount)
+
+	fromWallet, err := from.save(ctx, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	toWallet, err := to.save(ctx, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	transfer, err := tx.RecordTransfer(ctx, fromAddress, toAddress, amount, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.AppendEvents(ctx, models.TransferEvents(transfer)...); err != nil {
+		return nil, nil, err
+	}
+	records := append(from.auditRecords(models.AuditTransfer, &transfer.ID), to.auditRecords(models.AuditTransfer, &transfer.ID)...)
+	if err := tx.AppendAuditRecords(ctx, records...); err != nil {
+		return nil, nil, err
+	}
+
+	return fromWallet, toWallet, nil
+}
@@ -0,0 +1,168 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+	"token-transfer-api/db/migrations"
+
+	"gorm.io/gorm"
+)
+
+// Checker serves the liveness, readiness and status endpoints.
+type Checker struct {
+	DB       *gorm.DB
+	Migrator *migrations.Migrator
+	// Draining reports whether the server is shutting down.
+	Draining func() bool
+	// LastTransfer returns the time of the last successful transfer, zero if none.
+	LastTransfer func() time.Time
+	Version      string
+	Started      time.Time
+	// Timeout bounds the database checks of a single probe.
+	Timeout time.Duration
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (c *Checker) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 2 * time.Second
+}
+
+// checks runs every readiness check and reports whether all of them passed.
+func (c *Checker) checks(ctx context.Context) (map[string]checkResult, bool) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	results := map[string]checkResult{}
+	ready := true
+	fail := func(name string, err string) {
+		results[name] = checkResult{Status: "fail", Error: err}
+		ready = false
+	}
+
+	if c.Draining != nil && c.Draining() {
+		fail("draining", "server is shutting down")
+	} else {
+		results["draining"] = checkResult{Status: "ok"}
+	}
+
+	sqlDB, err := c.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		fail("database", err.Error())
+	} else {
+		results["database"] = checkResult{Status: "ok"}
+	}
+
+	if c.Migrator != nil {
+		pending, err := c.Migrator.Pending(ctx)
+		switch {
+		case err != nil:
+			fail("migrations", err.Error())
+		case pending > 0:
+			fail("migrations", "migrations pending")
+		default:
+			results["migrations"] = checkResult{Status: "ok"}
+		}
+	}
+
+	return results, ready
+}
+
+// Healthz reports that the process is alive. It does not touch any dependency.
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the server should receive traffic: the database is reachable,
+// all migrations are applied and the server is not draining.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	results, ready := c.checks(r.Context())
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": results})
+}
+
+type poolStats struct {
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDuration       string `json:"waitDuration"`
+	MaxIdleClosed      int64  `json:"maxIdleClosed"`
+	MaxLifetimeClosed  int64  `json:"maxLifetimeClosed"`
+}
+
+type statusResponse struct {
+	Status         string                 `json:"status"`
+	Version        string                 `json:"version"`
+	StartedAt      time.Time              `json:"startedAt"`
+	Uptime         string                 `json:"uptime"`
+	LastTransferAt *time.Time             `json:"lastTransferAt"`
+	DatabasePool   *poolStats             `json:"databasePool,omitempty"`
+	Checks         map[string]checkResult `json:"checks"`
+}
+
+// Status reports build, uptime, connection pool and transfer details along with the
+// readiness checks. It always answers 200 so it can be scraped while unhealthy.
+func (c *Checker) Status(w http.ResponseWriter, r *http.Request) {
+	results, ready := c.checks(r.Context())
+	response := statusResponse{
+		Status:    "ready",
+		Version:   c.Version,
+		StartedAt: c.Started,
+		Uptime:    time.Since(c.Started).Round(time.Second).String(),
+		Checks:    results,
+	}
+	if !ready {
+		response.Status = "not ready"
+	}
+
+	if c.LastTransfer != nil {
+		if last := c.LastTransfer(); !last.IsZero() {
+			response.LastTransferAt = &last
+		}
+	}
+
+	if sqlDB, err := c.DB.DB(); err == nil {
+		stats := sqlDB.Stats()
+		response.DatabasePool = &poolStats{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDuration:       stats.WaitDuration.String(),
+			MaxIdleClosed:      stats.MaxIdleClosed,
+			MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Register mounts the endpoints on mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.Healthz)
+	mux.HandleFunc("/readyz", c.Readyz)
+	mux.HandleFunc("/status", c.Status)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"token-transfer-api/cli"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
+	"token-transfer-api/ledger"
+	"token-transfer-api/models"
+)
+
+// runLedger implements the "ledger rebuild" and "ledger snapshot" commands.
+func runLedger(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: ledger rebuild | snapshot")
+		os.Exit(2)
+	}
+
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+	s := cli.NewStore(cfg.Database, database)
+
+	ctx := context.Background()
+	switch args[0] {
+	case "rebuild":
+		report, err := ledger.Diff(ctx, s)
+		if err != nil {
+			fatal("failed to rebuild wallets", err)
+		}
+		if len(report.Mismatches) > 0 {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ADDRESS\tSOURCE\tSTORED BALANCE\tREBUILT BALANCE\tSTORED VERIFIED\tREBUILT VERIFIED\tSTORED FROZEN\tREBUILT FROZEN")
+			for _, mismatch := range report.Mismatches {
+				storedBalance, storedVerified, storedFrozen := describe(mismatch.Stored)
+				rebuiltBalance, rebuiltVerified, rebuiltFrozen := describe(mismatch.Projected)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", mismatch.Address, mismatch.Source,
+					storedBalance, rebuiltBalance, storedVerified, rebuiltVerified, storedFrozen, rebuiltFrozen)
+			}
+			w.Flush()
+		}
+		fmt.Printf("rebuilt %d wallets from their events, %d mismatches\n", report.Wallets, len(report.Mismatches))
+		if len(report.Mismatches) > 0 {
+			os.Exit(1)
+		}
+	case "snapshot":
+		if err := ledger.SnapshotAll(ctx, s); err != nil {
+			fatal("failed to snapshot wallets", err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown ledger command %q\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// describe formats the balance and the verified and frozen flags of a wallet that may be
+// missing.
+func describe(wallet *models.Wallet) (string, string, string) {
+	if wallet == nil {
+		return "-", "-", "-"
+	}
+	return strconv.Itoa(wallet.Balance), strconv.FormatBool(wallet.Verified), strconv.FormatBool(wallet.Frozen)
+}
@@ -0,0 +1,130 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// Sources of stored state compared by Diff.
+const (
+	// SourceWallet is the wallets table, plus the shards of hot wallets.
+	SourceWallet = "wallet"
+	// SourceSnapshot is the latest snapshot of a wallet.
+	SourceSnapshot = "snapshot"
+)
+
+// Mismatch is stored state of a wallet that differs from the state rebuilt from its
+// events.
+type Mismatch struct {
+	Address string
+	Source  string
+	// Stored is nil when the wallet has no row, Projected when it has no events.
+	Stored    *models.Wallet
+	Projected *models.Wallet
+}
+
+// Report is the outcome of Diff.
+type Report struct {
+	// Wallets is the number of wallets rebuilt.
+	Wallets    int
+	Mismatches []Mismatch
+}
+
+// Diff rebuilds every wallet from its first event and compares the result with the
+// wallets table, and the events up to its latest snapshot with that snapshot. Each
+// wallet is compared in a transaction holding its locks, so transfers in flight do not
+// show up as mismatches.
+func Diff(ctx context.Context, s store.Store) (*Report, error) {
+	wallets, err := s.Wallets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := s.EventAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, wallet := range wallets {
+		addresses = append(addresses, wallet.Address)
+	}
+	slices.Sort(addresses)
+	addresses = slices.Compact(addresses)
+
+	report := &Report{Wallets: len(addresses)}
+	for _, address := range addresses {
+		err := s.Transaction(ctx, func(tx store.Tx) error {
+			mismatches, err := diffWallet(ctx, tx, address)
+			report.Mismatches = append(report.Mismatches, mismatches...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// diffWallet compares the stored state of one wallet with its events inside tx.
+func diffWallet(ctx context.Context, tx store.Tx, address string) ([]Mismatch, error) {
+	stored, shards, err := LockWallet(ctx, tx, address)
+	if errors.Is(err, models.ErrWalletNotFound) {
+		stored = nil
+	} else if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		for _, shard := range shards {
+			stored.Balance += shard.Balance
+		}
+	}
+
+	snapshot, err := tx.LatestSnapshot(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	events, err := tx.WalletEvents(ctx, address, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []Mismatch
+	var projected *models.Wallet
+	for i, event := range events {
+		if projected == nil {
+			projected = &models.Wallet{Address: address}
+		}
+		if err := event.Apply(projected); err != nil {
+			return nil, err
+		}
+		if snapshot != nil && event.Seq <= snapshot.Seq && (i == len(events)-1 || events[i+1].Seq > snapshot.Seq) {
+			if !sameState(snapshot.Wallet(), projected) {
+				mismatches = append(mismatches, Mismatch{Address: address, Source: SourceSnapshot, Stored: snapshot.Wallet(), Projected: copyOf(projected)})
+			}
+			snapshot = nil
+		}
+	}
+	if snapshot != nil {
+		// No event precedes the snapshot.
+		mismatches = append(mismatches, Mismatch{Address: address, Source: SourceSnapshot, Stored: snapshot.Wallet()})
+	}
+	if !sameState(stored, projected) {
+		mismatches = append(mismatches, Mismatch{Address: address, Source: SourceWallet, Stored: stored, Projected: projected})
+	}
+	return mismatches, nil
+}
+
+// sameState reports whether two wallets, either of which may be missing, agree on the
+// state the events record.
+func sameState(a *models.Wallet, b *models.Wallet) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Balance == b.Balance && a.Verified == b.Verified && a.Frozen == b.Frozen
+}
+
+func copyOf(wallet *models.Wallet) *models.Wallet {
+	copied := *wallet
+	return &copied
+}
@@ -0,0 +1,223 @@
+// Package ledger rebuilds wallets from the wallet event stream. The wallets table is a
+// projection of the events: replaying the events of a wallet must give its stored
+// balance, which Diff checks. Snapshots record the projection at a point of the stream,
+// so a wallet can be rebuilt from its latest snapshot instead of its first event.
+package ledger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// Project rebuilds a wallet from its events, starting from its latest snapshot unless
+// fromScratch is set. It returns nil if the wallet has neither events nor snapshots,
+// and the Seq of the last event the result includes.
+func Project(ctx context.Context, tx store.Tx, address string, fromScratch bool) (*models.Wallet, int64, error) {
+	var snapshot *models.WalletSnapshot
+	if !fromScratch {
+		var err error
+		snapshot, err = tx.LatestSnapshot(ctx, address)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return project(ctx, tx, address, snapshot)
+}
+
+// project applies the events of a wallet after snapshot, or all of them if it is nil.
+func project(ctx context.Context, tx store.Tx, address string, snapshot *models.WalletSnapshot) (*models.Wallet, int64, error) {
+	var wallet *models.Wallet
+	var seq int64
+	if snapshot != nil {
+		wallet, seq = snapshot.Wallet(), snapshot.Seq
+	}
+
+	events, err := tx.WalletEvents(ctx, address, seq)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, event := range events {
+		if wallet == nil {
+			wallet = &models.Wallet{Address: address}
+		}
+		if err := event.Apply(wallet); err != nil {
+			return nil, 0, err
+		}
+		seq = event.Seq
+	}
+	return wallet, seq, nil
+}
+
+// ProjectAsOf rebuilds a wallet as it stood at the given time, from its latest snapshot
+// taken by then and the events recorded since up to that time. It returns nil if the
+// wallet did not exist yet.
+func ProjectAsOf(ctx context.Context, tx store.Tx, address string, at time.Time) (*models.Wallet, error) {
+	snapshot, err := tx.SnapshotAsOf(ctx, address, at)
+	if err != nil {
+		return nil, err
+	}
+	var wallet *models.Wallet
+	var seq int64
+	if snapshot != nil {
+		wallet, seq = snapshot.Wallet(), snapshot.Seq
+	}
+
+	events, err := tx.WalletEventsAsOf(ctx, address, seq, at)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if wallet == nil {
+			wallet = &models.Wallet{Address: address}
+		}
+		if err := event.Apply(wallet); err != nil {
+			return nil, err
+		}
+	}
+	return wallet, nil
+}
+
+// ProjectAllAsOf rebuilds the wallets with the given addresses as ProjectAsOf does, with
+// one read of their snapshots and one of their events. Wallets that did not exist yet
+// are left out.
+func ProjectAllAsOf(ctx context.Context, tx store.Tx, addresses []string, at time.Time) (map[string]*models.Wallet, error) {
+	snapshots, err := tx.SnapshotsAsOf(ctx, addresses, at)
+	if err != nil {
+		return nil, err
+	}
+	wallets := make(map[string]*models.Wallet, len(addresses))
+	for _, snapshot := range snapshots {
+		wallets[snapshot.Address] = snapshot.Wallet()
+	}
+
+	events, err := tx.EventsAsOf(ctx, addresses, at)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		wallet := wallets[event.Address]
+		if wallet == nil {
+			wallet = &models.Wallet{Address: event.Address}
+			wallets[event.Address] = wallet
+		}
+		if err := event.Apply(wallet); err != nil {
+			return nil, err
+		}
+	}
+	return wallets, nil
+}
+
+// LockWallet locks the row of a wallet and then its shards in index order, the way
+// rebalancing a hot wallet does. Once it returns, every transaction that changed the
+// wallet has committed its events, and no other can start until tx ends.
+func LockWallet(ctx context.Context, tx store.Tx, address string) (*models.Wallet, []*models.WalletShard, error) {
+	wallet, err := tx.LockWallet(ctx, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	shards, err := tx.WalletShards(ctx, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	locked := make([]*models.WalletShard, 0, len(shards))
+	for _, shard := range shards {
+		shard, err := tx.LockWalletShard(ctx, address, shard.Shard)
+		if errors.Is(err, models.ErrShardNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		locked = append(locked, shard)
+	}
+	return wallet, locked, nil
+}
+
+// Balances returns every wallet with the balance of its shards added, in address order.
+// Read in a transaction started by store.Store.Snapshot, the balances and the transfers
+// recorded are a consistent cut: a transfer is in both of its wallets or in neither.
+func Balances(ctx context.Context, tx store.Tx) ([]*models.Wallet, error) {
+	wallets, err := tx.Wallets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, wallet := range wallets {
+		shards, err := tx.WalletShards(ctx, wallet.Address)
+		if err != nil {
+			return nil, err
+		}
+		for _, shard := range shards {
+			wallet.Balance += shard.Balance
+		}
+	}
+	return wallets, nil
+}
+
+// Snapshot records the projection of a wallet if it has events since its latest
+// snapshot, and returns the new snapshot. It returns nil if there was nothing to record.
+func Snapshot(ctx context.Context, s store.Store, address string) (*models.WalletSnapshot, error) {
+	var snapshot *models.WalletSnapshot
+	err := s.Transaction(ctx, func(tx store.Tx) error {
+		// Events are appended while the wallet or one of its shards is locked, so holding
+		// all of them keeps an event with a lower Seq from committing after the snapshot.
+		if _, _, err := LockWallet(ctx, tx, address); err != nil {
+			return err
+		}
+		latest, err := tx.LatestSnapshot(ctx, address)
+		if err != nil {
+			return err
+		}
+		wallet, seq, err := project(ctx, tx, address, latest)
+		if err != nil {
+			return err
+		}
+		if wallet == nil || (latest != nil && latest.Seq == seq) {
+			return nil
+		}
+
+		snapshot = &models.WalletSnapshot{
+			Address:   address,
+			Seq:       seq,
+			Balance:   wallet.Balance,
+			Verified:  wallet.Verified,
+			Frozen:    wallet.Frozen,
+			CreatedAt: time.Now(),
+		}
+		return tx.SaveSnapshot(ctx, snapshot)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// SnapshotAll snapshots every wallet with events since its latest snapshot. It runs
+// periodically so that rebuilding a wallet only replays recent events.
+func SnapshotAll(ctx context.Context, s store.Store) error {
+	addresses, err := s.EventAddresses(ctx)
+	if err != nil {
+		return err
+	}
+	taken := 0
+	for _, address := range addresses {
+		snapshot, err := Snapshot(ctx, s, address)
+		if errors.Is(err, models.ErrWalletNotFound) {
+			slog.WarnContext(ctx, "wallet with events does not exist", "address", address)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if snapshot != nil {
+			taken++
+		}
+	}
+	if taken > 0 {
+		slog.InfoContext(ctx, "snapshotted wallets", "count", taken)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GORM is a GORM logger writing through slog. Failed statements are logged as errors,
+// statements slower than SlowThreshold as warnings, and others at debug level.
+type GORM struct {
+	// Logger defaults to slog.Default() at the time of logging.
+	Logger        *slog.Logger
+	SlowThreshold time.Duration
+}
+
+var (
+	_ gormlogger.Interface = GORM{}
+	_ gorm.ParamsFilter    = GORM{}
+)
+
+func (l GORM) logger() *slog.Logger {
+	if l.Logger != nil {
+		return l.Logger
+	}
+	return slog.Default()
+}
+
+// LogMode is a no-op: the level is controlled by the slog handler.
+func (l GORM) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l GORM) Info(ctx context.Context, msg string, args ...any) {
+	l.logger().InfoContext(ctx, msg, slog.Any("args", args))
+}
+
+func (l GORM) Warn(ctx context.Context, msg string, args ...any) {
+	l.logger().WarnContext(ctx, msg, slog.Any("args", args))
+}
+
+func (l GORM) Error(ctx context.Context, msg string, args ...any) {
+	l.logger().ErrorContext(ctx, msg, slog.Any("args", args))
+}
+
+// ParamsFilter drops bound values so that statements are logged with their placeholders
+// and amounts, addresses or signatures never reach the log.
+func (l GORM) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return sql, nil
+}
+
+func (l GORM) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	logger := l.logger()
+	elapsed := time.Since(begin)
+
+	level := slog.LevelDebug
+	msg := "query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level, msg = slog.LevelError, "query failed"
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold:
+		level, msg = slog.LevelWarn, "slow query"
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Duration("latency", elapsed),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -1,29 +1,135 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+	"time"
+	"token-transfer-api/audit"
+	"token-transfer-api/cli"
+	"token-transfer-api/config"
 	"token-transfer-api/db"
-	"token-transfer-api/graph"
+	"token-transfer-api/db/migrations"
+	"token-transfer-api/engine"
 	"token-transfer-api/graph/generated"
+	"token-transfer-api/health"
+	"token-transfer-api/ledger"
+	"token-transfer-api/logging"
+	"token-transfer-api/metrics"
 	"token-transfer-api/models"
+	"token-transfer-api/reconcile"
+	"token-transfer-api/reserves"
+	"token-transfer-api/server"
+	"token-transfer-api/tracing"
+	"token-transfer-api/worker"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = ""
+
+// buildVersion returns version, falling back to the VCS revision recorded by the Go toolchain.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "dev"
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 func main() {
-	database, err := db.Connect()
+	started := time.Now()
+
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to connect to the database: %v", err)
+		fatal("failed to load the configuration", err)
+	}
+
+	level, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		fatal("failed to configure logging", err)
+	}
+	slog.SetDefault(logging.New(os.Stdout, level))
+
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "ledger" {
+		runLedger(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "audit" {
+		runAudit(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "reserves" {
+		runReserves(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "reconcile" {
+		runReconcile(cfg, args[1:])
+		return
+	}
+
+	auditKey, _, err := cli.AuditKeys(cfg.Features)
+	if err != nil {
+		fatal("failed to load the audit keys", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+
+	if err := database.Use(tracing.GORM{}); err != nil {
+		fatal("failed to register the tracing plugin", err)
+	}
+
+	if err := db.Migrate(database); err != nil {
+		fatal("failed to migrate the database", err)
+	}
+
+	migrator, err := migrations.New(database)
+	if err != nil {
+		fatal("failed to load migrations", err)
+	}
+
+	// Before anything changes a balance, so that an empty audit log means the rows
+	// predate it.
+	if err := audit.Open(context.Background(), cli.NewStore(cfg.Database, database)); err != nil {
+		fatal("failed to open the audit log", err)
 	}
 
 	defaultAddress := "0x0000"
 	initialBalance := 1000000
 	err = models.InitializeWallet(database, defaultAddress, initialBalance)
 	if err != nil {
-		log.Fatalf("Failed to initialize the default wallet: %v", err)
+		fatal("failed to initialize the default wallet", err)
 	}
 
 	// Uncomment the following lines to initialize an additional wallet
@@ -33,19 +139,105 @@ func main() {
 	// additionalBalance := 0
 	// err = models.InitializeWallet(database, additionalAddress, additionalBalance)
 	// if err != nil {
-	// 	log.Fatalf("Failed to initialize the additional wallet: %v", err)
+	// 	fatal("failed to initialize the additional wallet", err)
 	// }
 
-	resolver := &graph.Resolver{DB: database}
+	resolver, err := cli.NewResolver(cfg, cli.NewStore(cfg.Database, database))
+	if err != nil {
+		fatal("failed to configure the resolver", err)
+	}
+
+	if err := resolver.RebalanceHotWallets(context.Background()); err != nil {
+		fatal("failed to shard hot wallets", err)
+	}
+
+	workers := worker.NewGroup()
+	if cfg.Engine.Enabled {
+		resolver.Engine = engine.New(resolver.Store, cfg.Engine, resolver.BatchExecutor())
+		workers.Go("transfer-engine", resolver.Engine.Run)
+	}
+	if resolver.Blocks != nil {
+		workers.Go("seal-blocks", resolver.Blocks.Run)
+	}
+	workers.Every("expire-proposals", time.Minute, func(ctx context.Context) error {
+		_, err := resolver.Store.ExpireProposals(ctx, time.Now())
+		return err
+	})
+	if len(cfg.Features.HotWallets) > 0 {
+		workers.Every("rebalance-hot-wallets", cfg.Features.HotWalletRebalanceInterval, resolver.RebalanceHotWallets)
+	}
+	workers.Every("snapshot-wallets", cfg.Features.SnapshotInterval, func(ctx context.Context) error {
+		return ledger.SnapshotAll(ctx, resolver.Store)
+	})
+	if auditKey != nil {
+		workers.Every("checkpoint-audit-log", cfg.Features.AuditCheckpointInterval, func(ctx context.Context) error {
+			_, err := audit.Checkpoint(ctx, resolver.Store, auditKey)
+			return err
+		})
+	} else {
+		slog.Warn("audit checkpoints are disabled: AUDIT_KEY is not set")
+	}
+	workers.Every("commit-balance-root", cfg.Features.BalanceRootInterval, func(ctx context.Context) error {
+		_, err := reserves.Commit(ctx, resolver.Store)
+		return err
+	})
+	workers.Every("reconcile-ledger", cfg.Features.ReconciliationInterval, func(ctx context.Context) error {
+		_, err := reconcile.Run(ctx, resolver.Store)
+		return err
+	})
 
 	execSchema := generated.NewExecutableSchema(generated.Config{Resolvers: resolver})
 
 	srv := handler.New(execSchema)
 	srv.AddTransport(transport.POST{})
+	srv.Use(metrics.Tracer{})
+	srv.Use(tracing.GraphQL{})
+
+	if sqlDB, err := database.DB(); err == nil {
+		if err := metrics.RegisterDB(sqlDB); err != nil {
+			fatal("failed to register database metrics", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	if cfg.Features.Playground {
+		mux.Handle("/", playground.Handler("GraphQL Playground", "/query"))
+	}
+	mux.Handle("/query", tracing.Middleware(logging.Middleware(srv)))
+	mux.Handle("/metrics", metrics.Handler())
+
+	httpServer := server.New(cfg.Server, mux)
+
+	checker := &health.Checker{
+		DB:           database,
+		Migrator:     migrator,
+		Draining:     httpServer.Draining,
+		LastTransfer: resolver.LastTransferAt,
+		Version:      buildVersion(),
+		Started:      started,
+	}
+	checker.Register(mux)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	http.Handle("/", playground.Handler("GraphQL Playground", "/query"))
-	http.Handle("/query", srv)
+	if err := httpServer.Run(ctx); err != nil {
+		slog.Error("HTTP server stopped with error", "error", err)
+	}
 
-	log.Println("GraphQL server is running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := workers.Stop(shutdownCtx); err != nil {
+		slog.Error("background workers did not stop in time", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+
+	if sqlDB, err := database.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Error("failed to close the database pool", "error", err)
+		}
+	}
+	slog.Info("server stopped")
 }
@@ -0,0 +1,183 @@
+// Package merkle builds the Merkle tree of wallet balances that proof-of-reserves
+// attestations publish the root of, and checks inclusion proofs against such a root. It
+// depends on the standard library only, so wallet owners can copy it and verify their
+// proof offline:
+//
+//	var proof merkle.Proof
+//	json.Unmarshal(balanceProofJSON, &proof)
+//	ok := merkle.Verify(publishedRoot, &proof)
+//
+// Leaves are the (address, balance) pairs in address order. A leaf hashes to
+// SHA-256(0x00 || len(address) || address || balance) and a node to
+// SHA-256(0x01 || left || right), lengths and balances as 8-byte big-endian integers, so
+// a leaf can never pass for a node. The last node of a level with an odd number of nodes
+// moves up a level unchanged.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// Leaf is the balance of one wallet.
+type Leaf struct {
+	Address string
+	Balance int
+}
+
+// Sibling is a node on the path from a leaf to the root. Left is set when the sibling is
+// the left child, so the node on the path is hashed after it.
+type Sibling struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+// Proof shows that a balance is a leaf of a tree: hashing the leaf with each sibling in
+// turn gives the root.
+type Proof struct {
+	Address  string    `json:"address"`
+	Balance  int       `json:"balance"`
+	Siblings []Sibling `json:"siblings"`
+}
+
+// Tree is a Merkle tree of balances.
+type Tree struct {
+	leaves []Leaf
+	// levels holds the hashes of every level, from the leaves up to the root.
+	levels [][][]byte
+}
+
+// Build returns the tree of leaves, which must have distinct addresses. They are sorted
+// by address, so the root does not depend on their order.
+func Build(leaves []Leaf) *Tree {
+	leaves = slices.Clone(leaves)
+	slices.SortFunc(leaves, func(a, b Leaf) int { return strings.Compare(a.Address, b.Address) })
+
+	hashes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = HashLeaf(leaf.Address, leaf.Balance)
+	}
+	return &Tree{leaves: leaves, levels: levels(hashes)}
+}
+
+// Root returns the hex-encoded root of the tree whose leaves have the given hashes, in
+// that order. It serves trees over other data than balances, whose leaves are hashed
+// with HashData.
+func Root(hashes [][]byte) string {
+	top := levels(hashes)
+	return rootOf(top[len(top)-1])
+}
+
+// levels returns the hashes of every level of the tree over hashes, from the leaves up
+// to the root.
+func levels(hashes [][]byte) [][][]byte {
+	all := [][][]byte{hashes}
+	level := hashes
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, hashNode(level[i], level[i+1]))
+		}
+		all = append(all, next)
+		level = next
+	}
+	return all
+}
+
+// Root returns the hex-encoded root hash. The root of an empty tree is the hash of no
+// input.
+func (t *Tree) Root() string {
+	return rootOf(t.levels[len(t.levels)-1])
+}
+
+func rootOf(top [][]byte) string {
+	if len(top) == 0 {
+		empty := sha256.Sum256(nil)
+		return hex.EncodeToString(empty[:])
+	}
+	return hex.EncodeToString(top[0])
+}
+
+// Len returns the number of leaves.
+func (t *Tree) Len() int {
+	return len(t.leaves)
+}
+
+// Proof returns the inclusion proof of the leaf of address, or false if the tree has
+// none.
+func (t *Tree) Proof(address string) (*Proof, bool) {
+	index, found := slices.BinarySearchFunc(t.leaves, address, func(leaf Leaf, address string) int {
+		return strings.Compare(leaf.Address, address)
+	})
+	if !found {
+		return nil, false
+	}
+
+	proof := &Proof{Address: address, Balance: t.leaves[index].Balance, Siblings: []Sibling{}}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, Sibling{Hash: hex.EncodeToString(level[sibling]), Left: sibling < index})
+		}
+		index /= 2
+	}
+	return proof, true
+}
+
+// Verify reports whether proof leads from its leaf to root, the hex-encoded root hash.
+func Verify(root string, proof *Proof) bool {
+	expected, err := hex.DecodeString(root)
+	if err != nil || proof == nil {
+		return false
+	}
+	hash := HashLeaf(proof.Address, proof.Balance)
+	for _, sibling := range proof.Siblings {
+		other, err := hex.DecodeString(sibling.Hash)
+		if err != nil || len(other) != sha256.Size {
+			return false
+		}
+		if sibling.Left {
+			hash = hashNode(other, hash)
+		} else {
+			hash = hashNode(hash, other)
+		}
+	}
+	return bytes.Equal(hash, expected)
+}
+
+// HashLeaf returns the hash of the leaf of a balance.
+func HashLeaf(address string, balance int) []byte {
+	data := binary.BigEndian.AppendUint64(nil, uint64(len(address)))
+	data = append(data, address...)
+	data = binary.BigEndian.AppendUint64(data, uint64(int64(balance)))
+	return HashData(data)
+}
+
+// HashData returns the hash of a leaf holding data.
+func HashData(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hashNode(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Tracer is a gqlgen handler extension recording operation and root field latency.
+type Tracer struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = Tracer{}
+
+func (Tracer) ExtensionName() string {
+	return "PrometheusMetrics"
+}
+
+func (Tracer) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func status(failed bool) string {
+	if failed {
+		return "error"
+	}
+	return "ok"
+}
+
+func (Tracer) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	start := time.Now()
+	response := next(ctx)
+
+	operationType := "unknown"
+	if graphql.HasOperationContext(ctx) {
+		if op := graphql.GetOperationContext(ctx).Operation; op != nil {
+			operationType = string(op.Operation)
+		}
+	}
+	failed := response == nil || len(response.Errors) > 0
+	GraphQLOperations.WithLabelValues(operationType, status(failed)).Observe(time.Since(start).Seconds())
+	return response
+}
+
+func (Tracer) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || fc.Object != "Query" && fc.Object != "Mutation" {
+		return next(ctx)
+	}
+
+	start := time.Now()
+	result, err := next(ctx)
+	// Field names come from the schema, so they are a bounded label set
+	GraphQLFields.WithLabelValues(fc.Object+"."+fc.Field.Name, status(err != nil)).Observe(time.Since(start).Seconds())
+	return result, err
+}
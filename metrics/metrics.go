@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Labels are limited to fixed sets of values; addresses and other user input must never
+// be used as label values.
+var (
+	Transfers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tta_transfers_total",
+		Help: "Transfer attempts by outcome.",
+	}, []string{"outcome"})
+
+	TransferDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tta_transfer_duration_seconds",
+		Help:    "Time to execute a transfer, including waiting for locks.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"outcome"})
+
+	LockWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tta_wallet_lock_wait_seconds",
+		Help:    "Time spent acquiring a wallet row lock with SELECT ... FOR UPDATE.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+
+	TransactionRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tta_transaction_retries_total",
+		Help: "Transactions run again after a transient failure, by reason.",
+	}, []string{"reason"})
+
+	TransactionRetriesExhausted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tta_transaction_retries_exhausted_total",
+		Help: "Transactions that failed with a transient error after using up their retry attempts or budget, by reason.",
+	}, []string{"reason"})
+
+	EngineBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tta_engine_batch_size",
+		Help:    "Transfers committed together by the transfer engine.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 11),
+	})
+
+	EngineBatchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tta_engine_batch_duration_seconds",
+		Help:    "Time to apply and commit a batch of the transfer engine.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+
+	EngineQueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tta_engine_queue_wait_seconds",
+		Help:    "Time transfers wait in the transfer engine queue before their batch starts.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	})
+
+	ReconciliationRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tta_reconciliation_runs_total",
+		Help: "Reconciliation runs by outcome: ok, mismatch or error.",
+	}, []string{"outcome"})
+
+	ReconciliationDiscrepancies = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tta_reconciliation_discrepancies",
+		Help: "Discrepancies found by the latest reconciliation run, by kind.",
+	}, []string{"kind"})
+
+	GraphQLOperations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tta_graphql_operation_duration_seconds",
+		Help:    "GraphQL operation latency by operation type and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"type", "status"})
+
+	GraphQLFields = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tta_graphql_root_field_duration_seconds",
+		Help:    "Latency of top-level GraphQL query and mutation fields.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"field", "status"})
+)
+
+// ObserveTransfer records the outcome and duration of a transfer attempt.
+func ObserveTransfer(outcome string, duration time.Duration) {
+	Transfers.WithLabelValues(outcome).Inc()
+	TransferDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, "tta"))
+}
+
+// Handler serves the registered metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
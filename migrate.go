@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"token-transfer-api/cli"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
+	"token-transfer-api/db/migrations"
+)
+
+// runMigrate implements the "migrate up", "migrate down [steps]" and "migrate status" commands.
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: migrate up | down [steps|all] | status")
+		os.Exit(2)
+	}
+
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+
+	migrator, err := migrations.New(database)
+	if err != nil {
+		fatal("failed to load migrations", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			fatal("failed to apply migrations", err)
+		}
+		cli.WriteMigrations(os.Stdout, "applied", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = cli.ParseSteps(args[1]); err != nil {
+				fatal("invalid number of steps", err)
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			fatal("failed to revert migrations", err)
+		}
+		cli.WriteMigrations(os.Stdout, "reverted", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fatal("failed to read migration status", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		cli.WriteMigrationStatus(w, statuses)
+		w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n", args[0])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Operations recorded in the audit log.
+const (
+	// AuditCreate records a wallet created with Balance.
+	AuditCreate = "create"
+	// AuditOpen starts the chain of a row that predates the audit log.
+	AuditOpen = "open"
+	// AuditTransfer records a row changed by the transfer TransferID names.
+	AuditTransfer = "transfer"
+	// AuditRebalance records a row changed by moving balance between a hot wallet and
+	// its shards.
+	AuditRebalance = "rebalance"
+	// AuditMint records a row credited with newly minted tokens.
+	AuditMint = "mint"
+)
+
+// AuditRecord is an entry of the tamper-evident audit log. Every row holding balance, a
+// wallet or a shard of a hot wallet, has a chain of records named by its Account. Each
+// change to the row appends a record with the resulting Balance, in the same transaction
+// as the change, whose Hash covers the record and the Hash of the previous record of the
+// chain. Editing or deleting a record therefore breaks the chain, and changing a balance
+// without a record leaves the row disagreeing with the head of its chain.
+type AuditRecord struct {
+	Seq        int64      `gorm:"primaryKey;autoIncrement"`
+	Account    string     `gorm:"not null;index:idx_audit_records_account_seq"`
+	Address    string     `gorm:"not null;index:idx_audit_records_address_seq"`
+	Operation  string     `gorm:"not null"`
+	TransferID *uuid.UUID `gorm:"type:uuid"`
+	Balance    int        `gorm:"not null"`
+	PrevHash   string     `gorm:"not null"`
+	Hash       string     `gorm:"not null"`
+	CreatedAt  time.Time  `gorm:"not null"`
+}
+
+// WalletAccount returns the audit account of the row of a wallet.
+func WalletAccount(address string) string {
+	return address
+}
+
+// ShardAccount returns the audit account of a shard of a hot wallet.
+func ShardAccount(address string, shard int) string {
+	return fmt.Sprintf("%s#%d", address, shard)
+}
+
+// ComputeHash returns the hash of the record: every field but Seq and Hash, including
+// PrevHash.
+func (record *AuditRecord) ComputeHash() string {
+	transferID := ""
+	if record.TransferID != nil {
+		transferID = record.TransferID.String()
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n%s\n%d\n%s\n", record.PrevHash, record.Account, record.Address,
+		record.Operation, transferID, record.Balance, record.CreatedAt.UTC().Format(time.RFC3339Nano))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Seal links record to prev, the hash of the head of its chain, and sets its Hash.
+// CreatedAt is rounded to the precision the database keeps.
+func (record *AuditRecord) Seal(prev string) {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	record.CreatedAt = record.CreatedAt.UTC().Truncate(time.Microsecond)
+	record.PrevHash = prev
+	record.Hash = record.ComputeHash()
+}
+
+// AppendAuditRecords seals records onto the heads of their chains and adds them inside
+// tx. The caller holds the lock on the row of each account.
+func AppendAuditRecords(tx *gorm.DB, records ...*AuditRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	heads := map[string]string{}
+	for _, record := range records {
+		prev, ok := heads[record.Account]
+		if !ok {
+			var head AuditRecord
+			if err := tx.Where("account = ?", record.Account).Order("seq DESC").Limit(1).Find(&head).Error; err != nil {
+				return err
+			}
+			prev = head.Hash
+		}
+		record.Seal(prev)
+		heads[record.Account] = record.Hash
+	}
+	return tx.Create(records).Error
+}
+
+// AuditCheckpoint commits to the heads of the audit chains at a point in time. Its Hash
+// covers the entries and the Hash of the previous checkpoint, and Signature is the
+// ed25519 signature of Hash by the audit key, so the records a checkpoint covers cannot
+// be rewritten without the key.
+type AuditCheckpoint struct {
+	ID int64 `gorm:"primaryKey;autoIncrement"`
+	// PrevHash is unique, so concurrent checkpoints cannot fork the chain.
+	PrevHash  string                 `gorm:"not null;uniqueIndex"`
+	Hash      string                 `gorm:"not null"`
+	Signature string                 `gorm:"not null"`
+	CreatedAt time.Time              `gorm:"not null"`
+	Entries   []AuditCheckpointEntry `gorm:"foreignKey:CheckpointID"`
+}
+
+// AuditCheckpointEntry is the head of one chain a checkpoint covers. A checkpoint only
+// holds the chains that changed since the previous one.
+type AuditCheckpointEntry struct {
+	CheckpointID int64  `gorm:"primaryKey;autoIncrement:false"`
+	Account      string `gorm:"primaryKey"`
+	Seq          int64  `gorm:"not null"`
+	Hash         string `gorm:"not null"`
+}
+
+// ComputeHash returns the hash of the checkpoint: PrevHash, CreatedAt and the entries in
+// account order.
+func (checkpoint *AuditCheckpoint) ComputeHash() string {
+	entries := slices.Clone(checkpoint.Entries)
+	slices.SortFunc(entries, func(a, b AuditCheckpointEntry) int { return strings.Compare(a.Account, b.Account) })
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", checkpoint.PrevHash, checkpoint.CreatedAt.UTC().Format(time.RFC3339Nano))
+	for _, entry := range entries {
+		fmt.Fprintf(h, "%s %d %s\n", entry.Account, entry.Seq, entry.Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrBlockNotFound is returned when no block has the requested number.
+var ErrBlockNotFound = errors.New("block not found")
+
+// PreGenesisBlock is the block number of transfers imported from another ledger. No block
+// has it, so they are never sealed: they happened before the first block of this one.
+const PreGenesisBlock int64 = 0
+
+// sealBatchSize is how many transfers SaveBlock assigns to a block per statement.
+const sealBatchSize = 500
+
+// Block is a numbered epoch of the ledger: the transfers committed since the previous
+// block. Its header chains to the previous block by ParentHash, which is empty for the
+// first block, and commits to its transfers by TransferRoot and to the balance of every
+// wallet once they are applied by StateRoot. Numbers have no gaps, so a consumer of the
+// blocks can tell when it missed one.
+type Block struct {
+	Number     int64  `gorm:"primaryKey;autoIncrement:false"`
+	ParentHash string `gorm:"not null;uniqueIndex"`
+	Hash       string `gorm:"not null;uniqueIndex"`
+	// TransferRoot is the Merkle root of the transfers in block order; StateRoot that of
+	// the balances, built like a BalanceRoot.
+	TransferRoot  string    `gorm:"not null"`
+	StateRoot     string    `gorm:"not null"`
+	TransferCount int       `gorm:"not null"`
+	CreatedAt     time.Time `gorm:"not null"`
+}
+
+// ComputeHash returns the hash of the header: every field but Hash.
+func (block *Block) ComputeHash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n%s\n%s\n%d\n%s\n", block.Number, block.ParentHash, block.TransferRoot,
+		block.StateRoot, block.TransferCount, block.CreatedAt.UTC().Format(time.RFC3339Nano))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SaveBlock adds block and assigns it the given transfers in batches. It fails if one of
+// them already belongs to a block.
+func SaveBlock(db *gorm.DB, block *Block, transfers []*Transfer) error {
+	ids := make([]uuid.UUID, len(transfers))
+	for i, transfer := range transfers {
+		ids[i] = transfer.ID
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(block).Error; err != nil {
+			return err
+		}
+		for batch := range slices.Chunk(ids, sealBatchSize) {
+			result := tx.Model(&Transfer{}).Where("id IN ? AND block_number IS NULL", batch).Update("block_number", block.Number)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != int64(len(batch)) {
+				return fmt.Errorf("transfers of block %d are already sealed", block.Number)
+			}
+		}
+		for _, transfer := range transfers {
+			transfer.BlockNumber = &block.Number
+		}
+		return nil
+	})
+}
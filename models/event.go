@@ -0,0 +1,126 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Types of wallet events.
+const (
+	// EventWalletCreated opens a wallet with Amount as its balance.
+	EventWalletCreated = "wallet_created"
+	// EventCredited and EventDebited move Amount into and out of a wallet; TransferID
+	// names the transfer that did.
+	EventCredited = "credited"
+	EventDebited  = "debited"
+	// EventVerified marks a wallet verified.
+	EventVerified = "verified"
+	// EventFrozen and EventUnfrozen freeze and unfreeze a wallet.
+	EventFrozen   = "frozen"
+	EventUnfrozen = "unfrozen"
+	// EventMinted adds Amount to a wallet, increasing the supply.
+	EventMinted = "minted"
+)
+
+// WalletEvent is an entry of the append-only stream recording every change to the state
+// of a wallet, in the same transaction as the change. Replaying the events of a wallet
+// in Seq order rebuilds it; see Apply. Moving balance between a hot wallet and its
+// shards is not an event, since the total does not change.
+type WalletEvent struct {
+	Seq        int64      `gorm:"primaryKey;autoIncrement"`
+	Address    string     `gorm:"not null;index:idx_wallet_events_address_seq"`
+	Type       string     `gorm:"not null"`
+	Amount     int        `gorm:"not null;default:0"`
+	TransferID *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt  time.Time  `gorm:"not null"`
+}
+
+// Apply projects event onto wallet, the state rebuilt from the events before it.
+func (event *WalletEvent) Apply(wallet *Wallet) error {
+	switch event.Type {
+	case EventWalletCreated:
+		wallet.Address = event.Address
+		wallet.Balance = event.Amount
+	case EventCredited:
+		wallet.Balance += event.Amount
+	case EventDebited:
+		wallet.Balance -= event.Amount
+	case EventVerified:
+		wallet.Verified = true
+	case EventFrozen:
+		wallet.Frozen = true
+	case EventUnfrozen:
+		wallet.Frozen = false
+	case EventMinted:
+		wallet.Balance += event.Amount
+	default:
+		return fmt.Errorf("event %d: unknown wallet event type %q", event.Seq, event.Type)
+	}
+	return nil
+}
+
+// TransferEvents returns the events recording transfer: the sender debited and the
+// receiver credited its amount.
+func TransferEvents(transfer *Transfer) []*WalletEvent {
+	return []*WalletEvent{
+		{Address: transfer.FromAddress, Type: EventDebited, Amount: transfer.Amount, TransferID: &transfer.ID, CreatedAt: transfer.CreatedAt},
+		{Address: transfer.ToAddress, Type: EventCredited, Amount: transfer.Amount, TransferID: &transfer.ID, CreatedAt: transfer.CreatedAt},
+	}
+}
+
+// FreezeEvent returns the event recording that a wallet was frozen or unfrozen.
+func FreezeEvent(address string, frozen bool) *WalletEvent {
+	event := &WalletEvent{Address: address, Type: EventUnfrozen, CreatedAt: time.Now()}
+	if frozen {
+		event.Type = EventFrozen
+	}
+	return event
+}
+
+// AppendEvents adds events to the stream inside tx, assigning their Seq.
+func AppendEvents(tx *gorm.DB, events ...*WalletEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return tx.Create(events).Error
+}
+
+// EventTotals returns the sum of the amounts of the events of each type, of the wallet
+// with the given address or of all of them if address is nil.
+func EventTotals(tx *gorm.DB, address *string) (map[string]int, error) {
+	var rows []struct {
+		Type   string
+		Amount int
+	}
+	query := tx.Model(&WalletEvent{}).Select("type, COALESCE(SUM(amount), 0) AS amount")
+	if address != nil {
+		query = query.Where("address = ?", *address)
+	}
+	if err := query.Group("type").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	totals := make(map[string]int, len(rows))
+	for _, row := range rows {
+		totals[row.Type] = row.Amount
+	}
+	return totals, nil
+}
+
+// WalletSnapshot is the state of a wallet rebuilt from its events up to and including
+// Seq. Rebuilding starts from the latest snapshot instead of the first event.
+type WalletSnapshot struct {
+	Address   string    `gorm:"primaryKey"`
+	Seq       int64     `gorm:"primaryKey;autoIncrement:false"`
+	Balance   int       `gorm:"not null"`
+	Verified  bool      `gorm:"not null;default:false"`
+	Frozen    bool      `gorm:"not null;default:false"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// Wallet returns the state the snapshot holds.
+func (snapshot *WalletSnapshot) Wallet() *Wallet {
+	return &Wallet{Address: snapshot.Address, Balance: snapshot.Balance, Verified: snapshot.Verified, Frozen: snapshot.Frozen}
+}
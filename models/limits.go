@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WalletLimits caps the outbound activity of a wallet. A zero value in any field means
+// that dimension is unlimited.
+type WalletLimits struct {
+	Address               string `gorm:"primaryKey"`
+	MaxSingleTransfer     int    `gorm:"not null;default:0"`
+	DailyOutbound         int    `gorm:"not null;default:0"`
+	MonthlyOutbound       int    `gorm:"not null;default:0"`
+	MaxTransfersPerWindow int    `gorm:"not null;default:0"`
+	WindowMinutes         int    `gorm:"not null;default:0"`
+}
+
+// SpendCounter accumulates the outbound amount and number of transfers of a wallet
+// for one minute. Rolling windows are evaluated by summing the buckets they cover.
+type SpendCounter struct {
+	Address     string    `gorm:"primaryKey"`
+	BucketStart time.Time `gorm:"primaryKey"`
+	Amount      int       `gorm:"not null;default:0"`
+	Transfers   int       `gorm:"not null;default:0"`
+}
+
+// SpendBucket is the width of a SpendCounter bucket.
+const SpendBucket = time.Minute
+
+// SpendRetention is how long counters are kept; it must cover the longest window.
+const SpendRetention = 31 * 24 * time.Hour
+
+// DefaultUnverifiedLimits are applied to wallets that have not been verified and have
+// no limits of their own.
+var DefaultUnverifiedLimits = WalletLimits{
+	MaxSingleTransfer:     1000,
+	DailyOutbound:         5000,
+	MonthlyOutbound:       50000,
+	MaxTransfersPerWindow: 20,
+	WindowMinutes:         60,
+}
+
+// Unlimited reports whether no dimension is limited.
+func (limits *WalletLimits) Unlimited() bool {
+	return limits.MaxSingleTransfer == 0 && limits.DailyOutbound == 0 && limits.MonthlyOutbound == 0 &&
+		limits.MaxTransfersPerWindow == 0
+}
+
+// Validate rejects negative limits and transfer counts without a window.
+func (limits *WalletLimits) Validate() error {
+	if limits.MaxSingleTransfer < 0 || limits.DailyOutbound < 0 || limits.MonthlyOutbound < 0 ||
+		limits.MaxTransfersPerWindow < 0 || limits.WindowMinutes < 0 {
+		return errors.New("limits cannot be negative")
+	}
+	if limits.MaxTransfersPerWindow > 0 && limits.WindowMinutes == 0 {
+		return errors.New("window is required when limiting the number of transfers")
+	}
+	return nil
+}
+
+// SetWalletLimits creates or replaces the limits of an existing wallet.
+func SetWalletLimits(db *gorm.DB, limits WalletLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+
+	var wallet Wallet
+	if err := db.Where("address = ?", limits.Address).First(&wallet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWalletNotFound
+		}
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&limits).Error
+}
+
+// RecordSpend adds one outbound transfer of amount to the counter bucket containing at.
+func RecordSpend(tx *gorm.DB, address string, amount int, at time.Time) error {
+	counter := SpendCounter{
+		Address:     address,
+		BucketStart: at.UTC().Truncate(SpendBucket),
+		Amount:      amount,
+		Transfers:   1,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "address"}, {Name: "bucket_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"amount":    gorm.Expr("spend_counters.amount + ?", amount),
+			"transfers": gorm.Expr("spend_counters.transfers + 1"),
+		}),
+	}).Create(&counter).Error
+}
+
+// SpentSince returns the outbound amount and number of transfers of a wallet in the
+// buckets starting at or after since.
+func SpentSince(tx *gorm.DB, address string, since time.Time) (amount int, transfers int, err error) {
+	var totals struct {
+		Amount    int
+		Transfers int
+	}
+	err = tx.Model(&SpendCounter{}).
+		Select("COALESCE(SUM(amount), 0) AS amount, COALESCE(SUM(transfers), 0) AS transfers").
+		Where("address = ? AND bucket_start >= ?", address, since.UTC().Truncate(SpendBucket)).
+		Scan(&totals).Error
+	return totals.Amount, totals.Transfers, err
+}
+
+// PruneSpendCounters deletes the counters of a wallet that no window can cover anymore.
+func PruneSpendCounters(tx *gorm.DB, address string, now time.Time) error {
+	return tx.Where("address = ? AND bucket_start < ?", address, now.UTC().Add(-SpendRetention)).
+		Delete(&SpendCounter{}).Error
+}
@@ -0,0 +1,365 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ProposalPending  = "pending"
+	ProposalExecuted = "executed"
+	ProposalExpired  = "expired"
+)
+
+var (
+	ErrProposalNotFound       = errors.New("proposal not found")
+	ErrNotSigner              = errors.New("not a signer of this wallet")
+	ErrMultisigExists         = errors.New("wallet is already a multisig wallet")
+	ErrNotMultisig            = errors.New("not a multisig wallet")
+	ErrMultisigChangeNotFound = errors.New("signer change not found")
+)
+
+// MultisigWallet marks a wallet whose outbound transfers need Threshold of its signers.
+type MultisigWallet struct {
+	Address   string `gorm:"primaryKey"`
+	Threshold int    `gorm:"not null"`
+}
+
+// MultisigSigner is one of the keys allowed to approve transfers from a multisig wallet.
+type MultisigSigner struct {
+	Address   string `gorm:"primaryKey"`
+	Signer    string `gorm:"primaryKey"`
+	PublicKey string `gorm:"not null"`
+}
+
+// TransferProposal is a pending transfer from a multisig wallet.
+type TransferProposal struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;"`
+	FromAddress string    `gorm:"not null;index"`
+	ToAddress   string    `gorm:"not null"`
+	Amount      int       `gorm:"not null"`
+	Proposer    string    `gorm:"not null"`
+	Status      string    `gorm:"not null;default:pending;index"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	CreatedAt   time.Time
+	ExecutedAt  *time.Time
+}
+
+// ProposalApproval is the signature of one signer on a proposal.
+type ProposalApproval struct {
+	ProposalID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Signer     string    `gorm:"primaryKey"`
+	Signature  string    `gorm:"not null"`
+	CreatedAt  time.Time
+}
+
+// MultisigChange is a pending replacement of the signers and threshold of a multisig
+// wallet. Like a transfer proposal, it takes effect once the threshold of the current
+// signers approved it, and its approvals are ProposalApprovals.
+type MultisigChange struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;"`
+	Address string    `gorm:"not null;index"`
+	// Signers is the JSON object mapping the new signer names to their public keys.
+	Signers    string    `gorm:"not null"`
+	Threshold  int       `gorm:"not null"`
+	Proposer   string    `gorm:"not null"`
+	Status     string    `gorm:"not null;default:pending;index"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	CreatedAt  time.Time
+	ExecutedAt *time.Time
+}
+
+func (proposal *TransferProposal) BeforeCreate(tx *gorm.DB) (err error) {
+	proposal.ID = uuid.New()
+	return
+}
+
+func (change *MultisigChange) BeforeCreate(tx *gorm.DB) (err error) {
+	change.ID = uuid.New()
+	return
+}
+
+// NewMultisigChange returns a pending change of the signers of address to signers, which
+// maps signer names to hex encoded ed25519 public keys, and of its threshold.
+func NewMultisigChange(address string, signers map[string]string, threshold int, proposer string, expiresAt time.Time) (*MultisigChange, error) {
+	if err := ValidateMultisig(signers, threshold); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(signers)
+	if err != nil {
+		return nil, err
+	}
+	return &MultisigChange{
+		Address:   address,
+		Signers:   string(encoded),
+		Threshold: threshold,
+		Proposer:  proposer,
+		Status:    ProposalPending,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// SignerKeys returns the new signers of the change, mapped to their public keys.
+func (change *MultisigChange) SignerKeys() (map[string]string, error) {
+	var signers map[string]string
+	if err := json.Unmarshal([]byte(change.Signers), &signers); err != nil {
+		return nil, fmt.Errorf("invalid signers of change %s: %w", change.ID, err)
+	}
+	return signers, nil
+}
+
+// SignerNames returns the names of the new signers of the change in order.
+func (change *MultisigChange) SignerNames() ([]string, error) {
+	signers, err := change.SignerKeys()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(signers))
+	for name := range signers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// ProposalMessage is the message a signer signs to propose a transfer.
+func ProposalMessage(fromAddress string, toAddress string, amount int) []byte {
+	return []byte(fmt.Sprintf("propose %s %s %d", fromAddress, toAddress, amount))
+}
+
+// MultisigChangeMessage is the message a signer signs to propose replacing the signers
+// and threshold of a multisig wallet. Signers are listed by name, in order.
+func MultisigChangeMessage(address string, signers map[string]string, threshold int) []byte {
+	names := make([]string, 0, len(signers))
+	for name := range signers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	entries := make([]string, len(names))
+	for i, name := range names {
+		entries[i] = name + "=" + signers[name]
+	}
+	return []byte(fmt.Sprintf("change signers %s %d %s", address, threshold, strings.Join(entries, ",")))
+}
+
+// ApprovalMessage is the message a signer signs to approve a proposal or a signer change.
+func ApprovalMessage(proposalID string) []byte {
+	return []byte("approve " + proposalID)
+}
+
+// VerifySignature checks a hex encoded ed25519 signature of message by signer.
+func (signer *MultisigSigner) VerifySignature(message []byte, signature string) error {
+	publicKey, err := hex.DecodeString(signer.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid signer public key")
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !ed25519.Verify(publicKey, message, sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// ValidateMultisig checks a threshold against its signers and the signers' public keys.
+func ValidateMultisig(signers map[string]string, threshold int) error {
+	if threshold < 1 || threshold > len(signers) {
+		return errors.New("threshold must be between 1 and the number of signers")
+	}
+	for name, publicKey := range signers {
+		key, err := hex.DecodeString(publicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key for signer %s", name)
+		}
+	}
+	return nil
+}
+
+// CreateMultisigWallet turns an existing wallet into a multisig wallet. signers maps
+// signer names to hex encoded ed25519 public keys. It fails with ErrMultisigExists if the
+// wallet already is one: only its signers can change them, see ReplaceMultisigSigners.
+func CreateMultisigWallet(db *gorm.DB, address string, signers map[string]string, threshold int) (*MultisigWallet, error) {
+	if err := ValidateMultisig(signers, threshold); err != nil {
+		return nil, err
+	}
+
+	multisig := MultisigWallet{Address: address, Threshold: threshold}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Locking the wallet serializes concurrent attempts to make it a multisig wallet.
+		var wallet Wallet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("address = ?", address).First(&wallet).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWalletNotFound
+			}
+			return err
+		}
+		existing, err := FindMultisigWallet(tx, address)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrMultisigExists
+		}
+		if err := tx.Create(&multisig).Error; err != nil {
+			return err
+		}
+		return createSigners(tx, address, signers)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &multisig, nil
+}
+
+// ReplaceMultisigSigners replaces the signers and threshold of a multisig wallet. The
+// pending proposals and signer changes of the wallet expire, as their approvals were
+// given under the old signers.
+func ReplaceMultisigSigners(db *gorm.DB, address string, signers map[string]string, threshold int) (*MultisigWallet, error) {
+	if err := ValidateMultisig(signers, threshold); err != nil {
+		return nil, err
+	}
+
+	var multisig MultisigWallet
+	err := db.Transaction(func(tx *gorm.DB) error {
+		locked, err := LockMultisigWallet(tx, address)
+		if err != nil {
+			return err
+		}
+		if locked == nil {
+			return ErrNotMultisig
+		}
+		multisig = *locked
+		multisig.Threshold = threshold
+		if err := tx.Model(&multisig).Update("threshold", threshold).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("address = ?", address).Delete(&MultisigSigner{}).Error; err != nil {
+			return err
+		}
+		if err := createSigners(tx, address, signers); err != nil {
+			return err
+		}
+		if err := tx.Model(&TransferProposal{}).
+			Where("from_address = ? AND status = ?", address, ProposalPending).
+			Update("status", ProposalExpired).Error; err != nil {
+			return err
+		}
+		return tx.Model(&MultisigChange{}).
+			Where("address = ? AND status = ?", address, ProposalPending).
+			Update("status", ProposalExpired).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &multisig, nil
+}
+
+func createSigners(tx *gorm.DB, address string, signers map[string]string) error {
+	for name, publicKey := range signers {
+		if err := tx.Create(&MultisigSigner{Address: address, Signer: name, PublicKey: publicKey}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindMultisigWallet returns the multisig settings of address, or nil if it is an ordinary wallet.
+func FindMultisigWallet(db *gorm.DB, address string) (*MultisigWallet, error) {
+	var multisig MultisigWallet
+	result := db.Where("address = ?", address).Limit(1).Find(&multisig)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &multisig, nil
+}
+
+// LockMultisigWallet selects the multisig settings of address FOR UPDATE inside tx, or
+// returns nil if it is an ordinary wallet.
+func LockMultisigWallet(tx *gorm.DB, address string) (*MultisigWallet, error) {
+	return FindMultisigWallet(tx.Clauses(clause.Locking{Strength: "UPDATE"}), address)
+}
+
+// FindSigner returns a signer of a multisig wallet.
+func FindSigner(db *gorm.DB, address string, signer string) (*MultisigSigner, error) {
+	var s MultisigSigner
+	if err := db.Where("address = ? AND signer = ?", address, signer).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotSigner
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LockProposal selects a proposal FOR UPDATE inside tx.
+func LockProposal(tx *gorm.DB, id string) (*TransferProposal, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrProposalNotFound
+	}
+
+	var proposal TransferProposal
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		First(&proposal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProposalNotFound
+		}
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// LockMultisigChange selects a signer change FOR UPDATE inside tx.
+func LockMultisigChange(tx *gorm.DB, id string) (*MultisigChange, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrMultisigChangeNotFound
+	}
+
+	var change MultisigChange
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		First(&change).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMultisigChangeNotFound
+		}
+		return nil, err
+	}
+	return &change, nil
+}
+
+// CountApprovals returns the number of signers that approved a proposal.
+func CountApprovals(tx *gorm.DB, proposalID uuid.UUID) (int, error) {
+	var count int64
+	err := tx.Model(&ProposalApproval{}).Where("proposal_id = ?", proposalID).Count(&count).Error
+	return int(count), err
+}
+
+// ExpireProposals marks every pending proposal and signer change past its expiry as
+// expired.
+func ExpireProposals(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Model(&TransferProposal{}).
+		Where("status = ? AND expires_at <= ?", ProposalPending, now).
+		Update("status", ProposalExpired)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	changes := db.Model(&MultisigChange{}).
+		Where("status = ? AND expires_at <= ?", ProposalPending, now).
+		Update("status", ProposalExpired)
+	return result.RowsAffected + changes.RowsAffected, changes.Error
+}
+
+// DefaultProposalTTL is how long a proposal can collect approvals unless configured otherwise.
+const DefaultProposalTTL = 24 * time.Hour
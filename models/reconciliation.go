@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// discrepancyBatchSize is how many discrepancies SaveReconciliationReport inserts per
+// statement.
+const discrepancyBatchSize = 500
+
+// Kinds of reconciliation discrepancies.
+const (
+	// DiscrepancySupply is a sum of balances other than the minted supply minus what was
+	// burned. It names no wallet.
+	DiscrepancySupply = "supply"
+	// DiscrepancyNegativeBalance is a wallet row or shard holding less than nothing.
+	DiscrepancyNegativeBalance = "negative_balance"
+	// DiscrepancyHistory is a wallet whose balance is not its opening balance plus what
+	// was minted to it and what it received, minus what it sent.
+	DiscrepancyHistory = "history"
+)
+
+// ReconciliationReport is the outcome of a reconciliation run, which checks the
+// invariants of the ledger as a whole. OK is set when it found no discrepancy.
+type ReconciliationReport struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement"`
+	StartedAt  time.Time `gorm:"not null"`
+	FinishedAt time.Time `gorm:"not null"`
+	// Wallets is the number of wallets checked. Supply is the sum of their balances,
+	// Minted the balances they were opened with and the tokens minted to them since, and
+	// Burned what transfers debited beyond what they credited.
+	Wallets       int                         `gorm:"not null"`
+	Supply        int                         `gorm:"not null"`
+	Minted        int                         `gorm:"not null"`
+	Burned        int                         `gorm:"not null"`
+	OK            bool                        `gorm:"not null"`
+	Discrepancies []ReconciliationDiscrepancy `gorm:"foreignKey:ReportID"`
+}
+
+// ReconciliationDiscrepancy is a broken invariant found by a reconciliation run. Expected
+// and Actual are the amounts that should have been equal; for a negative balance,
+// Expected is zero.
+type ReconciliationDiscrepancy struct {
+	ReportID int64  `gorm:"primaryKey;autoIncrement:false"`
+	Position int    `gorm:"primaryKey;autoIncrement:false"`
+	Kind     string `gorm:"not null"`
+	Address  string `gorm:"not null;default:''"`
+	Expected int    `gorm:"not null"`
+	Actual   int    `gorm:"not null"`
+	Detail   string `gorm:"not null;default:''"`
+}
+
+// SaveReconciliationReport adds report and then its discrepancies in batches, numbering
+// them in order.
+func SaveReconciliationReport(db *gorm.DB, report *ReconciliationReport) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Discrepancies").Create(report).Error; err != nil {
+			return err
+		}
+		if len(report.Discrepancies) == 0 {
+			return nil
+		}
+		for i := range report.Discrepancies {
+			report.Discrepancies[i].ReportID = report.ID
+			report.Discrepancies[i].Position = i
+		}
+		return tx.CreateInBatches(report.Discrepancies, discrepancyBatchSize).Error
+	})
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// leafBatchSize is how many leaves SaveBalanceRoot inserts per statement.
+const leafBatchSize = 500
+
+// ErrBalanceRootNotFound is returned when no balance root has the requested hash.
+var ErrBalanceRootNotFound = errors.New("balance root not found")
+
+// BalanceRoot is the root of the Merkle tree of every wallet's balance at a point in
+// time, published as a proof-of-reserves attestation. Its leaves are kept so inclusion
+// proofs can be built against it later. See package merkle.
+type BalanceRoot struct {
+	Seq  int64  `gorm:"primaryKey;autoIncrement"`
+	Root string `gorm:"not null;index"`
+	// Wallets is the number of leaves and Total the sum of their balances.
+	Wallets   int           `gorm:"not null"`
+	Total     int           `gorm:"not null"`
+	CreatedAt time.Time     `gorm:"not null"`
+	Leaves    []BalanceLeaf `gorm:"foreignKey:RootSeq"`
+}
+
+// BalanceLeaf is the balance of a wallet, including its shards, under a BalanceRoot.
+type BalanceLeaf struct {
+	RootSeq int64  `gorm:"primaryKey;autoIncrement:false"`
+	Address string `gorm:"primaryKey"`
+	Balance int    `gorm:"not null"`
+}
+
+// TableName overrides the "balance_leafs" GORM would derive.
+func (BalanceLeaf) TableName() string {
+	return "balance_leaves"
+}
+
+// SaveBalanceRoot adds root and then its leaves in batches, so a root over many wallets
+// stays within the limits the database puts on a single statement.
+func SaveBalanceRoot(db *gorm.DB, root *BalanceRoot) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Leaves").Create(root).Error; err != nil {
+			return err
+		}
+		if len(root.Leaves) == 0 {
+			return nil
+		}
+		for i := range root.Leaves {
+			root.Leaves[i].RootSeq = root.Seq
+		}
+		return tx.CreateInBatches(root.Leaves, leafBatchSize).Error
+	})
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+var (
+	ErrReviewNotFound = errors.New("review not found")
+	ErrReviewDecided  = errors.New("review already decided")
+)
+
+// TransferReview is a transfer held back by the rules engine until an admin decides on it.
+type TransferReview struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;"`
+	FromAddress string    `gorm:"not null"`
+	ToAddress   string    `gorm:"not null"`
+	Amount      int       `gorm:"not null"`
+	Reasons     string    `gorm:"not null"`
+	Status      string    `gorm:"not null;default:pending;index"`
+	Note        string
+	CreatedAt   time.Time
+	DecidedAt   *time.Time
+}
+
+func (review *TransferReview) BeforeCreate(tx *gorm.DB) (err error) {
+	review.ID = uuid.New()
+	return
+}
+
+// LockPendingReview selects a pending review FOR UPDATE inside tx.
+func LockPendingReview(tx *gorm.DB, id string) (*TransferReview, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrReviewNotFound
+	}
+
+	var review TransferReview
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		First(&review).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReviewNotFound
+		}
+		return nil, err
+	}
+	if review.Status != ReviewPending {
+		return nil, ErrReviewDecided
+	}
+	return &review, nil
+}
+
+// Decide sets the final status of a review.
+func (review *TransferReview) Decide(status string, note string) {
+	now := time.Now()
+	review.Status = status
+	review.Note = note
+	review.DecidedAt = &now
+}
+
+// DecideReview sets the final status of a review inside tx.
+func DecideReview(tx *gorm.DB, review *TransferReview, status string, note string) error {
+	review.Decide(status, note)
+	return tx.Save(review).Error
+}
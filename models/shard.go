@@ -0,0 +1,15 @@
+package models
+
+import "errors"
+
+// ErrShardNotFound is returned when a hot wallet has no shard with the requested index.
+var ErrShardNotFound = errors.New("wallet shard not found")
+
+// WalletShard holds part of the balance of a hot wallet. Transfers from a hot wallet
+// lock a single shard instead of the wallet row, so they do not all wait for the same
+// lock. The balance of a sharded wallet is its own balance plus that of its shards.
+type WalletShard struct {
+	Address string `gorm:"primaryKey"`
+	Shard   int    `gorm:"primaryKey;autoIncrement:false"`
+	Balance int    `gorm:"not null;default:0"`
+}
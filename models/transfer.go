@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Transfer records a committed transfer between two wallets.
+type Transfer struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;"`
+	FromAddress string    `gorm:"not null;index:idx_transfers_from_to;index:idx_transfers_from_created"`
+	ToAddress   string    `gorm:"not null;index:idx_transfers_from_to;index:idx_transfers_to_created"`
+	Amount      int       `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"not null;index:idx_transfers_from_created;index:idx_transfers_to_created"`
+	// BlockNumber is the block the transfer was sealed into, nil until it is, or
+	// PreGenesisBlock for imported transfers.
+	BlockNumber *int64 `gorm:"index"`
+}
+
+// transferBatchSize is how many transfers ImportTransfers inserts per statement.
+const transferBatchSize = 500
+
+// BeforeCreate assigns the transfer an ID, unless it was imported with one.
+func (transfer *Transfer) BeforeCreate(tx *gorm.DB) (err error) {
+	if transfer.ID == uuid.Nil {
+		transfer.ID = uuid.New()
+	}
+	return
+}
+
+// RecordTransfer appends a transfer to the history inside tx.
+func RecordTransfer(tx *gorm.DB, fromAddress string, toAddress string, amount int, at time.Time) (*Transfer, error) {
+	transfer := Transfer{
+		FromAddress: fromAddress,
+		ToAddress:   toAddress,
+		Amount:      amount,
+		CreatedAt:   at,
+	}
+	if err := tx.Create(&transfer).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// ImportTransfers adds transfers in batches as they are, keeping their IDs and times. They
+// are marked as PreGenesisBlock, so no block seals them.
+func ImportTransfers(tx *gorm.DB, transfers []*Transfer) error {
+	for _, transfer := range transfers {
+		number := PreGenesisBlock
+		transfer.BlockNumber = &number
+	}
+	return tx.CreateInBatches(transfers, transferBatchSize).Error
+}
+
+// TransfersAfter returns up to limit transfers ordered by creation time and then ID that
+// follow after, or the first ones if after is nil.
+func TransfersAfter(tx *gorm.DB, after *Transfer, limit int) ([]*Transfer, error) {
+	query := tx.Order("created_at, id").Limit(limit)
+	if after != nil {
+		query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", after.CreatedAt, after.CreatedAt, after.ID)
+	}
+	var transfers []*Transfer
+	err := query.Find(&transfers).Error
+	return transfers, err
+}
+
+// TransferTotals returns the amounts address received and sent in transfers since the
+// given time.
+func TransferTotals(tx *gorm.DB, address string, since time.Time) (received int, sent int, err error) {
+	err = tx.Model(&Transfer{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("to_address = ? AND created_at >= ?", address, since).
+		Scan(&received).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	err = tx.Model(&Transfer{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("from_address = ? AND created_at >= ?", address, since).
+		Scan(&sent).Error
+	return received, sent, err
+}
+
+// CountRecipientsSince returns the number of distinct wallets address sent tokens to since the given time.
+func CountRecipientsSince(tx *gorm.DB, address string, since time.Time) (int, error) {
+	var count int64
+	err := tx.Model(&Transfer{}).
+		Where("from_address = ? AND created_at >= ?", address, since).
+		Distinct("to_address").
+		Count(&count).Error
+	return int(count), err
+}
+
+// HasTransferred reports whether fromAddress ever sent tokens to toAddress.
+func HasTransferred(tx *gorm.DB, fromAddress string, toAddress string) (bool, error) {
+	var count int64
+	err := tx.Model(&Transfer{}).
+		Where("from_address = ? AND to_address = ?", fromAddress, toAddress).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
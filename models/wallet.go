@@ -2,16 +2,26 @@ package models
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrWalletNotFound is returned when no wallet has the requested address.
+var ErrWalletNotFound = errors.New("wallet not found")
+
 type Wallet struct {
-	ID      uuid.UUID `gorm:"type:uuid;primary_key;"`
-	Address string    `gorm:"unique;not null"`
-	Balance int       `gorm:"not null"`
-	Version int       `gorm:"default:1"`
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;"`
+	Address  string    `gorm:"unique;not null"`
+	Balance  int       `gorm:"not null"`
+	Version  int       `gorm:"default:1"`
+	Verified bool      `gorm:"not null;default:false"`
+	// Frozen wallets can neither send nor receive tokens.
+	Frozen bool `gorm:"not null;default:false"`
+	// AsOf is set on wallets rebuilt as they stood at a past time.
+	AsOf *time.Time `gorm:"-"`
 }
 
 func (wallet *Wallet) BeforeCreate(tx *gorm.DB) (err error) {
@@ -19,13 +29,21 @@ func (wallet *Wallet) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
-func InitializeWallet(db *gorm.DB, address string, initialBalance int) error {
+// ValidateWallet checks the address and initial balance of a new wallet.
+func ValidateWallet(address string, initialBalance int) error {
 	if address == "" {
 		return errors.New("address cannot be empty")
 	}
 	if initialBalance < 0 {
 		return errors.New("balance cannot be negative")
 	}
+	return nil
+}
+
+func InitializeWallet(db *gorm.DB, address string, initialBalance int) error {
+	if err := ValidateWallet(address, initialBalance); err != nil {
+		return err
+	}
 
 	var wallet Wallet
 	result := db.Where("address = ?", address).First(&wallet)
@@ -37,7 +55,68 @@ func InitializeWallet(db *gorm.DB, address string, initialBalance int) error {
 			Address: address,
 			Balance: initialBalance,
 		}
-		return db.Create(&wallet).Error
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&wallet).Error; err != nil {
+				return err
+			}
+			if err := AppendAuditRecords(tx, &AuditRecord{Account: WalletAccount(address), Address: address, Operation: AuditCreate, Balance: initialBalance}); err != nil {
+				return err
+			}
+			return AppendEvents(tx, &WalletEvent{Address: address, Type: EventWalletCreated, Amount: initialBalance, CreatedAt: time.Now()})
+		})
 	}
 	return nil
 }
+
+// VerifyWallet marks a wallet as verified, lifting the default limits of unverified wallets.
+func VerifyWallet(db *gorm.DB, address string) (*Wallet, error) {
+	var wallet Wallet
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("address = ?", address).First(&wallet).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWalletNotFound
+			}
+			return err
+		}
+		if wallet.Verified {
+			return nil
+		}
+
+		wallet.Verified = true
+		if err := tx.Model(&wallet).Update("verified", true).Error; err != nil {
+			return err
+		}
+		return AppendEvents(tx, &WalletEvent{Address: address, Type: EventVerified, CreatedAt: time.Now()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// SetWalletFrozen freezes or unfreezes a wallet. It records an EventFrozen or
+// EventUnfrozen event unless the wallet already was in that state.
+func SetWalletFrozen(db *gorm.DB, address string, frozen bool) (*Wallet, error) {
+	var wallet Wallet
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("address = ?", address).First(&wallet).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWalletNotFound
+			}
+			return err
+		}
+		if wallet.Frozen == frozen {
+			return nil
+		}
+
+		wallet.Frozen = frozen
+		if err := tx.Model(&wallet).Update("frozen", frozen).Error; err != nil {
+			return err
+		}
+		return AppendEvents(tx, FreezeEvent(address, frozen))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"token-transfer-api/cli"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
+	"token-transfer-api/reconcile"
+)
+
+// runReconcile implements the "reconcile" command: it reconciles the ledger once, prints
+// the discrepancies and exits with status 1 if there are any.
+func runReconcile(cfg *config.Config, args []string) {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: reconcile")
+		os.Exit(2)
+	}
+
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		fatal("failed to connect to the database", err)
+	}
+	report, err := reconcile.Run(context.Background(), cli.NewStore(cfg.Database, database))
+	if err != nil {
+		fatal("failed to reconcile the ledger", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	cli.WriteReconciliation(w, report)
+	w.Flush()
+	if !report.OK {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,175 @@
+// Package reconcile checks the invariants of the ledger as a whole, which no single
+// transaction can: the balances add up to the supply minted minus what was burned, no
+// balance is negative, and every wallet holds its opening balance plus what was minted to
+// it and what it received, minus what it sent. Run records what it finds as a models.ReconciliationReport.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"token-transfer-api/ledger"
+	"token-transfer-api/metrics"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// Run checks every invariant, then saves the report and returns it. A report with
+// discrepancies is logged as an error and counted as a mismatch by
+// metrics.ReconciliationRuns.
+func Run(ctx context.Context, s store.Store) (*models.ReconciliationReport, error) {
+	report, err := run(ctx, s)
+	if err != nil {
+		metrics.ReconciliationRuns.WithLabelValues("error").Inc()
+		return nil, err
+	}
+
+	counts := map[string]int{models.DiscrepancySupply: 0, models.DiscrepancyNegativeBalance: 0, models.DiscrepancyHistory: 0}
+	for _, discrepancy := range report.Discrepancies {
+		counts[discrepancy.Kind]++
+	}
+	for kind, count := range counts {
+		metrics.ReconciliationDiscrepancies.WithLabelValues(kind).Set(float64(count))
+	}
+	if report.OK {
+		metrics.ReconciliationRuns.WithLabelValues("ok").Inc()
+		slog.InfoContext(ctx, "reconciled ledger", "report", report.ID, "wallets", report.Wallets, "supply", report.Supply)
+		return report, nil
+	}
+	metrics.ReconciliationRuns.WithLabelValues("mismatch").Inc()
+	slog.ErrorContext(ctx, "ledger reconciliation found discrepancies", "report", report.ID, "supply", counts[models.DiscrepancySupply],
+		"negative_balances", counts[models.DiscrepancyNegativeBalance], "history", counts[models.DiscrepancyHistory])
+	return report, nil
+}
+
+func run(ctx context.Context, s store.Store) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{StartedAt: time.Now().UTC().Truncate(time.Microsecond)}
+	if err := checkSupply(ctx, s, report); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.Wallets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, wallet := range wallets {
+		var discrepancies []models.ReconciliationDiscrepancy
+		err := s.Snapshot(ctx, func(tx store.Tx) error {
+			var err error
+			discrepancies, err = checkWallet(ctx, tx, wallet.Address)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancies...)
+	}
+
+	report.FinishedAt = time.Now().UTC().Truncate(time.Microsecond)
+	report.OK = len(report.Discrepancies) == 0
+	if err := s.SaveReconciliationReport(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkSupply sums the balances and the events into report, and adds a discrepancy if
+// they disagree. Both are read from one snapshot, see ledger.Balances, so a transfer or a
+// wallet created in the meantime is counted in both or in neither.
+func checkSupply(ctx context.Context, s store.Store, report *models.ReconciliationReport) error {
+	err := s.Snapshot(ctx, func(tx store.Tx) error {
+		wallets, err := ledger.Balances(ctx, tx)
+		if err != nil {
+			return err
+		}
+		totals, err := tx.EventTotals(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		report.Wallets, report.Supply = len(wallets), 0
+		for _, wallet := range wallets {
+			report.Supply += wallet.Balance
+		}
+		report.Minted = totals[models.EventWalletCreated] + totals[models.EventMinted]
+		report.Burned = totals[models.EventDebited] - totals[models.EventCredited]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if expected := report.Minted - report.Burned; report.Supply != expected {
+		report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+			Kind:     models.DiscrepancySupply,
+			Expected: expected,
+			Actual:   report.Supply,
+		})
+	}
+	return nil
+}
+
+// checkWallet checks the balance of one wallet inside tx, a snapshot, so transfers in
+// flight do not show up as discrepancies. The history starts at the wallet_created
+// event, which for a wallet that predates the event stream holds the balance it had
+// then. Tokens minted to the wallet since are added to it. Transfers are free, so a
+// sender loses exactly what the receiver gets.
+func checkWallet(ctx context.Context, tx store.Tx, address string) ([]models.ReconciliationDiscrepancy, error) {
+	wallet, err := tx.Wallet(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := tx.WalletShards(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	var discrepancies []models.ReconciliationDiscrepancy
+	negative := func(balance int, detail string) {
+		if balance < 0 {
+			discrepancies = append(discrepancies, models.ReconciliationDiscrepancy{
+				Kind:    models.DiscrepancyNegativeBalance,
+				Address: address,
+				Actual:  balance,
+				Detail:  detail,
+			})
+		}
+	}
+	negative(wallet.Balance, "wallet")
+	balance := wallet.Balance
+	for _, shard := range shards {
+		negative(shard.Balance, fmt.Sprintf("shard %d", shard.Shard))
+		balance += shard.Balance
+	}
+
+	opening, err := tx.FirstEvent(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if opening == nil || opening.Type != models.EventWalletCreated {
+		return append(discrepancies, models.ReconciliationDiscrepancy{
+			Kind:    models.DiscrepancyHistory,
+			Address: address,
+			Actual:  balance,
+			Detail:  "no wallet_created event opens the history",
+		}), nil
+	}
+	totals, err := tx.EventTotals(ctx, &address)
+	if err != nil {
+		return nil, err
+	}
+	received, sent, err := tx.TransferTotals(ctx, address, opening.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expected := opening.Amount + totals[models.EventMinted] + received - sent; balance != expected {
+		discrepancies = append(discrepancies, models.ReconciliationDiscrepancy{
+			Kind:     models.DiscrepancyHistory,
+			Address:  address,
+			Expected: expected,
+			Actual:   balance,
+		})
+	}
+	return discrepancies, nil
+}
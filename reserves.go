@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"token-transfer-api/cli"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
+	"token-transfer-api/merkle"
+	"token-transfer-api/reserves"
+)
+
+// runReserves implements the "reserves commit" and "reserves verify" commands. verify
+// checks a proof saved from the balanceProof query against a root and needs no database.
+func runReserves(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: reserves commit | verify ROOT PROOF_FILE")
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "commit":
+		database, err := db.Connect(cfg.Database)
+		if err != nil {
+			fatal("failed to connect to the database", err)
+		}
+		root, err := reserves.Commit(context.Background(), cli.NewStore(cfg.Database, database))
+		if err != nil {
+			fatal("failed to commit the balance root", err)
+		}
+		if root == nil {
+			fmt.Println("no balance changed since the latest root")
+			return
+		}
+		fmt.Printf("balance root %d over %d wallets holding %d: %s\n", root.Seq, root.Wallets, root.Total, root.Root)
+	case "verify":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: reserves verify ROOT PROOF_FILE")
+			os.Exit(2)
+		}
+		data, err := os.ReadFile(args[2])
+		if err != nil {
+			fatal("failed to read the proof", err)
+		}
+		var proof merkle.Proof
+		if err := json.Unmarshal(data, &proof); err != nil {
+			fatal("failed to parse the proof", err)
+		}
+		if !merkle.Verify(args[1], &proof) {
+			fmt.Printf("the balance %d of %s is NOT included in %s\n", proof.Balance, proof.Address, args[1])
+			os.Exit(1)
+		}
+		fmt.Printf("the balance %d of %s is included in %s\n", proof.Balance, proof.Address, args[1])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown reserves command %q\n", args[0])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,101 @@
+// Package reserves publishes proof-of-reserves attestations: roots of the Merkle tree of
+// every wallet's balance, persisted with a sequence number, and inclusion proofs that let
+// a wallet owner check their balance was counted. The trees are built by package merkle,
+// whose Verify checks a proof offline.
+package reserves
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"token-transfer-api/ledger"
+	"token-transfer-api/merkle"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+)
+
+// ErrNotIncluded is returned when a balance root has no leaf for the requested wallet.
+var ErrNotIncluded = errors.New("wallet is not included in the balance root")
+
+// Commit persists the root of the tree of every wallet's balance, shards included, and
+// returns it. The balances are read from a snapshot, see ledger.Balances, so committing
+// does not hold up transfers. It returns nil if the root is the same as the latest one.
+func Commit(ctx context.Context, s store.Store) (*models.BalanceRoot, error) {
+	var root *models.BalanceRoot
+	err := s.Snapshot(ctx, func(tx store.Tx) error {
+		wallets, err := ledger.Balances(ctx, tx)
+		if err != nil {
+			return err
+		}
+		leaves := make([]merkle.Leaf, len(wallets))
+		total := 0
+		for i, wallet := range wallets {
+			leaves[i] = merkle.Leaf{Address: wallet.Address, Balance: wallet.Balance}
+			total += wallet.Balance
+		}
+
+		tree := merkle.Build(leaves)
+		latest, err := tx.LatestBalanceRoot(ctx)
+		if err != nil {
+			return err
+		}
+		if latest != nil && latest.Root == tree.Root() {
+			return nil
+		}
+
+		root = &models.BalanceRoot{
+			Root:      tree.Root(),
+			Wallets:   tree.Len(),
+			Total:     total,
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+			Leaves:    make([]models.BalanceLeaf, len(leaves)),
+		}
+		for i, leaf := range leaves {
+			root.Leaves[i] = models.BalanceLeaf{Address: leaf.Address, Balance: leaf.Balance}
+		}
+		return tx.SaveBalanceRoot(ctx, root)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root != nil {
+		slog.InfoContext(ctx, "committed balance root", "seq", root.Seq, "root", root.Root, "wallets", root.Wallets, "total", root.Total)
+	}
+	return root, nil
+}
+
+// Prove returns the inclusion proof of the balance of address under the latest root with
+// the given hash, or under the latest root if root is empty, along with that root.
+func Prove(ctx context.Context, s store.Store, address string, root string) (*models.BalanceRoot, *merkle.Proof, error) {
+	if root == "" {
+		latest, err := s.LatestBalanceRoot(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if latest == nil {
+			return nil, nil, models.ErrBalanceRootNotFound
+		}
+		root = latest.Root
+	}
+	found, err := s.BalanceRoot(ctx, root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	leaves := make([]merkle.Leaf, len(found.Leaves))
+	for i, leaf := range found.Leaves {
+		leaves[i] = merkle.Leaf{Address: leaf.Address, Balance: leaf.Balance}
+	}
+	tree := merkle.Build(leaves)
+	if tree.Root() != found.Root {
+		return nil, nil, fmt.Errorf("leaves of balance root %d do not match its hash", found.Seq)
+	}
+	proof, ok := tree.Proof(address)
+	if !ok {
+		return nil, nil, ErrNotIncluded
+	}
+	found.Leaves = nil
+	return found, proof, nil
+}
@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+)
+
+// Blocklist matches transfers from or to any of its addresses.
+type Blocklist struct {
+	Addresses map[string]bool
+	Decision  Decision
+}
+
+func (b *Blocklist) Name() string { return "blocklist" }
+
+func (b *Blocklist) Evaluate(t Transfer, history History) (Decision, string, error) {
+	if b.Addresses[t.FromAddress] {
+		return b.Decision, fmt.Sprintf("sender %s is blocklisted", t.FromAddress), nil
+	}
+	if b.Addresses[t.ToAddress] {
+		return b.Decision, fmt.Sprintf("receiver %s is blocklisted", t.ToAddress), nil
+	}
+	return Allow, "", nil
+}
+
+// AmountThreshold matches transfers of at least Amount tokens.
+type AmountThreshold struct {
+	Amount   int
+	Decision Decision
+}
+
+func (a *AmountThreshold) Name() string { return "amount_threshold" }
+
+func (a *AmountThreshold) Evaluate(t Transfer, history History) (Decision, string, error) {
+	if t.Amount >= a.Amount {
+		return a.Decision, fmt.Sprintf("amount %d reaches threshold %d", t.Amount, a.Amount), nil
+	}
+	return Allow, "", nil
+}
+
+// FanOut matches senders that paid more than MaxRecipients distinct wallets within Window,
+// counting the receiver of the transfer being evaluated if it is a new one.
+type FanOut struct {
+	MaxRecipients int
+	Window        time.Duration
+	Decision      Decision
+}
+
+func (f *FanOut) Name() string { return "fan_out" }
+
+func (f *FanOut) Evaluate(t Transfer, history History) (Decision, string, error) {
+	recipients, err := history.RecipientsSince(t.FromAddress, t.At.Add(-f.Window))
+	if err != nil {
+		return Allow, "", err
+	}
+	if recipients < f.MaxRecipients {
+		return Allow, "", nil
+	}
+	known, err := history.HasTransferred(t.FromAddress, t.ToAddress)
+	if err != nil {
+		return Allow, "", err
+	}
+	if known {
+		return Allow, "", nil
+	}
+	return f.Decision, fmt.Sprintf("more than %d recipients within %s", f.MaxRecipients, f.Window), nil
+}
+
+// FirstTimeCounterparty matches transfers of at least MinAmount tokens to a wallet the
+// sender never paid before.
+type FirstTimeCounterparty struct {
+	MinAmount int
+	Decision  Decision
+}
+
+func (f *FirstTimeCounterparty) Name() string { return "first_time_counterparty" }
+
+func (f *FirstTimeCounterparty) Evaluate(t Transfer, history History) (Decision, string, error) {
+	if t.Amount < f.MinAmount {
+		return Allow, "", nil
+	}
+	known, err := history.HasTransferred(t.FromAddress, t.ToAddress)
+	if err != nil {
+		return Allow, "", err
+	}
+	if known {
+		return Allow, "", nil
+	}
+	return f.Decision, fmt.Sprintf("first transfer to %s", t.ToAddress), nil
+}
@@ -0,0 +1,88 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ruleConfig is one entry of a rules file. Which fields are used depends on Type.
+type ruleConfig struct {
+	Type          string   `json:"type"`
+	Decision      string   `json:"decision"`
+	Addresses     []string `json:"addresses"`
+	Amount        int      `json:"amount"`
+	MaxRecipients int      `json:"maxRecipients"`
+	Window        string   `json:"window"`
+}
+
+type fileConfig struct {
+	Rules []ruleConfig `json:"rules"`
+}
+
+// LoadFile builds an engine from a JSON rules file such as:
+//
+//	{
+//	  "rules": [
+//	    {"type": "blocklist", "addresses": ["0xdead"], "decision": "deny"},
+//	    {"type": "amount_threshold", "amount": 100000, "decision": "review"},
+//	    {"type": "fan_out", "maxRecipients": 20, "window": "1h", "decision": "review"},
+//	    {"type": "first_time_counterparty", "amount": 10000, "decision": "review"}
+//	  ]
+//	}
+func LoadFile(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules file: %w", err)
+	}
+
+	var config fileConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing rules file: %w", err)
+	}
+
+	var rules []Rule
+	for i, c := range config.Rules {
+		rule, err := c.build()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return NewEngine(rules...), nil
+}
+
+func (c ruleConfig) build() (Rule, error) {
+	decision, err := ParseDecision(c.Decision)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Type {
+	case "blocklist":
+		addresses := make(map[string]bool, len(c.Addresses))
+		for _, address := range c.Addresses {
+			addresses[address] = true
+		}
+		return &Blocklist{Addresses: addresses, Decision: decision}, nil
+	case "amount_threshold":
+		if c.Amount <= 0 {
+			return nil, errors.New("amount must be positive")
+		}
+		return &AmountThreshold{Amount: c.Amount, Decision: decision}, nil
+	case "fan_out":
+		window, err := time.ParseDuration(c.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window: %w", err)
+		}
+		if c.MaxRecipients <= 0 || window <= 0 {
+			return nil, errors.New("maxRecipients and window must be positive")
+		}
+		return &FanOut{MaxRecipients: c.MaxRecipients, Window: window, Decision: decision}, nil
+	case "first_time_counterparty":
+		return &FirstTimeCounterparty{MinAmount: c.Amount, Decision: decision}, nil
+	}
+	return nil, fmt.Errorf("unknown rule type %q", c.Type)
+}
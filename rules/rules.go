@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+)
+
+// Decision is the outcome of evaluating a transfer against a rule.
+type Decision int
+
+const (
+	Allow Decision = iota
+	Review
+	Deny
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Allow:
+		return "allow"
+	case Review:
+		return "review"
+	case Deny:
+		return "deny"
+	}
+	return fmt.Sprintf("Decision(%d)", int(d))
+}
+
+// ParseDecision parses the decision names used in rule files.
+func ParseDecision(s string) (Decision, error) {
+	switch s {
+	case "allow":
+		return Allow, nil
+	case "review":
+		return Review, nil
+	case "deny":
+		return Deny, nil
+	}
+	return Allow, fmt.Errorf("unknown decision %q", s)
+}
+
+// Transfer is the transfer being evaluated.
+type Transfer struct {
+	FromAddress string
+	ToAddress   string
+	Amount      int
+	At          time.Time
+}
+
+// History gives rules access to past transfers. It is backed by the same transaction
+// as the transfer being evaluated.
+type History interface {
+	// RecipientsSince returns the number of distinct wallets address sent tokens to since the given time.
+	RecipientsSince(address string, since time.Time) (int, error)
+	// HasTransferred reports whether fromAddress ever sent tokens to toAddress.
+	HasTransferred(fromAddress string, toAddress string) (bool, error)
+}
+
+// Rule evaluates a single fraud or compliance check.
+type Rule interface {
+	Name() string
+	// Evaluate returns the decision for t and, unless it allows t, the reason for it.
+	Evaluate(t Transfer, history History) (Decision, string, error)
+}
+
+// Result is the combined outcome of all rules of an engine.
+type Result struct {
+	Decision Decision
+	Reasons  []string
+}
+
+// Engine evaluates transfers against a set of rules.
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Rules returns the rules of the engine in evaluation order.
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Evaluate runs every rule and returns the strictest decision together with the
+// reasons of all rules that did not allow the transfer.
+func (e *Engine) Evaluate(t Transfer, history History) (Result, error) {
+	result := Result{Decision: Allow}
+	for _, rule := range e.rules {
+		decision, reason, err := rule.Evaluate(t, history)
+		if err != nil {
+			return Result{}, fmt.Errorf("rule %s: %w", rule.Name(), err)
+		}
+		if decision == Allow {
+			continue
+		}
+		if decision > result.Decision {
+			result.Decision = decision
+		}
+		result.Reasons = append(result.Reasons, fmt.Sprintf("%s: %s", rule.Name(), reason))
+	}
+	return result, nil
+}
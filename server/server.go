@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"token-transfer-api/config"
+)
+
+// Server is the HTTP server of the API. It drains gracefully: once shutdown starts it
+// reports itself as not ready, stops accepting connections and lets in-flight requests
+// finish before the shutdown deadline.
+type Server struct {
+	cfg      config.ServerConfig
+	http     *http.Server
+	draining atomic.Bool
+}
+
+func New(cfg config.ServerConfig, handler http.Handler) *Server {
+	return &Server{
+		cfg: cfg,
+		http: &http.Server{
+			Addr:         cfg.Addr,
+			Handler:      handler,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
+	}
+}
+
+// Draining reports whether the server has started shutting down.
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// Run serves requests until ctx is cancelled, then drains the server within the
+// configured shutdown timeout. Requests still running at the deadline are cancelled,
+// which rolls back their transactions.
+func (s *Server) Run(ctx context.Context) error {
+	if s.cfg.TLSClientCAFile != "" {
+		pool, err := loadCertPool(s.cfg.TLSClientCAFile)
+		if err != nil {
+			return err
+		}
+		// Clients without a certificate are still served, as the anonymous principal.
+		s.http.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if s.cfg.TLSCertFile != "" {
+			slog.Info("GraphQL server is running", "url", "https://"+s.cfg.Addr)
+			errs <- s.http.ListenAndServeTLS(s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+		} else {
+			slog.Info("GraphQL server is running", "url", "http://"+s.cfg.Addr)
+			errs <- s.http.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.draining.Store(true)
+	slog.Info("shutting down, draining in-flight requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		s.http.Close()
+		return fmt.Errorf("error draining requests: %w", err)
+	}
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// loadCertPool reads the PEM certificates in path.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in client CA file %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,34 @@
+package gormstore
+
+import (
+	"errors"
+
+	"github.com/glebarez/go-sqlite"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// sqliteBusy is the primary SQLITE_BUSY result code; extended codes keep it in their low byte.
+const sqliteBusy = 5
+
+// Retryable is a store.Classifier for the errors of the databases gormstore runs on.
+// Postgres aborts transactions with serialization failures (40001) and deadlocks
+// (40P01) that would succeed if run again; SQLite reports SQLITE_BUSY when the write
+// lock was not released within the busy timeout.
+func Retryable(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001":
+			return "serialization_failure"
+		case "40P01":
+			return "deadlock"
+		}
+		return ""
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqliteBusy {
+		return "busy"
+	}
+	return ""
+}
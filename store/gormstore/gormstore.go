@@ -0,0 +1,568 @@
+// Package gormstore implements store.Store on a GORM database, locking rows with
+// SELECT ... FOR UPDATE. SQLite has no row locks: its dialector drops the locking
+// clause and db.Connect opens SQLite transactions with BEGIN IMMEDIATE, which takes the
+// database write lock up front and so serializes transactions instead.
+package gormstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Store is a store.Store on a GORM database. Inside Transaction it is bound to the
+// transaction and passed to fn as the store.Tx.
+type Store struct {
+	db *gorm.DB
+
+	// Isolation is the isolation level of transactions started by Transaction. The zero
+	// value uses the database default, which is READ COMMITTED on Postgres. SQLite
+	// transactions are always serializable and ignore it.
+	Isolation sql.IsolationLevel
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// DB returns the database the store runs on.
+func (s *Store) DB() *gorm.DB {
+	return s.db
+}
+
+// Transaction runs fn in a new transaction, or in a savepoint when s is already bound to
+// one; GORM nests transactions that way.
+func (s *Store) Transaction(ctx context.Context, fn func(tx store.Tx) error) error {
+	var opts []*sql.TxOptions
+	if s.Isolation != sql.LevelDefault && s.db.Dialector.Name() == "postgres" {
+		opts = append(opts, &sql.TxOptions{Isolation: s.Isolation})
+	}
+	return s.db.WithContext(ctx).Session(&gorm.Session{
+		SkipDefaultTransaction: true,
+		PrepareStmt:            true,
+	}).Transaction(func(tx *gorm.DB) error {
+		return fn(&Store{db: tx, Isolation: s.Isolation})
+	}, opts...)
+}
+
+// Snapshot runs fn in a REPEATABLE READ transaction on Postgres. SQLite transactions
+// always read a snapshot.
+func (s *Store) Snapshot(ctx context.Context, fn func(tx store.Tx) error) error {
+	snapshot := *s
+	if s.Isolation != sql.LevelSerializable {
+		snapshot.Isolation = sql.LevelRepeatableRead
+	}
+	return snapshot.Transaction(ctx, fn)
+}
+
+func (s *Store) with(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx)
+}
+
+func (s *Store) Wallet(ctx context.Context, address string) (*models.Wallet, error) {
+	return s.findWallet(s.with(ctx), address)
+}
+
+func (s *Store) LockWallet(ctx context.Context, address string) (*models.Wallet, error) {
+	return s.findWallet(s.with(ctx).Clauses(clause.Locking{Strength: "UPDATE"}), address)
+}
+
+func (s *Store) findWallet(db *gorm.DB, address string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	result := db.Where("address = ?", address).Limit(1).Find(&wallet)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.ErrWalletNotFound
+	}
+	return &wallet, nil
+}
+
+func (s *Store) Wallets(ctx context.Context) ([]*models.Wallet, error) {
+	var wallets []*models.Wallet
+	err := s.with(ctx).Order("address").Find(&wallets).Error
+	return wallets, err
+}
+
+func (s *Store) WalletsAfter(ctx context.Context, after string, limit int) ([]*models.Wallet, error) {
+	var wallets []*models.Wallet
+	err := s.with(ctx).Where("address > ?", after).Order("address").Limit(limit).Find(&wallets).Error
+	return wallets, err
+}
+
+func (s *Store) CreateWallet(ctx context.Context, address string, balance int) error {
+	return models.InitializeWallet(s.with(ctx), address, balance)
+}
+
+func (s *Store) SaveWallet(ctx context.Context, wallet *models.Wallet) error {
+	return s.with(ctx).Save(wallet).Error
+}
+
+func (s *Store) VerifyWallet(ctx context.Context, address string) (*models.Wallet, error) {
+	return models.VerifyWallet(s.with(ctx), address)
+}
+
+func (s *Store) SetWalletFrozen(ctx context.Context, address string, frozen bool) (*models.Wallet, error) {
+	return models.SetWalletFrozen(s.with(ctx), address, frozen)
+}
+
+func (s *Store) WalletLimits(ctx context.Context, address string) (*models.WalletLimits, error) {
+	var limits models.WalletLimits
+	result := s.with(ctx).Where("address = ?", address).Limit(1).Find(&limits)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &limits, nil
+}
+
+func (s *Store) SetWalletLimits(ctx context.Context, limits models.WalletLimits) error {
+	return models.SetWalletLimits(s.with(ctx), limits)
+}
+
+func (s *Store) WalletShards(ctx context.Context, address string) ([]*models.WalletShard, error) {
+	var shards []*models.WalletShard
+	err := s.with(ctx).Where("address = ?", address).Order("shard").Find(&shards).Error
+	return shards, err
+}
+
+func (s *Store) ShardedWallets(ctx context.Context) ([]string, error) {
+	var addresses []string
+	err := s.with(ctx).Model(&models.WalletShard{}).Distinct("address").Order("address").Pluck("address", &addresses).Error
+	return addresses, err
+}
+
+func (s *Store) LockWalletShard(ctx context.Context, address string, shard int) (*models.WalletShard, error) {
+	var found models.WalletShard
+	result := s.with(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("address = ? AND shard = ?", address, shard).
+		Limit(1).
+		Find(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.ErrShardNotFound
+	}
+	return &found, nil
+}
+
+func (s *Store) SaveWalletShard(ctx context.Context, shard *models.WalletShard) error {
+	return s.with(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(shard).Error
+}
+
+func (s *Store) DeleteWalletShards(ctx context.Context, address string, from int) error {
+	return s.with(ctx).Where("address = ? AND shard >= ?", address, from).Delete(&models.WalletShard{}).Error
+}
+
+func (s *Store) AppendEvents(ctx context.Context, events ...*models.WalletEvent) error {
+	return models.AppendEvents(s.with(ctx), events...)
+}
+
+func (s *Store) WalletEvents(ctx context.Context, address string, after int64) ([]*models.WalletEvent, error) {
+	var events []*models.WalletEvent
+	err := s.with(ctx).Where("address = ? AND seq > ?", address, after).Order("seq").Find(&events).Error
+	return events, err
+}
+
+func (s *Store) WalletEventsAsOf(ctx context.Context, address string, after int64, at time.Time) ([]*models.WalletEvent, error) {
+	var events []*models.WalletEvent
+	err := s.with(ctx).Where("address = ? AND seq > ? AND created_at <= ?", address, after, at).Order("seq").Find(&events).Error
+	return events, err
+}
+
+func (s *Store) EventAddresses(ctx context.Context) ([]string, error) {
+	var addresses []string
+	err := s.with(ctx).Model(&models.WalletEvent{}).Distinct("address").Order("address").Pluck("address", &addresses).Error
+	return addresses, err
+}
+
+func (s *Store) FirstEvent(ctx context.Context, address string) (*models.WalletEvent, error) {
+	var event models.WalletEvent
+	result := s.with(ctx).Where("address = ?", address).Order("seq").Limit(1).Find(&event)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &event, nil
+}
+
+func (s *Store) EventTotals(ctx context.Context, address *string) (map[string]int, error) {
+	return models.EventTotals(s.with(ctx), address)
+}
+
+func (s *Store) LatestSnapshot(ctx context.Context, address string) (*models.WalletSnapshot, error) {
+	var snapshot models.WalletSnapshot
+	result := s.with(ctx).Where("address = ?", address).Order("seq DESC").Limit(1).Find(&snapshot)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
+
+func (s *Store) SnapshotAsOf(ctx context.Context, address string, at time.Time) (*models.WalletSnapshot, error) {
+	var snapshot models.WalletSnapshot
+	result := s.with(ctx).Where("address = ? AND created_at <= ?", address, at).Order("seq DESC").Limit(1).Find(&snapshot)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
+
+func (s *Store) SnapshotsAsOf(ctx context.Context, addresses []string, at time.Time) ([]*models.WalletSnapshot, error) {
+	var snapshots []*models.WalletSnapshot
+	latest := s.with(ctx).Model(&models.WalletSnapshot{}).Select("address, MAX(seq) AS seq").
+		Where("address IN ? AND created_at <= ?", addresses, at).Group("address")
+	err := s.with(ctx).Joins("JOIN (?) AS latest ON latest.address = wallet_snapshots.address AND latest.seq = wallet_snapshots.seq", latest).
+		Find(&snapshots).Error
+	return snapshots, err
+}
+
+func (s *Store) EventsAsOf(ctx context.Context, addresses []string, at time.Time) ([]*models.WalletEvent, error) {
+	var events []*models.WalletEvent
+	err := s.with(ctx).
+		Where("address IN ? AND created_at <= ?", addresses, at).
+		Where("seq > COALESCE((SELECT MAX(seq) FROM wallet_snapshots WHERE wallet_snapshots.address = wallet_events.address AND wallet_snapshots.created_at <= ?), 0)", at).
+		Order("seq").Find(&events).Error
+	return events, err
+}
+
+func (s *Store) SaveSnapshot(ctx context.Context, snapshot *models.WalletSnapshot) error {
+	return s.with(ctx).Create(snapshot).Error
+}
+
+func (s *Store) AppendAuditRecords(ctx context.Context, records ...*models.AuditRecord) error {
+	return models.AppendAuditRecords(s.with(ctx), records...)
+}
+
+func (s *Store) AuditRecords(ctx context.Context, address string, after int64, limit int) ([]*models.AuditRecord, error) {
+	var records []*models.AuditRecord
+	err := s.with(ctx).Where("address = ? AND seq > ?", address, after).Order("seq").Limit(limit).Find(&records).Error
+	return records, err
+}
+
+func (s *Store) AuditAddresses(ctx context.Context) ([]string, error) {
+	var addresses []string
+	err := s.with(ctx).Model(&models.AuditRecord{}).Distinct("address").Order("address").Pluck("address", &addresses).Error
+	return addresses, err
+}
+
+func (s *Store) AuditHeads(ctx context.Context) ([]*models.AuditRecord, error) {
+	var heads []*models.AuditRecord
+	err := s.with(ctx).
+		Where("seq = (SELECT MAX(latest.seq) FROM audit_records latest WHERE latest.account = audit_records.account)").
+		Order("account").Find(&heads).Error
+	return heads, err
+}
+
+func (s *Store) AuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error) {
+	var checkpoints []*models.AuditCheckpoint
+	err := s.with(ctx).Preload("Entries").Order("id").Find(&checkpoints).Error
+	return checkpoints, err
+}
+
+func (s *Store) LatestAuditCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	var checkpoint models.AuditCheckpoint
+	result := s.with(ctx).Order("id DESC").Limit(1).Find(&checkpoint)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+func (s *Store) CheckpointedHeads(ctx context.Context) ([]*models.AuditCheckpointEntry, error) {
+	var entries []*models.AuditCheckpointEntry
+	err := s.with(ctx).
+		Where("seq = (SELECT MAX(latest.seq) FROM audit_checkpoint_entries latest WHERE latest.account = audit_checkpoint_entries.account)").
+		Order("account").Find(&entries).Error
+	return entries, err
+}
+
+func (s *Store) SaveAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	return s.with(ctx).Create(checkpoint).Error
+}
+
+func (s *Store) SaveBalanceRoot(ctx context.Context, root *models.BalanceRoot) error {
+	return models.SaveBalanceRoot(s.with(ctx), root)
+}
+
+func (s *Store) LatestBalanceRoot(ctx context.Context) (*models.BalanceRoot, error) {
+	var root models.BalanceRoot
+	result := s.with(ctx).Order("seq DESC").Limit(1).Find(&root)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &root, nil
+}
+
+func (s *Store) BalanceRoot(ctx context.Context, root string) (*models.BalanceRoot, error) {
+	var found models.BalanceRoot
+	result := s.with(ctx).Preload("Leaves").Where("root = ?", root).Order("seq DESC").Limit(1).Find(&found)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.ErrBalanceRootNotFound
+	}
+	return &found, nil
+}
+
+func (s *Store) UnsealedTransfers(ctx context.Context) ([]*models.Transfer, error) {
+	var transfers []*models.Transfer
+	err := s.with(ctx).Where("block_number IS NULL").Order("created_at, id").Find(&transfers).Error
+	return transfers, err
+}
+
+func (s *Store) SaveBlock(ctx context.Context, block *models.Block, transfers []*models.Transfer) error {
+	return models.SaveBlock(s.with(ctx), block, transfers)
+}
+
+func (s *Store) LatestBlock(ctx context.Context) (*models.Block, error) {
+	var block models.Block
+	result := s.with(ctx).Order("number DESC").Limit(1).Find(&block)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &block, nil
+}
+
+func (s *Store) Block(ctx context.Context, number int64) (*models.Block, error) {
+	var block models.Block
+	result := s.with(ctx).Where("number = ?", number).Limit(1).Find(&block)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, models.ErrBlockNotFound
+	}
+	return &block, nil
+}
+
+func (s *Store) BlockTransfers(ctx context.Context, number int64) ([]*models.Transfer, error) {
+	var transfers []*models.Transfer
+	err := s.with(ctx).Where("block_number = ?", number).Order("created_at, id").Find(&transfers).Error
+	return transfers, err
+}
+
+func (s *Store) SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error {
+	return models.SaveReconciliationReport(s.with(ctx), report)
+}
+
+func (s *Store) ReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	var reports []*models.ReconciliationReport
+	err := s.with(ctx).
+		Preload("Discrepancies", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Order("id DESC").Limit(limit).Find(&reports).Error
+	return reports, err
+}
+
+func (s *Store) RecordTransfer(ctx context.Context, fromAddress string, toAddress string, amount int, at time.Time) (*models.Transfer, error) {
+	return models.RecordTransfer(s.with(ctx), fromAddress, toAddress, amount, at)
+}
+
+func (s *Store) CountRecipientsSince(ctx context.Context, address string, since time.Time) (int, error) {
+	return models.CountRecipientsSince(s.with(ctx), address, since)
+}
+
+func (s *Store) HasTransferred(ctx context.Context, fromAddress string, toAddress string) (bool, error) {
+	return models.HasTransferred(s.with(ctx), fromAddress, toAddress)
+}
+
+func (s *Store) TransferTotals(ctx context.Context, address string, since time.Time) (int, int, error) {
+	return models.TransferTotals(s.with(ctx), address, since)
+}
+
+func (s *Store) TransfersAfter(ctx context.Context, after *models.Transfer, limit int) ([]*models.Transfer, error) {
+	return models.TransfersAfter(s.with(ctx), after, limit)
+}
+
+func (s *Store) ImportTransfers(ctx context.Context, transfers []*models.Transfer) error {
+	return models.ImportTransfers(s.with(ctx), transfers)
+}
+
+func (s *Store) RecordSpend(ctx context.Context, address string, amount int, at time.Time) error {
+	return models.RecordSpend(s.with(ctx), address, amount, at)
+}
+
+func (s *Store) SpentSince(ctx context.Context, address string, since time.Time) (int, int, error) {
+	return models.SpentSince(s.with(ctx), address, since)
+}
+
+func (s *Store) PruneSpendCounters(ctx context.Context, address string, now time.Time) error {
+	return models.PruneSpendCounters(s.with(ctx), address, now)
+}
+
+func (s *Store) CreateReview(ctx context.Context, review *models.TransferReview) error {
+	return s.with(ctx).Create(review).Error
+}
+
+func (s *Store) LockPendingReview(ctx context.Context, id string) (*models.TransferReview, error) {
+	return models.LockPendingReview(s.with(ctx), id)
+}
+
+func (s *Store) SaveReview(ctx context.Context, review *models.TransferReview) error {
+	return s.with(ctx).Save(review).Error
+}
+
+func (s *Store) Reviews(ctx context.Context, status *string) ([]*models.TransferReview, error) {
+	query := s.with(ctx).Order("created_at")
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var reviews []*models.TransferReview
+	if err := query.Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (s *Store) CreateMultisigWallet(ctx context.Context, address string, signers map[string]string, threshold int) (*models.MultisigWallet, error) {
+	return models.CreateMultisigWallet(s.with(ctx), address, signers, threshold)
+}
+
+func (s *Store) ReplaceMultisigSigners(ctx context.Context, address string, signers map[string]string, threshold int) (*models.MultisigWallet, error) {
+	return models.ReplaceMultisigSigners(s.with(ctx), address, signers, threshold)
+}
+
+func (s *Store) MultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error) {
+	return models.FindMultisigWallet(s.with(ctx), address)
+}
+
+func (s *Store) LockMultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error) {
+	return models.LockMultisigWallet(s.with(ctx), address)
+}
+
+func (s *Store) Signer(ctx context.Context, address string, signer string) (*models.MultisigSigner, error) {
+	return models.FindSigner(s.with(ctx), address, signer)
+}
+
+func (s *Store) Signers(ctx context.Context, address string) ([]string, error) {
+	var signers []string
+	if err := s.with(ctx).Model(&models.MultisigSigner{}).
+		Where("address = ?", address).
+		Order("signer").
+		Pluck("signer", &signers).Error; err != nil {
+		return nil, err
+	}
+	return signers, nil
+}
+
+func (s *Store) CreateProposal(ctx context.Context, proposal *models.TransferProposal) error {
+	return s.with(ctx).Create(proposal).Error
+}
+
+func (s *Store) LockProposal(ctx context.Context, id string) (*models.TransferProposal, error) {
+	return models.LockProposal(s.with(ctx), id)
+}
+
+func (s *Store) SaveProposal(ctx context.Context, proposal *models.TransferProposal) error {
+	return s.with(ctx).Save(proposal).Error
+}
+
+func (s *Store) Proposals(ctx context.Context, address string, status *string) ([]*models.TransferProposal, error) {
+	query := s.with(ctx).Where("from_address = ?", address).Order("created_at")
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var proposals []*models.TransferProposal
+	if err := query.Find(&proposals).Error; err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+func (s *Store) CreateMultisigChange(ctx context.Context, change *models.MultisigChange) error {
+	return s.with(ctx).Create(change).Error
+}
+
+func (s *Store) LockMultisigChange(ctx context.Context, id string) (*models.MultisigChange, error) {
+	return models.LockMultisigChange(s.with(ctx), id)
+}
+
+func (s *Store) SaveMultisigChange(ctx context.Context, change *models.MultisigChange) error {
+	return s.with(ctx).Save(change).Error
+}
+
+func (s *Store) MultisigChanges(ctx context.Context, address string, status *string) ([]*models.MultisigChange, error) {
+	query := s.with(ctx).Where("address = ?", address).Order("created_at")
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var changes []*models.MultisigChange
+	if err := query.Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (s *Store) AddApproval(ctx context.Context, approval *models.ProposalApproval) (bool, error) {
+	result := s.with(ctx).Where(&models.ProposalApproval{ProposalID: approval.ProposalID, Signer: approval.Signer}).
+		Attrs(approval).
+		FirstOrCreate(approval)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *Store) CountApprovals(ctx context.Context, proposalID uuid.UUID) (int, error) {
+	return models.CountApprovals(s.with(ctx), proposalID)
+}
+
+func (s *Store) Approvals(ctx context.Context, proposalID uuid.UUID) ([]string, error) {
+	var signers []string
+	if err := s.with(ctx).Model(&models.ProposalApproval{}).
+		Where("proposal_id = ?", proposalID).
+		Order("created_at").
+		Pluck("signer", &signers).Error; err != nil {
+		return nil, err
+	}
+	return signers, nil
+}
+
+func (s *Store) ExpireProposals(ctx context.Context, now time.Time) (int64, error) {
+	return models.ExpireProposals(s.with(ctx), now)
+}
+
+func (s *Store) CreateTranche(ctx context.Context, tranche *models.VestingTranche) error {
+	return s.with(ctx).Create(tranche).Error
+}
+
+func (s *Store) VestingTranches(ctx context.Context, address string) ([]*models.VestingTranche, error) {
+	return models.FindVestingTranches(s.with(ctx), address)
+}
+
+func (s *Store) LockedBalance(ctx context.Context, address string, at time.Time) (int, error) {
+	return models.LockedBalance(s.with(ctx), address, at)
+}
@@ -0,0 +1,341 @@
+package memstore
+
+import (
+	"context"
+	"time"
+	"token-transfer-api/models"
+
+	"github.com/google/uuid"
+)
+
+// The methods of Store run each operation in a transaction of its own.
+
+func (s *Store) Wallet(ctx context.Context, address string) (*models.Wallet, error) {
+	return run(s, ctx, func(t *txn) (*models.Wallet, error) { return t.Wallet(ctx, address) })
+}
+
+func (s *Store) LockWallet(ctx context.Context, address string) (*models.Wallet, error) {
+	return run(s, ctx, func(t *txn) (*models.Wallet, error) { return t.LockWallet(ctx, address) })
+}
+
+func (s *Store) Wallets(ctx context.Context) ([]*models.Wallet, error) {
+	return run(s, ctx, func(t *txn) ([]*models.Wallet, error) { return t.Wallets(ctx) })
+}
+
+func (s *Store) WalletsAfter(ctx context.Context, after string, limit int) ([]*models.Wallet, error) {
+	return run(s, ctx, func(t *txn) ([]*models.Wallet, error) { return t.WalletsAfter(ctx, after, limit) })
+}
+
+func (s *Store) CreateWallet(ctx context.Context, address string, balance int) error {
+	return exec(s, ctx, func(t *txn) error { return t.CreateWallet(ctx, address, balance) })
+}
+
+func (s *Store) SaveWallet(ctx context.Context, wallet *models.Wallet) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveWallet(ctx, wallet) })
+}
+
+func (s *Store) VerifyWallet(ctx context.Context, address string) (*models.Wallet, error) {
+	return run(s, ctx, func(t *txn) (*models.Wallet, error) { return t.VerifyWallet(ctx, address) })
+}
+
+func (s *Store) SetWalletFrozen(ctx context.Context, address string, frozen bool) (*models.Wallet, error) {
+	return run(s, ctx, func(t *txn) (*models.Wallet, error) { return t.SetWalletFrozen(ctx, address, frozen) })
+}
+
+func (s *Store) WalletLimits(ctx context.Context, address string) (*models.WalletLimits, error) {
+	return run(s, ctx, func(t *txn) (*models.WalletLimits, error) { return t.WalletLimits(ctx, address) })
+}
+
+func (s *Store) SetWalletLimits(ctx context.Context, limits models.WalletLimits) error {
+	return exec(s, ctx, func(t *txn) error { return t.SetWalletLimits(ctx, limits) })
+}
+
+func (s *Store) WalletShards(ctx context.Context, address string) ([]*models.WalletShard, error) {
+	return run(s, ctx, func(t *txn) ([]*models.WalletShard, error) { return t.WalletShards(ctx, address) })
+}
+
+func (s *Store) ShardedWallets(ctx context.Context) ([]string, error) {
+	return run(s, ctx, func(t *txn) ([]string, error) { return t.ShardedWallets(ctx) })
+}
+
+func (s *Store) LockWalletShard(ctx context.Context, address string, shard int) (*models.WalletShard, error) {
+	return run(s, ctx, func(t *txn) (*models.WalletShard, error) { return t.LockWalletShard(ctx, address, shard) })
+}
+
+func (s *Store) SaveWalletShard(ctx context.Context, shard *models.WalletShard) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveWalletShard(ctx, shard) })
+}
+
+func (s *Store) DeleteWalletShards(ctx context.Context, address string, from int) error {
+	return exec(s, ctx, func(t *txn) error { return t.DeleteWalletShards(ctx, address, from) })
+}
+
+func (s *Store) AppendEvents(ctx context.Context, events ...*models.WalletEvent) error {
+	return exec(s, ctx, func(t *txn) error { return t.AppendEvents(ctx, events...) })
+}
+
+func (s *Store) WalletEvents(ctx context.Context, address string, after int64) ([]*models.WalletEvent, error) {
+	return run(s, ctx, func(t *txn) ([]*models.WalletEvent, error) { return t.WalletEvents(ctx, address, after) })
+}
+
+func (s *Store) WalletEventsAsOf(ctx context.Context, address string, after int64, at time.Time) ([]*models.WalletEvent, error) {
+	return run(s, ctx, func(t *txn) ([]*models.WalletEvent, error) { return t.WalletEventsAsOf(ctx, address, after, at) })
+}
+
+func (s *Store) EventAddresses(ctx context.Context) ([]string, error) {
+	return run(s, ctx, func(t *txn) ([]string, error) { return t.EventAddresses(ctx) })
+}
+
+func (s *Store) FirstEvent(ctx context.Context, address string) (*models.WalletEvent, error) {
+	return run(s, ctx, func(t *txn) (*models.WalletEvent, error) { return t.FirstEvent(ctx, address) })
+}
+
+func (s *Store) EventTotals(ctx context.Context, address *string) (map[string]int, error) {
+	return run(s, ctx, func(t *txn) (map[string]int, error) { return t.EventTotals(ctx, address) })
+}
+
+func (s *Store) LatestSnapshot(ctx context.Context, address string) (*models.WalletSnapshot, error) {
+	return run(s, ctx, func(t *txn) (*models.WalletSnapshot, error) { return t.LatestSnapshot(ctx, address) })
+}
+
+func (s *Store) SnapshotAsOf(ctx context.Context, address string, at time.Time) (*models.WalletSnapshot, error) {
+	return run(s, ctx, func(t *txn) (*models.WalletSnapshot, error) { return t.SnapshotAsOf(ctx, address, at) })
+}
+
+func (s *Store) SnapshotsAsOf(ctx context.Context, addresses []string, at time.Time) ([]*models.WalletSnapshot, error) {
+	return run(s, ctx, func(t *txn) ([]*models.WalletSnapshot, error) { return t.SnapshotsAsOf(ctx, addresses, at) })
+}
+
+func (s *Store) EventsAsOf(ctx context.Context, addresses []string, at time.Time) ([]*models.WalletEvent, error) {
+	return run(s, ctx, func(t *txn) ([]*models.WalletEvent, error) { return t.EventsAsOf(ctx, addresses, at) })
+}
+
+func (s *Store) SaveSnapshot(ctx context.Context, snapshot *models.WalletSnapshot) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveSnapshot(ctx, snapshot) })
+}
+
+func (s *Store) AppendAuditRecords(ctx context.Context, records ...*models.AuditRecord) error {
+	return exec(s, ctx, func(t *txn) error { return t.AppendAuditRecords(ctx, records...) })
+}
+
+func (s *Store) AuditRecords(ctx context.Context, address string, after int64, limit int) ([]*models.AuditRecord, error) {
+	return run(s, ctx, func(t *txn) ([]*models.AuditRecord, error) { return t.AuditRecords(ctx, address, after, limit) })
+}
+
+func (s *Store) AuditAddresses(ctx context.Context) ([]string, error) {
+	return run(s, ctx, func(t *txn) ([]string, error) { return t.AuditAddresses(ctx) })
+}
+
+func (s *Store) AuditHeads(ctx context.Context) ([]*models.AuditRecord, error) {
+	return run(s, ctx, func(t *txn) ([]*models.AuditRecord, error) { return t.AuditHeads(ctx) })
+}
+
+func (s *Store) AuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error) {
+	return run(s, ctx, func(t *txn) ([]*models.AuditCheckpoint, error) { return t.AuditCheckpoints(ctx) })
+}
+
+func (s *Store) LatestAuditCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	return run(s, ctx, func(t *txn) (*models.AuditCheckpoint, error) { return t.LatestAuditCheckpoint(ctx) })
+}
+
+func (s *Store) CheckpointedHeads(ctx context.Context) ([]*models.AuditCheckpointEntry, error) {
+	return run(s, ctx, func(t *txn) ([]*models.AuditCheckpointEntry, error) { return t.CheckpointedHeads(ctx) })
+}
+
+func (s *Store) SaveAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveAuditCheckpoint(ctx, checkpoint) })
+}
+
+func (s *Store) SaveBalanceRoot(ctx context.Context, root *models.BalanceRoot) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveBalanceRoot(ctx, root) })
+}
+
+func (s *Store) LatestBalanceRoot(ctx context.Context) (*models.BalanceRoot, error) {
+	return run(s, ctx, func(t *txn) (*models.BalanceRoot, error) { return t.LatestBalanceRoot(ctx) })
+}
+
+func (s *Store) BalanceRoot(ctx context.Context, root string) (*models.BalanceRoot, error) {
+	return run(s, ctx, func(t *txn) (*models.BalanceRoot, error) { return t.BalanceRoot(ctx, root) })
+}
+
+func (s *Store) UnsealedTransfers(ctx context.Context) ([]*models.Transfer, error) {
+	return run(s, ctx, func(t *txn) ([]*models.Transfer, error) { return t.UnsealedTransfers(ctx) })
+}
+
+func (s *Store) SaveBlock(ctx context.Context, block *models.Block, transfers []*models.Transfer) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveBlock(ctx, block, transfers) })
+}
+
+func (s *Store) LatestBlock(ctx context.Context) (*models.Block, error) {
+	return run(s, ctx, func(t *txn) (*models.Block, error) { return t.LatestBlock(ctx) })
+}
+
+func (s *Store) Block(ctx context.Context, number int64) (*models.Block, error) {
+	return run(s, ctx, func(t *txn) (*models.Block, error) { return t.Block(ctx, number) })
+}
+
+func (s *Store) BlockTransfers(ctx context.Context, number int64) ([]*models.Transfer, error) {
+	return run(s, ctx, func(t *txn) ([]*models.Transfer, error) { return t.BlockTransfers(ctx, number) })
+}
+
+func (s *Store) SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveReconciliationReport(ctx, report) })
+}
+
+func (s *Store) ReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	return run(s, ctx, func(t *txn) ([]*models.ReconciliationReport, error) { return t.ReconciliationReports(ctx, limit) })
+}
+
+func (s *Store) RecordTransfer(ctx context.Context, fromAddress string, toAddress string, amount int, at time.Time) (*models.Transfer, error) {
+	return run(s, ctx, func(t *txn) (*models.Transfer, error) {
+		return t.RecordTransfer(ctx, fromAddress, toAddress, amount, at)
+	})
+}
+
+func (s *Store) CountRecipientsSince(ctx context.Context, address string, since time.Time) (int, error) {
+	return run(s, ctx, func(t *txn) (int, error) { return t.CountRecipientsSince(ctx, address, since) })
+}
+
+func (s *Store) HasTransferred(ctx context.Context, fromAddress string, toAddress string) (bool, error) {
+	return run(s, ctx, func(t *txn) (bool, error) { return t.HasTransferred(ctx, fromAddress, toAddress) })
+}
+
+func (s *Store) TransferTotals(ctx context.Context, address string, since time.Time) (int, int, error) {
+	var received, sent int
+	err := exec(s, ctx, func(t *txn) error {
+		var err error
+		received, sent, err = t.TransferTotals(ctx, address, since)
+		return err
+	})
+	return received, sent, err
+}
+
+func (s *Store) TransfersAfter(ctx context.Context, after *models.Transfer, limit int) ([]*models.Transfer, error) {
+	return run(s, ctx, func(t *txn) ([]*models.Transfer, error) { return t.TransfersAfter(ctx, after, limit) })
+}
+
+func (s *Store) ImportTransfers(ctx context.Context, transfers []*models.Transfer) error {
+	return exec(s, ctx, func(t *txn) error { return t.ImportTransfers(ctx, transfers) })
+}
+
+func (s *Store) RecordSpend(ctx context.Context, address string, amount int, at time.Time) error {
+	return exec(s, ctx, func(t *txn) error { return t.RecordSpend(ctx, address, amount, at) })
+}
+
+func (s *Store) SpentSince(ctx context.Context, address string, since time.Time) (int, int, error) {
+	var amount, transfers int
+	err := exec(s, ctx, func(t *txn) error {
+		var err error
+		amount, transfers, err = t.SpentSince(ctx, address, since)
+		return err
+	})
+	return amount, transfers, err
+}
+
+func (s *Store) PruneSpendCounters(ctx context.Context, address string, now time.Time) error {
+	return exec(s, ctx, func(t *txn) error { return t.PruneSpendCounters(ctx, address, now) })
+}
+
+func (s *Store) CreateReview(ctx context.Context, review *models.TransferReview) error {
+	return exec(s, ctx, func(t *txn) error { return t.CreateReview(ctx, review) })
+}
+
+func (s *Store) LockPendingReview(ctx context.Context, id string) (*models.TransferReview, error) {
+	return run(s, ctx, func(t *txn) (*models.TransferReview, error) { return t.LockPendingReview(ctx, id) })
+}
+
+func (s *Store) SaveReview(ctx context.Context, review *models.TransferReview) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveReview(ctx, review) })
+}
+
+func (s *Store) Reviews(ctx context.Context, status *string) ([]*models.TransferReview, error) {
+	return run(s, ctx, func(t *txn) ([]*models.TransferReview, error) { return t.Reviews(ctx, status) })
+}
+
+func (s *Store) CreateMultisigWallet(ctx context.Context, address string, signers map[string]string, threshold int) (*models.MultisigWallet, error) {
+	return run(s, ctx, func(t *txn) (*models.MultisigWallet, error) {
+		return t.CreateMultisigWallet(ctx, address, signers, threshold)
+	})
+}
+
+func (s *Store) ReplaceMultisigSigners(ctx context.Context, address string, signers map[string]string, threshold int) (*models.MultisigWallet, error) {
+	return run(s, ctx, func(t *txn) (*models.MultisigWallet, error) {
+		return t.ReplaceMultisigSigners(ctx, address, signers, threshold)
+	})
+}
+
+func (s *Store) MultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error) {
+	return run(s, ctx, func(t *txn) (*models.MultisigWallet, error) { return t.MultisigWallet(ctx, address) })
+}
+
+func (s *Store) LockMultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error) {
+	return run(s, ctx, func(t *txn) (*models.MultisigWallet, error) { return t.LockMultisigWallet(ctx, address) })
+}
+
+func (s *Store) Signer(ctx context.Context, address string, signer string) (*models.MultisigSigner, error) {
+	return run(s, ctx, func(t *txn) (*models.MultisigSigner, error) { return t.Signer(ctx, address, signer) })
+}
+
+func (s *Store) Signers(ctx context.Context, address string) ([]string, error) {
+	return run(s, ctx, func(t *txn) ([]string, error) { return t.Signers(ctx, address) })
+}
+
+func (s *Store) CreateProposal(ctx context.Context, proposal *models.TransferProposal) error {
+	return exec(s, ctx, func(t *txn) error { return t.CreateProposal(ctx, proposal) })
+}
+
+func (s *Store) LockProposal(ctx context.Context, id string) (*models.TransferProposal, error) {
+	return run(s, ctx, func(t *txn) (*models.TransferProposal, error) { return t.LockProposal(ctx, id) })
+}
+
+func (s *Store) SaveProposal(ctx context.Context, proposal *models.TransferProposal) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveProposal(ctx, proposal) })
+}
+
+func (s *Store) Proposals(ctx context.Context, address string, status *string) ([]*models.TransferProposal, error) {
+	return run(s, ctx, func(t *txn) ([]*models.TransferProposal, error) { return t.Proposals(ctx, address, status) })
+}
+
+func (s *Store) CreateMultisigChange(ctx context.Context, change *models.MultisigChange) error {
+	return exec(s, ctx, func(t *txn) error { return t.CreateMultisigChange(ctx, change) })
+}
+
+func (s *Store) LockMultisigChange(ctx context.Context, id string) (*models.MultisigChange, error) {
+	return run(s, ctx, func(t *txn) (*models.MultisigChange, error) { return t.LockMultisigChange(ctx, id) })
+}
+
+func (s *Store) SaveMultisigChange(ctx context.Context, change *models.MultisigChange) error {
+	return exec(s, ctx, func(t *txn) error { return t.SaveMultisigChange(ctx, change) })
+}
+
+func (s *Store) MultisigChanges(ctx context.Context, address string, status *string) ([]*models.MultisigChange, error) {
+	return run(s, ctx, func(t *txn) ([]*models.MultisigChange, error) { return t.MultisigChanges(ctx, address, status) })
+}
+
+func (s *Store) AddApproval(ctx context.Context, approval *models.ProposalApproval) (bool, error) {
+	return run(s, ctx, func(t *txn) (bool, error) { return t.AddApproval(ctx, approval) })
+}
+
+func (s *Store) CountApprovals(ctx context.Context, proposalID uuid.UUID) (int, error) {
+	return run(s, ctx, func(t *txn) (int, error) { return t.CountApprovals(ctx, proposalID) })
+}
+
+func (s *Store) Approvals(ctx context.Context, proposalID uuid.UUID) ([]string, error) {
+	return run(s, ctx, func(t *txn) ([]string, error) { return t.Approvals(ctx, proposalID) })
+}
+
+func (s *Store) ExpireProposals(ctx context.Context, now time.Time) (int64, error) {
+	return run(s, ctx, func(t *txn) (int64, error) { return t.ExpireProposals(ctx, now) })
+}
+
+func (s *Store) CreateTranche(ctx context.Context, tranche *models.VestingTranche) error {
+	return exec(s, ctx, func(t *txn) error { return t.CreateTranche(ctx, tranche) })
+}
+
+func (s *Store) VestingTranches(ctx context.Context, address string) ([]*models.VestingTranche, error) {
+	return run(s, ctx, func(t *txn) ([]*models.VestingTranche, error) { return t.VestingTranches(ctx, address) })
+}
+
+func (s *Store) LockedBalance(ctx context.Context, address string, at time.Time) (int, error) {
+	return run(s, ctx, func(t *txn) (int, error) { return t.LockedBalance(ctx, address, at) })
+}
@@ -0,0 +1,64 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+)
+
+// locks are exclusive row locks held by transactions until they end. They play the
+// role of the row locks taken by SELECT ... FOR UPDATE, UPDATE and INSERT in Postgres.
+type locks struct {
+	mu   sync.Mutex
+	held map[string]*rowLock
+}
+
+type rowLock struct {
+	owner    *txn
+	released chan struct{}
+}
+
+// acquire locks key for t, waiting while another transaction holds it. Waiting ends
+// early with ctx's error; there is no deadlock detection, so callers must lock rows in
+// a consistent order as they would in Postgres.
+func (l *locks) acquire(ctx context.Context, t *txn, key string) error {
+	for {
+		l.mu.Lock()
+		current, ok := l.held[key]
+		if !ok {
+			l.held[key] = &rowLock{owner: t, released: make(chan struct{})}
+			t.locked = append(t.locked, key)
+			l.mu.Unlock()
+			return nil
+		}
+		if current.owner == t {
+			l.mu.Unlock()
+			return nil
+		}
+		released := current.released
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// releaseAll releases every lock held by t and wakes up its waiters.
+func (l *locks) releaseAll(t *txn) {
+	l.release(t, 0)
+}
+
+// release releases the locks t took after its first n and wakes up their waiters.
+func (l *locks) release(t *txn, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range t.locked[n:] {
+		if current, ok := l.held[key]; ok && current.owner == t {
+			delete(l.held, key)
+			close(current.released)
+		}
+	}
+	t.locked = t.locked[:n]
+}
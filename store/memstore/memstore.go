@@ -0,0 +1,369 @@
+// Package memstore implements store.Store in memory. It needs no database, which makes
+// it suitable for tests and for trying the API out locally.
+package memstore
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+
+	"github.com/google/uuid"
+)
+
+// Store is a concurrency-safe in-memory store.Store. Transactions buffer their writes
+// and publish them atomically when they commit, so no transaction observes another's
+// uncommitted changes. Rows are locked where the Postgres store locks them: explicitly
+// by LockWallet, LockPendingReview, LockProposal and LockMultisigChange, and implicitly
+// by every write.
+type Store struct {
+	mu    sync.RWMutex
+	data  tables
+	locks locks
+	// seq is the last Seq assigned to a wallet event. Like a database sequence, values
+	// taken by transactions that roll back are not reused.
+	seq atomic.Int64
+	// auditSeq and checkpointSeq are the last Seq of an audit record and ID of an audit
+	// checkpoint assigned, rootSeq the last Seq of a balance root and reportSeq the last ID
+	// of a reconciliation report.
+	auditSeq      atomic.Int64
+	checkpointSeq atomic.Int64
+	rootSeq       atomic.Int64
+	reportSeq     atomic.Int64
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		data: tables{
+			wallets:       table[string, models.Wallet]{},
+			shards:        table[shardKey, models.WalletShard]{},
+			events:        table[int64, models.WalletEvent]{},
+			snapshots:     table[snapshotKey, models.WalletSnapshot]{},
+			audit:         table[int64, models.AuditRecord]{},
+			auditHeads:    table[string, models.AuditRecord]{},
+			checkpoints:   table[int64, models.AuditCheckpoint]{},
+			roots:         table[int64, models.BalanceRoot]{},
+			blocks:        table[int64, models.Block]{},
+			reports:       table[int64, models.ReconciliationReport]{},
+			limits:        table[string, models.WalletLimits]{},
+			counters:      table[counterKey, models.SpendCounter]{},
+			transfers:     table[uuid.UUID, models.Transfer]{},
+			reviews:       table[uuid.UUID, models.TransferReview]{},
+			multisig:      table[string, models.MultisigWallet]{},
+			signers:       table[signerKey, models.MultisigSigner]{},
+			proposals:     table[uuid.UUID, models.TransferProposal]{},
+			approvals:     table[approvalKey, models.ProposalApproval]{},
+			signerChanges: table[uuid.UUID, models.MultisigChange]{},
+			tranches:      table[uuid.UUID, models.VestingTranche]{},
+		},
+		locks: locks{held: map[string]*rowLock{}},
+	}
+}
+
+type shardKey struct {
+	address string
+	shard   int
+}
+
+type snapshotKey struct {
+	address string
+	seq     int64
+}
+
+type counterKey struct {
+	address string
+	bucket  int64
+}
+
+type signerKey struct {
+	address string
+	signer  string
+}
+
+type approvalKey struct {
+	proposal uuid.UUID
+	signer   string
+}
+
+// table holds the committed rows of one kind.
+type table[K comparable, V any] map[K]V
+
+// changes holds the rows a transaction wrote to one table; nil marks a deleted row.
+type changes[K comparable, V any] map[K]*V
+
+type tables struct {
+	wallets       table[string, models.Wallet]
+	shards        table[shardKey, models.WalletShard]
+	events        table[int64, models.WalletEvent]
+	snapshots     table[snapshotKey, models.WalletSnapshot]
+	audit         table[int64, models.AuditRecord]
+	auditHeads    table[string, models.AuditRecord]
+	checkpoints   table[int64, models.AuditCheckpoint]
+	roots         table[int64, models.BalanceRoot]
+	blocks        table[int64, models.Block]
+	reports       table[int64, models.ReconciliationReport]
+	limits        table[string, models.WalletLimits]
+	counters      table[counterKey, models.SpendCounter]
+	transfers     table[uuid.UUID, models.Transfer]
+	reviews       table[uuid.UUID, models.TransferReview]
+	multisig      table[string, models.MultisigWallet]
+	signers       table[signerKey, models.MultisigSigner]
+	proposals     table[uuid.UUID, models.TransferProposal]
+	approvals     table[approvalKey, models.ProposalApproval]
+	signerChanges table[uuid.UUID, models.MultisigChange]
+	tranches      table[uuid.UUID, models.VestingTranche]
+}
+
+// clone returns a copy of d that later commits do not change.
+func (d *tables) clone() *tables {
+	return &tables{
+		wallets:       maps.Clone(d.wallets),
+		shards:        maps.Clone(d.shards),
+		events:        maps.Clone(d.events),
+		snapshots:     maps.Clone(d.snapshots),
+		audit:         maps.Clone(d.audit),
+		auditHeads:    maps.Clone(d.auditHeads),
+		checkpoints:   maps.Clone(d.checkpoints),
+		roots:         maps.Clone(d.roots),
+		blocks:        maps.Clone(d.blocks),
+		reports:       maps.Clone(d.reports),
+		limits:        maps.Clone(d.limits),
+		counters:      maps.Clone(d.counters),
+		transfers:     maps.Clone(d.transfers),
+		reviews:       maps.Clone(d.reviews),
+		multisig:      maps.Clone(d.multisig),
+		signers:       maps.Clone(d.signers),
+		proposals:     maps.Clone(d.proposals),
+		approvals:     maps.Clone(d.approvals),
+		signerChanges: maps.Clone(d.signerChanges),
+		tranches:      maps.Clone(d.tranches),
+	}
+}
+
+type writes struct {
+	wallets       changes[string, models.Wallet]
+	shards        changes[shardKey, models.WalletShard]
+	events        changes[int64, models.WalletEvent]
+	snapshots     changes[snapshotKey, models.WalletSnapshot]
+	audit         changes[int64, models.AuditRecord]
+	auditHeads    changes[string, models.AuditRecord]
+	checkpoints   changes[int64, models.AuditCheckpoint]
+	roots         changes[int64, models.BalanceRoot]
+	blocks        changes[int64, models.Block]
+	reports       changes[int64, models.ReconciliationReport]
+	limits        changes[string, models.WalletLimits]
+	counters      changes[counterKey, models.SpendCounter]
+	transfers     changes[uuid.UUID, models.Transfer]
+	reviews       changes[uuid.UUID, models.TransferReview]
+	multisig      changes[string, models.MultisigWallet]
+	signers       changes[signerKey, models.MultisigSigner]
+	proposals     changes[uuid.UUID, models.TransferProposal]
+	approvals     changes[approvalKey, models.ProposalApproval]
+	signerChanges changes[uuid.UUID, models.MultisigChange]
+	tranches      changes[uuid.UUID, models.VestingTranche]
+}
+
+// clone returns a copy of w that later writes do not change. Rows are never modified in
+// place, so the maps can share them.
+func (w writes) clone() writes {
+	return writes{
+		wallets:       maps.Clone(w.wallets),
+		shards:        maps.Clone(w.shards),
+		events:        maps.Clone(w.events),
+		snapshots:     maps.Clone(w.snapshots),
+		audit:         maps.Clone(w.audit),
+		auditHeads:    maps.Clone(w.auditHeads),
+		checkpoints:   maps.Clone(w.checkpoints),
+		roots:         maps.Clone(w.roots),
+		blocks:        maps.Clone(w.blocks),
+		reports:       maps.Clone(w.reports),
+		limits:        maps.Clone(w.limits),
+		counters:      maps.Clone(w.counters),
+		transfers:     maps.Clone(w.transfers),
+		reviews:       maps.Clone(w.reviews),
+		multisig:      maps.Clone(w.multisig),
+		signers:       maps.Clone(w.signers),
+		proposals:     maps.Clone(w.proposals),
+		approvals:     maps.Clone(w.approvals),
+		signerChanges: maps.Clone(w.signerChanges),
+		tranches:      maps.Clone(w.tranches),
+	}
+}
+
+// get returns the row with the given key as seen by a transaction that made pending.
+func get[K comparable, V any](s *Store, committed table[K, V], pending changes[K, V], key K) (*V, bool) {
+	if row, ok := pending[key]; ok {
+		if row == nil {
+			return nil, false
+		}
+		found := *row
+		return &found, true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	row, ok := committed[key]
+	if !ok {
+		return nil, false
+	}
+	return &row, true
+}
+
+// scan returns copies of the rows matching match as seen by a transaction that made pending.
+func scan[K comparable, V any](s *Store, committed table[K, V], pending changes[K, V], match func(*V) bool) []*V {
+	var rows []*V
+	s.mu.RLock()
+	for key, row := range committed {
+		if _, ok := pending[key]; ok {
+			continue
+		}
+		if match(&row) {
+			rows = append(rows, &row)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, row := range pending {
+		if row != nil && match(row) {
+			found := *row
+			rows = append(rows, &found)
+		}
+	}
+	return rows
+}
+
+func put[K comparable, V any](pending changes[K, V], key K, row V) {
+	pending[key] = &row
+}
+
+func apply[K comparable, V any](committed table[K, V], pending changes[K, V]) {
+	for key, row := range pending {
+		if row == nil {
+			delete(committed, key)
+		} else {
+			committed[key] = *row
+		}
+	}
+}
+
+func (s *Store) begin() *txn {
+	return &txn{
+		store: s,
+		writes: writes{
+			wallets:       changes[string, models.Wallet]{},
+			shards:        changes[shardKey, models.WalletShard]{},
+			events:        changes[int64, models.WalletEvent]{},
+			snapshots:     changes[snapshotKey, models.WalletSnapshot]{},
+			audit:         changes[int64, models.AuditRecord]{},
+			auditHeads:    changes[string, models.AuditRecord]{},
+			checkpoints:   changes[int64, models.AuditCheckpoint]{},
+			roots:         changes[int64, models.BalanceRoot]{},
+			blocks:        changes[int64, models.Block]{},
+			reports:       changes[int64, models.ReconciliationReport]{},
+			limits:        changes[string, models.WalletLimits]{},
+			counters:      changes[counterKey, models.SpendCounter]{},
+			transfers:     changes[uuid.UUID, models.Transfer]{},
+			reviews:       changes[uuid.UUID, models.TransferReview]{},
+			multisig:      changes[string, models.MultisigWallet]{},
+			signers:       changes[signerKey, models.MultisigSigner]{},
+			proposals:     changes[uuid.UUID, models.TransferProposal]{},
+			approvals:     changes[approvalKey, models.ProposalApproval]{},
+			signerChanges: changes[uuid.UUID, models.MultisigChange]{},
+			tranches:      changes[uuid.UUID, models.VestingTranche]{},
+		},
+	}
+}
+
+// commit publishes the writes of t and then releases its locks, so that a transaction
+// waiting for one of them reads the committed row.
+func (s *Store) commit(t *txn) {
+	s.mu.Lock()
+	apply(s.data.wallets, t.writes.wallets)
+	apply(s.data.shards, t.writes.shards)
+	apply(s.data.events, t.writes.events)
+	apply(s.data.snapshots, t.writes.snapshots)
+	apply(s.data.audit, t.writes.audit)
+	apply(s.data.auditHeads, t.writes.auditHeads)
+	apply(s.data.checkpoints, t.writes.checkpoints)
+	apply(s.data.roots, t.writes.roots)
+	apply(s.data.blocks, t.writes.blocks)
+	apply(s.data.reports, t.writes.reports)
+	apply(s.data.limits, t.writes.limits)
+	apply(s.data.counters, t.writes.counters)
+	apply(s.data.transfers, t.writes.transfers)
+	apply(s.data.reviews, t.writes.reviews)
+	apply(s.data.multisig, t.writes.multisig)
+	apply(s.data.signers, t.writes.signers)
+	apply(s.data.proposals, t.writes.proposals)
+	apply(s.data.approvals, t.writes.approvals)
+	apply(s.data.signerChanges, t.writes.signerChanges)
+	apply(s.data.tranches, t.writes.tranches)
+	s.mu.Unlock()
+
+	s.locks.releaseAll(t)
+}
+
+func (s *Store) Transaction(ctx context.Context, fn func(tx store.Tx) error) error {
+	t := s.begin()
+	defer func() {
+		if r := recover(); r != nil {
+			s.locks.releaseAll(t)
+			panic(r)
+		}
+	}()
+
+	if err := fn(t); err != nil {
+		s.locks.releaseAll(t)
+		return err
+	}
+	s.commit(t)
+	return nil
+}
+
+// Snapshot is Transaction, except that fn reads the rows committed when it starts and
+// does not see later commits. Its writes still take locks and commit as usual.
+func (s *Store) Snapshot(ctx context.Context, fn func(tx store.Tx) error) error {
+	s.mu.RLock()
+	snapshot := s.data.clone()
+	s.mu.RUnlock()
+
+	return s.Transaction(ctx, func(tx store.Tx) error {
+		tx.(*txn).snapshot = snapshot
+		return fn(tx)
+	})
+}
+
+// run executes fn in a transaction of its own.
+func run[T any](s *Store, ctx context.Context, fn func(t *txn) (T, error)) (T, error) {
+	var result T
+	err := s.Transaction(ctx, func(tx store.Tx) error {
+		var err error
+		result, err = fn(tx.(*txn))
+		return err
+	})
+	return result, err
+}
+
+// exec executes fn in a transaction of its own.
+func exec(s *Store, ctx context.Context, fn func(t *txn) error) error {
+	return s.Transaction(ctx, func(tx store.Tx) error {
+		return fn(tx.(*txn))
+	})
+}
+
+// byCreation sorts rows by creation time, breaking ties by ID.
+func byCreation[V any](rows []*V, createdAt func(*V) time.Time, id func(*V) string) {
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := createdAt(rows[i]), createdAt(rows[j])
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return id(rows[i]) < id(rows[j])
+	})
+}
@@ -0,0 +1,1047 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+
+	"github.com/google/uuid"
+)
+
+// txn is a transaction of a Store. Its writes are only visible to itself until it commits.
+type txn struct {
+	store  *Store
+	writes writes
+	locked []string
+	// snapshot holds the committed rows as they were when a transaction started by
+	// Snapshot began. Other transactions read the rows committed at the time they read.
+	snapshot *tables
+}
+
+var _ store.Tx = (*txn)(nil)
+
+// data returns the committed rows t reads.
+func (t *txn) data() *tables {
+	if t.snapshot != nil {
+		return t.snapshot
+	}
+	return &t.store.data
+}
+
+func (t *txn) lock(ctx context.Context, kind string, key any) error {
+	return t.store.locks.acquire(ctx, t, fmt.Sprintf("%s/%v", kind, key))
+}
+
+// Transaction runs fn in a savepoint of t: the writes fn made are discarded and the locks
+// it took released if it fails, as rolling back to a savepoint does in Postgres.
+func (t *txn) Transaction(ctx context.Context, fn func(tx store.Tx) error) error {
+	saved, locked := t.writes.clone(), len(t.locked)
+	if err := fn(t); err != nil {
+		t.writes = saved
+		t.store.locks.release(t, locked)
+		return err
+	}
+	return nil
+}
+
+func (t *txn) Wallet(ctx context.Context, address string) (*models.Wallet, error) {
+	wallet, ok := get(t.store, t.data().wallets, t.writes.wallets, address)
+	if !ok {
+		return nil, models.ErrWalletNotFound
+	}
+	return wallet, nil
+}
+
+func (t *txn) LockWallet(ctx context.Context, address string) (*models.Wallet, error) {
+	if err := t.lock(ctx, "wallet", address); err != nil {
+		return nil, err
+	}
+	return t.Wallet(ctx, address)
+}
+
+func (t *txn) CreateWallet(ctx context.Context, address string, balance int) error {
+	if err := models.ValidateWallet(address, balance); err != nil {
+		return err
+	}
+	if err := t.lock(ctx, "wallet", address); err != nil {
+		return err
+	}
+	if _, ok := get(t.store, t.data().wallets, t.writes.wallets, address); ok {
+		return nil
+	}
+	put(t.writes.wallets, address, models.Wallet{
+		ID:      uuid.New(),
+		Address: address,
+		Balance: balance,
+		Version: 1,
+	})
+	if err := t.AppendAuditRecords(ctx, &models.AuditRecord{Account: models.WalletAccount(address), Address: address, Operation: models.AuditCreate, Balance: balance}); err != nil {
+		return err
+	}
+	return t.AppendEvents(ctx, &models.WalletEvent{Address: address, Type: models.EventWalletCreated, Amount: balance, CreatedAt: time.Now()})
+}
+
+func (t *txn) Wallets(ctx context.Context) ([]*models.Wallet, error) {
+	wallets := scan(t.store, t.data().wallets, t.writes.wallets, func(*models.Wallet) bool { return true })
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].Address < wallets[j].Address })
+	return wallets, nil
+}
+
+func (t *txn) WalletsAfter(ctx context.Context, after string, limit int) ([]*models.Wallet, error) {
+	wallets := scan(t.store, t.data().wallets, t.writes.wallets, func(wallet *models.Wallet) bool { return wallet.Address > after })
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].Address < wallets[j].Address })
+	if len(wallets) > limit {
+		wallets = wallets[:limit]
+	}
+	return wallets, nil
+}
+
+func (t *txn) SaveWallet(ctx context.Context, wallet *models.Wallet) error {
+	if err := t.lock(ctx, "wallet", wallet.Address); err != nil {
+		return err
+	}
+	if wallet.ID == uuid.Nil {
+		wallet.ID = uuid.New()
+	}
+	put(t.writes.wallets, wallet.Address, *wallet)
+	return nil
+}
+
+func (t *txn) VerifyWallet(ctx context.Context, address string) (*models.Wallet, error) {
+	wallet, err := t.LockWallet(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.Verified {
+		return wallet, nil
+	}
+	wallet.Verified = true
+	put(t.writes.wallets, address, *wallet)
+	return wallet, t.AppendEvents(ctx, &models.WalletEvent{Address: address, Type: models.EventVerified, CreatedAt: time.Now()})
+}
+
+func (t *txn) SetWalletFrozen(ctx context.Context, address string, frozen bool) (*models.Wallet, error) {
+	wallet, err := t.LockWallet(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.Frozen == frozen {
+		return wallet, nil
+	}
+	wallet.Frozen = frozen
+	put(t.writes.wallets, address, *wallet)
+	return wallet, t.AppendEvents(ctx, models.FreezeEvent(address, frozen))
+}
+
+func (t *txn) WalletLimits(ctx context.Context, address string) (*models.WalletLimits, error) {
+	limits, ok := get(t.store, t.data().limits, t.writes.limits, address)
+	if !ok {
+		return nil, nil
+	}
+	return limits, nil
+}
+
+func (t *txn) SetWalletLimits(ctx context.Context, limits models.WalletLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	if _, err := t.Wallet(ctx, limits.Address); err != nil {
+		return err
+	}
+	if err := t.lock(ctx, "limits", limits.Address); err != nil {
+		return err
+	}
+	put(t.writes.limits, limits.Address, limits)
+	return nil
+}
+
+func (t *txn) WalletShards(ctx context.Context, address string) ([]*models.WalletShard, error) {
+	shards := scan(t.store, t.data().shards, t.writes.shards, func(shard *models.WalletShard) bool {
+		return shard.Address == address
+	})
+	sort.Slice(shards, func(i, j int) bool { return shards[i].Shard < shards[j].Shard })
+	return shards, nil
+}
+
+func (t *txn) ShardedWallets(ctx context.Context) ([]string, error) {
+	sharded := map[string]bool{}
+	for _, shard := range scan(t.store, t.data().shards, t.writes.shards, func(*models.WalletShard) bool { return true }) {
+		sharded[shard.Address] = true
+	}
+	addresses := make([]string, 0, len(sharded))
+	for address := range sharded {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+func (t *txn) LockWalletShard(ctx context.Context, address string, shard int) (*models.WalletShard, error) {
+	key := shardKey{address: address, shard: shard}
+	if err := t.lock(ctx, "shard", key); err != nil {
+		return nil, err
+	}
+	found, ok := get(t.store, t.data().shards, t.writes.shards, key)
+	if !ok {
+		return nil, models.ErrShardNotFound
+	}
+	return found, nil
+}
+
+func (t *txn) SaveWalletShard(ctx context.Context, shard *models.WalletShard) error {
+	key := shardKey{address: shard.Address, shard: shard.Shard}
+	if err := t.lock(ctx, "shard", key); err != nil {
+		return err
+	}
+	put(t.writes.shards, key, *shard)
+	return nil
+}
+
+func (t *txn) DeleteWalletShards(ctx context.Context, address string, from int) error {
+	shards, err := t.WalletShards(ctx, address)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if shard.Shard < from {
+			continue
+		}
+		key := shardKey{address: address, shard: shard.Shard}
+		if err := t.lock(ctx, "shard", key); err != nil {
+			return err
+		}
+		t.writes.shards[key] = nil
+	}
+	return nil
+}
+
+func (t *txn) AppendEvents(ctx context.Context, events ...*models.WalletEvent) error {
+	for _, event := range events {
+		event.Seq = t.store.seq.Add(1)
+		put(t.writes.events, event.Seq, *event)
+	}
+	return nil
+}
+
+func (t *txn) WalletEvents(ctx context.Context, address string, after int64) ([]*models.WalletEvent, error) {
+	events := scan(t.store, t.data().events, t.writes.events, func(event *models.WalletEvent) bool {
+		return event.Address == address && event.Seq > after
+	})
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events, nil
+}
+
+func (t *txn) WalletEventsAsOf(ctx context.Context, address string, after int64, at time.Time) ([]*models.WalletEvent, error) {
+	events := scan(t.store, t.data().events, t.writes.events, func(event *models.WalletEvent) bool {
+		return event.Address == address && event.Seq > after && !event.CreatedAt.After(at)
+	})
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events, nil
+}
+
+func (t *txn) EventAddresses(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	for _, event := range scan(t.store, t.data().events, t.writes.events, func(*models.WalletEvent) bool { return true }) {
+		seen[event.Address] = true
+	}
+	addresses := make([]string, 0, len(seen))
+	for address := range seen {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+func (t *txn) FirstEvent(ctx context.Context, address string) (*models.WalletEvent, error) {
+	var first *models.WalletEvent
+	for _, event := range scan(t.store, t.data().events, t.writes.events, func(event *models.WalletEvent) bool {
+		return event.Address == address
+	}) {
+		if first == nil || event.Seq < first.Seq {
+			first = event
+		}
+	}
+	return first, nil
+}
+
+func (t *txn) EventTotals(ctx context.Context, address *string) (map[string]int, error) {
+	totals := map[string]int{}
+	for _, event := range scan(t.store, t.data().events, t.writes.events, func(event *models.WalletEvent) bool {
+		return address == nil || event.Address == *address
+	}) {
+		totals[event.Type] += event.Amount
+	}
+	return totals, nil
+}
+
+func (t *txn) LatestSnapshot(ctx context.Context, address string) (*models.WalletSnapshot, error) {
+	var latest *models.WalletSnapshot
+	for _, snapshot := range scan(t.store, t.data().snapshots, t.writes.snapshots, func(snapshot *models.WalletSnapshot) bool {
+		return snapshot.Address == address
+	}) {
+		if latest == nil || snapshot.Seq > latest.Seq {
+			latest = snapshot
+		}
+	}
+	return latest, nil
+}
+
+func (t *txn) SnapshotAsOf(ctx context.Context, address string, at time.Time) (*models.WalletSnapshot, error) {
+	var latest *models.WalletSnapshot
+	for _, snapshot := range scan(t.store, t.data().snapshots, t.writes.snapshots, func(snapshot *models.WalletSnapshot) bool {
+		return snapshot.Address == address && !snapshot.CreatedAt.After(at)
+	}) {
+		if latest == nil || snapshot.Seq > latest.Seq {
+			latest = snapshot
+		}
+	}
+	return latest, nil
+}
+
+func (t *txn) SnapshotsAsOf(ctx context.Context, addresses []string, at time.Time) ([]*models.WalletSnapshot, error) {
+	latest := map[string]*models.WalletSnapshot{}
+	for _, snapshot := range scan(t.store, t.data().snapshots, t.writes.snapshots, func(snapshot *models.WalletSnapshot) bool {
+		return slices.Contains(addresses, snapshot.Address) && !snapshot.CreatedAt.After(at)
+	}) {
+		if current := latest[snapshot.Address]; current == nil || snapshot.Seq > current.Seq {
+			latest[snapshot.Address] = snapshot
+		}
+	}
+	return slices.Collect(maps.Values(latest)), nil
+}
+
+func (t *txn) EventsAsOf(ctx context.Context, addresses []string, at time.Time) ([]*models.WalletEvent, error) {
+	snapshots, err := t.SnapshotsAsOf(ctx, addresses, at)
+	if err != nil {
+		return nil, err
+	}
+	after := map[string]int64{}
+	for _, snapshot := range snapshots {
+		after[snapshot.Address] = snapshot.Seq
+	}
+	events := scan(t.store, t.data().events, t.writes.events, func(event *models.WalletEvent) bool {
+		return slices.Contains(addresses, event.Address) && event.Seq > after[event.Address] && !event.CreatedAt.After(at)
+	})
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events, nil
+}
+
+func (t *txn) SaveSnapshot(ctx context.Context, snapshot *models.WalletSnapshot) error {
+	key := snapshotKey{address: snapshot.Address, seq: snapshot.Seq}
+	if _, ok := get(t.store, t.data().snapshots, t.writes.snapshots, key); ok {
+		return fmt.Errorf("snapshot of %s at %d already exists", snapshot.Address, snapshot.Seq)
+	}
+	put(t.writes.snapshots, key, *snapshot)
+	return nil
+}
+
+func (t *txn) AppendAuditRecords(ctx context.Context, records ...*models.AuditRecord) error {
+	for _, record := range records {
+		prev := ""
+		if head, ok := get(t.store, t.data().auditHeads, t.writes.auditHeads, record.Account); ok {
+			prev = head.Hash
+		}
+		record.Seal(prev)
+		record.Seq = t.store.auditSeq.Add(1)
+		put(t.writes.audit, record.Seq, *record)
+		put(t.writes.auditHeads, record.Account, *record)
+	}
+	return nil
+}
+
+func (t *txn) AuditRecords(ctx context.Context, address string, after int64, limit int) ([]*models.AuditRecord, error) {
+	records := scan(t.store, t.data().audit, t.writes.audit, func(record *models.AuditRecord) bool {
+		return record.Address == address && record.Seq > after
+	})
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+func (t *txn) AuditAddresses(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	for _, head := range scan(t.store, t.data().auditHeads, t.writes.auditHeads, func(*models.AuditRecord) bool { return true }) {
+		seen[head.Address] = true
+	}
+	addresses := make([]string, 0, len(seen))
+	for address := range seen {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+func (t *txn) AuditHeads(ctx context.Context) ([]*models.AuditRecord, error) {
+	heads := scan(t.store, t.data().auditHeads, t.writes.auditHeads, func(*models.AuditRecord) bool { return true })
+	sort.Slice(heads, func(i, j int) bool { return heads[i].Account < heads[j].Account })
+	return heads, nil
+}
+
+func (t *txn) AuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error) {
+	checkpoints := scan(t.store, t.data().checkpoints, t.writes.checkpoints, func(*models.AuditCheckpoint) bool { return true })
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].ID < checkpoints[j].ID })
+	for _, checkpoint := range checkpoints {
+		checkpoint.Entries = slices.Clone(checkpoint.Entries)
+	}
+	return checkpoints, nil
+}
+
+func (t *txn) LatestAuditCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	checkpoints, err := t.AuditCheckpoints(ctx)
+	if err != nil || len(checkpoints) == 0 {
+		return nil, err
+	}
+	latest := checkpoints[len(checkpoints)-1]
+	latest.Entries = nil
+	return latest, nil
+}
+
+func (t *txn) CheckpointedHeads(ctx context.Context) ([]*models.AuditCheckpointEntry, error) {
+	checkpoints, err := t.AuditCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]*models.AuditCheckpointEntry{}
+	for _, checkpoint := range checkpoints {
+		for _, entry := range checkpoint.Entries {
+			latest[entry.Account] = &entry
+		}
+	}
+	entries := make([]*models.AuditCheckpointEntry, 0, len(latest))
+	for _, entry := range latest {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Account < entries[j].Account })
+	return entries, nil
+}
+
+func (t *txn) SaveAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	if err := t.lock(ctx, "audit_checkpoint", checkpoint.PrevHash); err != nil {
+		return err
+	}
+	following := scan(t.store, t.data().checkpoints, t.writes.checkpoints, func(existing *models.AuditCheckpoint) bool {
+		return existing.PrevHash == checkpoint.PrevHash
+	})
+	if len(following) > 0 {
+		return fmt.Errorf("checkpoint %d already follows %q", following[0].ID, checkpoint.PrevHash)
+	}
+	checkpoint.ID = t.store.checkpointSeq.Add(1)
+	for i := range checkpoint.Entries {
+		checkpoint.Entries[i].CheckpointID = checkpoint.ID
+	}
+	saved := *checkpoint
+	saved.Entries = slices.Clone(checkpoint.Entries)
+	put(t.writes.checkpoints, checkpoint.ID, saved)
+	return nil
+}
+
+func (t *txn) SaveBalanceRoot(ctx context.Context, root *models.BalanceRoot) error {
+	root.Seq = t.store.rootSeq.Add(1)
+	for i := range root.Leaves {
+		root.Leaves[i].RootSeq = root.Seq
+	}
+	saved := *root
+	saved.Leaves = slices.Clone(root.Leaves)
+	put(t.writes.roots, root.Seq, saved)
+	return nil
+}
+
+func (t *txn) LatestBalanceRoot(ctx context.Context) (*models.BalanceRoot, error) {
+	var latest *models.BalanceRoot
+	for _, root := range scan(t.store, t.data().roots, t.writes.roots, func(*models.BalanceRoot) bool { return true }) {
+		if latest == nil || root.Seq > latest.Seq {
+			latest = root
+		}
+	}
+	if latest != nil {
+		latest.Leaves = nil
+	}
+	return latest, nil
+}
+
+func (t *txn) BalanceRoot(ctx context.Context, root string) (*models.BalanceRoot, error) {
+	var latest *models.BalanceRoot
+	for _, found := range scan(t.store, t.data().roots, t.writes.roots, func(found *models.BalanceRoot) bool { return found.Root == root }) {
+		if latest == nil || found.Seq > latest.Seq {
+			latest = found
+		}
+	}
+	if latest == nil {
+		return nil, models.ErrBalanceRootNotFound
+	}
+	latest.Leaves = slices.Clone(latest.Leaves)
+	return latest, nil
+}
+
+func (t *txn) UnsealedTransfers(ctx context.Context) ([]*models.Transfer, error) {
+	transfers := scan(t.store, t.data().transfers, t.writes.transfers, func(transfer *models.Transfer) bool {
+		return transfer.BlockNumber == nil
+	})
+	byCreation(transfers, func(transfer *models.Transfer) time.Time { return transfer.CreatedAt }, func(transfer *models.Transfer) string { return transfer.ID.String() })
+	return transfers, nil
+}
+
+func (t *txn) SaveBlock(ctx context.Context, block *models.Block, transfers []*models.Transfer) error {
+	if err := t.lock(ctx, "block", block.Number); err != nil {
+		return err
+	}
+	// Like the constraints of the database, these checks see the rows committed since a
+	// snapshot was taken, so that two transactions cannot seal the same block.
+	committed := &t.store.data
+	if _, ok := get(t.store, committed.blocks, t.writes.blocks, block.Number); ok {
+		return fmt.Errorf("block %d already exists", block.Number)
+	}
+	number := block.Number
+	for _, transfer := range transfers {
+		if err := t.lock(ctx, "transfer", transfer.ID); err != nil {
+			return err
+		}
+		sealed, ok := get(t.store, committed.transfers, t.writes.transfers, transfer.ID)
+		if !ok || sealed.BlockNumber != nil {
+			return fmt.Errorf("transfers of block %d are already sealed", block.Number)
+		}
+		sealed.BlockNumber = &number
+		put(t.writes.transfers, transfer.ID, *sealed)
+	}
+	put(t.writes.blocks, block.Number, *block)
+	for _, transfer := range transfers {
+		transfer.BlockNumber = &number
+	}
+	return nil
+}
+
+func (t *txn) LatestBlock(ctx context.Context) (*models.Block, error) {
+	var latest *models.Block
+	for _, block := range scan(t.store, t.data().blocks, t.writes.blocks, func(*models.Block) bool { return true }) {
+		if latest == nil || block.Number > latest.Number {
+			latest = block
+		}
+	}
+	return latest, nil
+}
+
+func (t *txn) Block(ctx context.Context, number int64) (*models.Block, error) {
+	block, ok := get(t.store, t.data().blocks, t.writes.blocks, number)
+	if !ok {
+		return nil, models.ErrBlockNotFound
+	}
+	return block, nil
+}
+
+func (t *txn) BlockTransfers(ctx context.Context, number int64) ([]*models.Transfer, error) {
+	transfers := scan(t.store, t.data().transfers, t.writes.transfers, func(transfer *models.Transfer) bool {
+		return transfer.BlockNumber != nil && *transfer.BlockNumber == number
+	})
+	byCreation(transfers, func(transfer *models.Transfer) time.Time { return transfer.CreatedAt }, func(transfer *models.Transfer) string { return transfer.ID.String() })
+	return transfers, nil
+}
+
+func (t *txn) SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error {
+	report.ID = t.store.reportSeq.Add(1)
+	for i := range report.Discrepancies {
+		report.Discrepancies[i].ReportID = report.ID
+		report.Discrepancies[i].Position = i
+	}
+	saved := *report
+	saved.Discrepancies = slices.Clone(report.Discrepancies)
+	put(t.writes.reports, report.ID, saved)
+	return nil
+}
+
+func (t *txn) ReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	reports := scan(t.store, t.data().reports, t.writes.reports, func(*models.ReconciliationReport) bool { return true })
+	sort.Slice(reports, func(i, j int) bool { return reports[i].ID > reports[j].ID })
+	if len(reports) > limit {
+		reports = reports[:limit]
+	}
+	for _, report := range reports {
+		report.Discrepancies = slices.Clone(report.Discrepancies)
+	}
+	return reports, nil
+}
+
+func (t *txn) RecordTransfer(ctx context.Context, fromAddress string, toAddress string, amount int, at time.Time) (*models.Transfer, error) {
+	transfer := models.Transfer{
+		ID:          uuid.New(),
+		FromAddress: fromAddress,
+		ToAddress:   toAddress,
+		Amount:      amount,
+		CreatedAt:   at,
+	}
+	put(t.writes.transfers, transfer.ID, transfer)
+	return &transfer, nil
+}
+
+func (t *txn) CountRecipientsSince(ctx context.Context, address string, since time.Time) (int, error) {
+	recipients := map[string]bool{}
+	for _, transfer := range scan(t.store, t.data().transfers, t.writes.transfers, func(transfer *models.Transfer) bool {
+		return transfer.FromAddress == address && !transfer.CreatedAt.Before(since)
+	}) {
+		recipients[transfer.ToAddress] = true
+	}
+	return len(recipients), nil
+}
+
+func (t *txn) HasTransferred(ctx context.Context, fromAddress string, toAddress string) (bool, error) {
+	transfers := scan(t.store, t.data().transfers, t.writes.transfers, func(transfer *models.Transfer) bool {
+		return transfer.FromAddress == fromAddress && transfer.ToAddress == toAddress
+	})
+	return len(transfers) > 0, nil
+}
+
+func (t *txn) TransferTotals(ctx context.Context, address string, since time.Time) (int, int, error) {
+	received, sent := 0, 0
+	for _, transfer := range scan(t.store, t.data().transfers, t.writes.transfers, func(transfer *models.Transfer) bool {
+		return (transfer.ToAddress == address || transfer.FromAddress == address) && !transfer.CreatedAt.Before(since)
+	}) {
+		if transfer.ToAddress == address {
+			received += transfer.Amount
+		}
+		if transfer.FromAddress == address {
+			sent += transfer.Amount
+		}
+	}
+	return received, sent, nil
+}
+
+func (t *txn) TransfersAfter(ctx context.Context, after *models.Transfer, limit int) ([]*models.Transfer, error) {
+	transfers := scan(t.store, t.data().transfers, t.writes.transfers, func(transfer *models.Transfer) bool {
+		if after == nil || transfer.CreatedAt.After(after.CreatedAt) {
+			return true
+		}
+		return transfer.CreatedAt.Equal(after.CreatedAt) && transfer.ID.String() > after.ID.String()
+	})
+	byCreation(transfers, func(transfer *models.Transfer) time.Time { return transfer.CreatedAt }, func(transfer *models.Transfer) string { return transfer.ID.String() })
+	if len(transfers) > limit {
+		transfers = transfers[:limit]
+	}
+	return transfers, nil
+}
+
+func (t *txn) ImportTransfers(ctx context.Context, transfers []*models.Transfer) error {
+	for _, transfer := range transfers {
+		if err := t.lock(ctx, "transfer", transfer.ID); err != nil {
+			return err
+		}
+		if _, ok := get(t.store, t.data().transfers, t.writes.transfers, transfer.ID); ok {
+			return fmt.Errorf("transfer %s already exists", transfer.ID)
+		}
+		number := models.PreGenesisBlock
+		transfer.BlockNumber = &number
+		put(t.writes.transfers, transfer.ID, *transfer)
+	}
+	return nil
+}
+
+func (t *txn) RecordSpend(ctx context.Context, address string, amount int, at time.Time) error {
+	bucket := at.UTC().Truncate(models.SpendBucket)
+	key := counterKey{address: address, bucket: bucket.UnixNano()}
+	if err := t.lock(ctx, "counter", key); err != nil {
+		return err
+	}
+
+	counter, ok := get(t.store, t.data().counters, t.writes.counters, key)
+	if !ok {
+		counter = &models.SpendCounter{Address: address, BucketStart: bucket}
+	}
+	counter.Amount += amount
+	counter.Transfers++
+	put(t.writes.counters, key, *counter)
+	return nil
+}
+
+func (t *txn) SpentSince(ctx context.Context, address string, since time.Time) (int, int, error) {
+	cutoff := since.UTC().Truncate(models.SpendBucket)
+	amount, transfers := 0, 0
+	for _, counter := range scan(t.store, t.data().counters, t.writes.counters, func(counter *models.SpendCounter) bool {
+		return counter.Address == address && !counter.BucketStart.Before(cutoff)
+	}) {
+		amount += counter.Amount
+		transfers += counter.Transfers
+	}
+	return amount, transfers, nil
+}
+
+func (t *txn) PruneSpendCounters(ctx context.Context, address string, now time.Time) error {
+	cutoff := now.UTC().Add(-models.SpendRetention)
+	for _, counter := range scan(t.store, t.data().counters, t.writes.counters, func(counter *models.SpendCounter) bool {
+		return counter.Address == address && counter.BucketStart.Before(cutoff)
+	}) {
+		key := counterKey{address: address, bucket: counter.BucketStart.UnixNano()}
+		if err := t.lock(ctx, "counter", key); err != nil {
+			return err
+		}
+		t.writes.counters[key] = nil
+	}
+	return nil
+}
+
+func (t *txn) CreateReview(ctx context.Context, review *models.TransferReview) error {
+	review.ID = uuid.New()
+	if review.Status == "" {
+		review.Status = models.ReviewPending
+	}
+	if review.CreatedAt.IsZero() {
+		review.CreatedAt = time.Now()
+	}
+	put(t.writes.reviews, review.ID, *review)
+	return nil
+}
+
+func (t *txn) LockPendingReview(ctx context.Context, id string) (*models.TransferReview, error) {
+	reviewID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, models.ErrReviewNotFound
+	}
+	if err := t.lock(ctx, "review", reviewID); err != nil {
+		return nil, err
+	}
+
+	review, ok := get(t.store, t.data().reviews, t.writes.reviews, reviewID)
+	if !ok {
+		return nil, models.ErrReviewNotFound
+	}
+	if review.Status != models.ReviewPending {
+		return nil, models.ErrReviewDecided
+	}
+	return review, nil
+}
+
+func (t *txn) SaveReview(ctx context.Context, review *models.TransferReview) error {
+	if err := t.lock(ctx, "review", review.ID); err != nil {
+		return err
+	}
+	put(t.writes.reviews, review.ID, *review)
+	return nil
+}
+
+func (t *txn) Reviews(ctx context.Context, status *string) ([]*models.TransferReview, error) {
+	reviews := scan(t.store, t.data().reviews, t.writes.reviews, func(review *models.TransferReview) bool {
+		return status == nil || review.Status == *status
+	})
+	byCreation(reviews,
+		func(review *models.TransferReview) time.Time { return review.CreatedAt },
+		func(review *models.TransferReview) string { return review.ID.String() })
+	return reviews, nil
+}
+
+func (t *txn) CreateMultisigWallet(ctx context.Context, address string, signers map[string]string, threshold int) (*models.MultisigWallet, error) {
+	if err := models.ValidateMultisig(signers, threshold); err != nil {
+		return nil, err
+	}
+	if _, err := t.Wallet(ctx, address); err != nil {
+		return nil, err
+	}
+	if err := t.lock(ctx, "multisig", address); err != nil {
+		return nil, err
+	}
+	if _, ok := get(t.store, t.data().multisig, t.writes.multisig, address); ok {
+		return nil, models.ErrMultisigExists
+	}
+
+	multisig := models.MultisigWallet{Address: address, Threshold: threshold}
+	put(t.writes.multisig, address, multisig)
+	t.putSigners(address, signers)
+	return &multisig, nil
+}
+
+func (t *txn) ReplaceMultisigSigners(ctx context.Context, address string, signers map[string]string, threshold int) (*models.MultisigWallet, error) {
+	if err := models.ValidateMultisig(signers, threshold); err != nil {
+		return nil, err
+	}
+	locked, err := t.LockMultisigWallet(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if locked == nil {
+		return nil, models.ErrNotMultisig
+	}
+
+	multisig := models.MultisigWallet{Address: address, Threshold: threshold}
+	put(t.writes.multisig, address, multisig)
+	for _, signer := range scan(t.store, t.data().signers, t.writes.signers, func(signer *models.MultisigSigner) bool {
+		return signer.Address == address
+	}) {
+		t.writes.signers[signerKey{address: address, signer: signer.Signer}] = nil
+	}
+	t.putSigners(address, signers)
+
+	pending := func(status string) bool { return status == models.ProposalPending }
+	for _, candidate := range scan(t.store, t.data().proposals, t.writes.proposals, func(proposal *models.TransferProposal) bool {
+		return proposal.FromAddress == address && pending(proposal.Status)
+	}) {
+		proposal, err := t.LockProposal(ctx, candidate.ID.String())
+		if err != nil {
+			return nil, err
+		}
+		if !pending(proposal.Status) {
+			continue
+		}
+		proposal.Status = models.ProposalExpired
+		put(t.writes.proposals, proposal.ID, *proposal)
+	}
+	for _, candidate := range scan(t.store, t.data().signerChanges, t.writes.signerChanges, func(change *models.MultisigChange) bool {
+		return change.Address == address && pending(change.Status)
+	}) {
+		change, err := t.LockMultisigChange(ctx, candidate.ID.String())
+		if err != nil {
+			return nil, err
+		}
+		if !pending(change.Status) {
+			continue
+		}
+		change.Status = models.ProposalExpired
+		put(t.writes.signerChanges, change.ID, *change)
+	}
+	return &multisig, nil
+}
+
+func (t *txn) putSigners(address string, signers map[string]string) {
+	for name, publicKey := range signers {
+		put(t.writes.signers, signerKey{address: address, signer: name}, models.MultisigSigner{
+			Address:   address,
+			Signer:    name,
+			PublicKey: publicKey,
+		})
+	}
+}
+
+func (t *txn) MultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error) {
+	multisig, ok := get(t.store, t.data().multisig, t.writes.multisig, address)
+	if !ok {
+		return nil, nil
+	}
+	return multisig, nil
+}
+
+func (t *txn) LockMultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error) {
+	if err := t.lock(ctx, "multisig", address); err != nil {
+		return nil, err
+	}
+	return t.MultisigWallet(ctx, address)
+}
+
+func (t *txn) Signer(ctx context.Context, address string, signer string) (*models.MultisigSigner, error) {
+	found, ok := get(t.store, t.data().signers, t.writes.signers, signerKey{address: address, signer: signer})
+	if !ok {
+		return nil, models.ErrNotSigner
+	}
+	return found, nil
+}
+
+func (t *txn) Signers(ctx context.Context, address string) ([]string, error) {
+	names := []string{}
+	for _, signer := range scan(t.store, t.data().signers, t.writes.signers, func(signer *models.MultisigSigner) bool {
+		return signer.Address == address
+	}) {
+		names = append(names, signer.Signer)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (t *txn) CreateProposal(ctx context.Context, proposal *models.TransferProposal) error {
+	proposal.ID = uuid.New()
+	if proposal.Status == "" {
+		proposal.Status = models.ProposalPending
+	}
+	if proposal.CreatedAt.IsZero() {
+		proposal.CreatedAt = time.Now()
+	}
+	put(t.writes.proposals, proposal.ID, *proposal)
+	return nil
+}
+
+func (t *txn) LockProposal(ctx context.Context, id string) (*models.TransferProposal, error) {
+	proposalID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, models.ErrProposalNotFound
+	}
+	if err := t.lock(ctx, "proposal", proposalID); err != nil {
+		return nil, err
+	}
+
+	proposal, ok := get(t.store, t.data().proposals, t.writes.proposals, proposalID)
+	if !ok {
+		return nil, models.ErrProposalNotFound
+	}
+	return proposal, nil
+}
+
+func (t *txn) SaveProposal(ctx context.Context, proposal *models.TransferProposal) error {
+	if err := t.lock(ctx, "proposal", proposal.ID); err != nil {
+		return err
+	}
+	put(t.writes.proposals, proposal.ID, *proposal)
+	return nil
+}
+
+func (t *txn) Proposals(ctx context.Context, address string, status *string) ([]*models.TransferProposal, error) {
+	proposals := scan(t.store, t.data().proposals, t.writes.proposals, func(proposal *models.TransferProposal) bool {
+		return proposal.FromAddress == address && (status == nil || proposal.Status == *status)
+	})
+	byCreation(proposals,
+		func(proposal *models.TransferProposal) time.Time { return proposal.CreatedAt },
+		func(proposal *models.TransferProposal) string { return proposal.ID.String() })
+	return proposals, nil
+}
+
+func (t *txn) CreateMultisigChange(ctx context.Context, change *models.MultisigChange) error {
+	change.ID = uuid.New()
+	if change.Status == "" {
+		change.Status = models.ProposalPending
+	}
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+	put(t.writes.signerChanges, change.ID, *change)
+	return nil
+}
+
+func (t *txn) LockMultisigChange(ctx context.Context, id string) (*models.MultisigChange, error) {
+	changeID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, models.ErrMultisigChangeNotFound
+	}
+	if err := t.lock(ctx, "signerChange", changeID); err != nil {
+		return nil, err
+	}
+
+	change, ok := get(t.store, t.data().signerChanges, t.writes.signerChanges, changeID)
+	if !ok {
+		return nil, models.ErrMultisigChangeNotFound
+	}
+	return change, nil
+}
+
+func (t *txn) SaveMultisigChange(ctx context.Context, change *models.MultisigChange) error {
+	if err := t.lock(ctx, "signerChange", change.ID); err != nil {
+		return err
+	}
+	put(t.writes.signerChanges, change.ID, *change)
+	return nil
+}
+
+func (t *txn) MultisigChanges(ctx context.Context, address string, status *string) ([]*models.MultisigChange, error) {
+	changes := scan(t.store, t.data().signerChanges, t.writes.signerChanges, func(change *models.MultisigChange) bool {
+		return change.Address == address && (status == nil || change.Status == *status)
+	})
+	byCreation(changes,
+		func(change *models.MultisigChange) time.Time { return change.CreatedAt },
+		func(change *models.MultisigChange) string { return change.ID.String() })
+	return changes, nil
+}
+
+func (t *txn) AddApproval(ctx context.Context, approval *models.ProposalApproval) (bool, error) {
+	key := approvalKey{proposal: approval.ProposalID, signer: approval.Signer}
+	if err := t.lock(ctx, "approval", key); err != nil {
+		return false, err
+	}
+	if _, ok := get(t.store, t.data().approvals, t.writes.approvals, key); ok {
+		return false, nil
+	}
+	if approval.CreatedAt.IsZero() {
+		approval.CreatedAt = time.Now()
+	}
+	put(t.writes.approvals, key, *approval)
+	return true, nil
+}
+
+func (t *txn) approvals(proposalID uuid.UUID) []*models.ProposalApproval {
+	approvals := scan(t.store, t.data().approvals, t.writes.approvals, func(approval *models.ProposalApproval) bool {
+		return approval.ProposalID == proposalID
+	})
+	byCreation(approvals,
+		func(approval *models.ProposalApproval) time.Time { return approval.CreatedAt },
+		func(approval *models.ProposalApproval) string { return approval.Signer })
+	return approvals
+}
+
+func (t *txn) CountApprovals(ctx context.Context, proposalID uuid.UUID) (int, error) {
+	return len(t.approvals(proposalID)), nil
+}
+
+func (t *txn) Approvals(ctx context.Context, proposalID uuid.UUID) ([]string, error) {
+	signers := []string{}
+	for _, approval := range t.approvals(proposalID) {
+		signers = append(signers, approval.Signer)
+	}
+	return signers, nil
+}
+
+func (t *txn) ExpireProposals(ctx context.Context, now time.Time) (int64, error) {
+	expired := func(proposal *models.TransferProposal) bool {
+		return proposal.Status == models.ProposalPending && !proposal.ExpiresAt.After(now)
+	}
+
+	var count int64
+	for _, candidate := range scan(t.store, t.data().proposals, t.writes.proposals, expired) {
+		// Like UPDATE, wait for the row and check it again once it is ours
+		proposal, err := t.LockProposal(ctx, candidate.ID.String())
+		if err != nil {
+			return count, err
+		}
+		if !expired(proposal) {
+			continue
+		}
+		proposal.Status = models.ProposalExpired
+		put(t.writes.proposals, proposal.ID, *proposal)
+		count++
+	}
+
+	expiredChange := func(change *models.MultisigChange) bool {
+		return change.Status == models.ProposalPending && !change.ExpiresAt.After(now)
+	}
+	for _, candidate := range scan(t.store, t.data().signerChanges, t.writes.signerChanges, expiredChange) {
+		change, err := t.LockMultisigChange(ctx, candidate.ID.String())
+		if err != nil {
+			return count, err
+		}
+		if !expiredChange(change) {
+			continue
+		}
+		change.Status = models.ProposalExpired
+		put(t.writes.signerChanges, change.ID, *change)
+		count++
+	}
+	return count, nil
+}
+
+func (t *txn) CreateTranche(ctx context.Context, tranche *models.VestingTranche) error {
+	tranche.ID = uuid.New()
+	if tranche.CreatedAt.IsZero() {
+		tranche.CreatedAt = time.Now()
+	}
+	put(t.writes.tranches, tranche.ID, *tranche)
+	return nil
+}
+
+func (t *txn) VestingTranches(ctx context.Context, address string) ([]*models.VestingTranche, error) {
+	tranches := scan(t.store, t.data().tranches, t.writes.tranches, func(tranche *models.VestingTranche) bool {
+		return tranche.Address == address
+	})
+	sort.Slice(tranches, func(i, j int) bool {
+		if !tranches[i].CliffAt.Equal(tranches[j].CliffAt) {
+			return tranches[i].CliffAt.Before(tranches[j].CliffAt)
+		}
+		return tranches[i].CreatedAt.Before(tranches[j].CreatedAt)
+	})
+	return tranches, nil
+}
+
+func (t *txn) LockedBalance(ctx context.Context, address string, at time.Time) (int, error) {
+	locked := 0
+	for _, tranche := range scan(t.store, t.data().tranches, t.writes.tranches, func(tranche *models.VestingTranche) bool {
+		return tranche.Address == address && tranche.EndsAt.After(at)
+	}) {
+		locked += tranche.Locked(at)
+	}
+	return locked, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+	"token-transfer-api/metrics"
+)
+
+// RetryPolicy controls how transactions that failed with a transient error are run again.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a transaction runs at most, including the first
+	// attempt. One or less disables retries.
+	MaxAttempts int
+	// BaseDelay is the upper bound of the backoff before the first retry. It doubles with
+	// every further retry, up to MaxDelay, and the actual delay is drawn uniformly below it.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget bounds the time spent on one transaction across all attempts. No retry is
+	// started that could not begin within it. Zero means no limit.
+	Budget time.Duration
+}
+
+// Classifier reports why a failed transaction can safely be run again, such as
+// "serialization_failure" or "deadlock", or "" if it cannot. The reason is used as a
+// metric label, so it must come from a fixed set.
+type Classifier func(err error) string
+
+// WithRetry returns s with a Transaction and a Snapshot that run fn again, in a new
+// transaction, when it fails with an error classify accepts. fn must not have effects outside the
+// transaction that are unsafe to repeat.
+func WithRetry(s Store, policy RetryPolicy, classify Classifier) Store {
+	return &retryStore{Store: s, policy: policy, classify: classify}
+}
+
+type retryStore struct {
+	Store
+	policy   RetryPolicy
+	classify Classifier
+}
+
+func (s *retryStore) Transaction(ctx context.Context, fn func(tx Tx) error) error {
+	return s.retry(ctx, s.Store.Transaction, fn)
+}
+
+func (s *retryStore) Snapshot(ctx context.Context, fn func(tx Tx) error) error {
+	return s.retry(ctx, s.Store.Snapshot, fn)
+}
+
+// retry runs fn in transactions started by begin until one succeeds or fails in a way
+// that cannot be retried.
+func (s *retryStore) retry(ctx context.Context, begin func(context.Context, func(Tx) error) error, fn func(tx Tx) error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := begin(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		reason := s.classify(err)
+		if reason == "" {
+			return err
+		}
+
+		delay := s.policy.backoff(attempt)
+		if attempt >= s.policy.MaxAttempts || (s.policy.Budget > 0 && time.Since(start)+delay > s.policy.Budget) {
+			metrics.TransactionRetriesExhausted.WithLabelValues(reason).Inc()
+			return err
+		}
+		metrics.TransactionRetries.WithLabelValues(reason).Inc()
+		slog.WarnContext(ctx, "retrying transaction", "reason", reason, "attempt", attempt, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the jittered delay before the retry following attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay
+	for i := 1; i < attempt && ceiling < p.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, p.MaxDelay)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
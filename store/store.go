@@ -0,0 +1,268 @@
+// Package store defines the persistence operations the resolvers need, independently
+// of the database behind them. gormstore implements them on Postgres and memstore in
+// memory, with the same locking semantics.
+package store
+
+import (
+	"context"
+	"time"
+	"token-transfer-api/models"
+
+	"github.com/google/uuid"
+)
+
+// WalletStore reads and updates wallets and their limits.
+type WalletStore interface {
+	// Wallet returns the wallet with the given address, or models.ErrWalletNotFound.
+	Wallet(ctx context.Context, address string) (*models.Wallet, error)
+	// LockWallet returns the wallet with the given address and locks it until the
+	// transaction ends. It waits while another transaction holds the lock.
+	LockWallet(ctx context.Context, address string) (*models.Wallet, error)
+	// Wallets returns every wallet ordered by address.
+	Wallets(ctx context.Context) ([]*models.Wallet, error)
+	// WalletsAfter returns up to limit wallets with an address above after, ordered by
+	// address.
+	WalletsAfter(ctx context.Context, after string, limit int) ([]*models.Wallet, error)
+	// CreateWallet creates a wallet with the given balance unless it already exists, and
+	// records its models.EventWalletCreated event and models.AuditCreate record.
+	CreateWallet(ctx context.Context, address string, balance int) error
+	SaveWallet(ctx context.Context, wallet *models.Wallet) error
+	// VerifyWallet marks a wallet verified and records its models.EventVerified event.
+	VerifyWallet(ctx context.Context, address string) (*models.Wallet, error)
+	// SetWalletFrozen freezes or unfreezes a wallet and records its models.EventFrozen
+	// or models.EventUnfrozen event, unless it already was in that state.
+	SetWalletFrozen(ctx context.Context, address string, frozen bool) (*models.Wallet, error)
+	// WalletLimits returns the limits set on a wallet, or nil if it has none.
+	WalletLimits(ctx context.Context, address string) (*models.WalletLimits, error)
+	SetWalletLimits(ctx context.Context, limits models.WalletLimits) error
+}
+
+// ShardStore holds the shards of hot wallets. See models.WalletShard.
+type ShardStore interface {
+	// WalletShards returns the shards of a wallet ordered by index; a wallet that is not
+	// sharded has none.
+	WalletShards(ctx context.Context, address string) ([]*models.WalletShard, error)
+	// ShardedWallets returns the addresses of the wallets that have shards, in order.
+	ShardedWallets(ctx context.Context) ([]string, error)
+	// LockWalletShard returns a shard and locks it until the transaction ends, or
+	// models.ErrShardNotFound.
+	LockWalletShard(ctx context.Context, address string, shard int) (*models.WalletShard, error)
+	// SaveWalletShard creates or updates a shard.
+	SaveWalletShard(ctx context.Context, shard *models.WalletShard) error
+	// DeleteWalletShards deletes the shards of a wallet with an index of at least from.
+	DeleteWalletShards(ctx context.Context, address string, from int) error
+}
+
+// EventStore holds the wallet event stream and the snapshots rebuilt from it. See
+// models.WalletEvent.
+type EventStore interface {
+	// AppendEvents adds events to the stream, assigning their Seq in order.
+	AppendEvents(ctx context.Context, events ...*models.WalletEvent) error
+	// WalletEvents returns the events of a wallet with a Seq above after, in order.
+	WalletEvents(ctx context.Context, address string, after int64) ([]*models.WalletEvent, error)
+	// WalletEventsAsOf returns the events of a wallet with a Seq above after that were
+	// recorded at or before at, in order.
+	WalletEventsAsOf(ctx context.Context, address string, after int64, at time.Time) ([]*models.WalletEvent, error)
+	// EventAddresses returns the addresses that have events, in order.
+	EventAddresses(ctx context.Context) ([]string, error)
+	// FirstEvent returns the event of a wallet with the lowest Seq, or nil if it has none.
+	FirstEvent(ctx context.Context, address string) (*models.WalletEvent, error)
+	// EventTotals returns the sum of the amounts of the events of each type, of the
+	// wallet with the given address or of all of them if address is nil.
+	EventTotals(ctx context.Context, address *string) (map[string]int, error)
+	// LatestSnapshot returns the snapshot of a wallet with the highest Seq, or nil if it
+	// has none.
+	LatestSnapshot(ctx context.Context, address string) (*models.WalletSnapshot, error)
+	// SnapshotAsOf returns the latest snapshot of a wallet taken at or before at, or nil
+	// if there is none.
+	SnapshotAsOf(ctx context.Context, address string, at time.Time) (*models.WalletSnapshot, error)
+	// SnapshotsAsOf returns the snapshot SnapshotAsOf returns for each of the wallets
+	// with the given addresses that has one, in no particular order.
+	SnapshotsAsOf(ctx context.Context, addresses []string, at time.Time) ([]*models.WalletSnapshot, error)
+	// EventsAsOf returns the events of the wallets with the given addresses that were
+	// recorded at or before at, after the snapshot SnapshotsAsOf returns for them, in
+	// order.
+	EventsAsOf(ctx context.Context, addresses []string, at time.Time) ([]*models.WalletEvent, error)
+	SaveSnapshot(ctx context.Context, snapshot *models.WalletSnapshot) error
+}
+
+// AuditStore holds the audit log: a hash chain of records per row holding balance, and
+// the signed checkpoints over them. See models.AuditRecord.
+type AuditStore interface {
+	// AppendAuditRecords seals each record onto the head of the chain of its account and
+	// adds it, assigning its Seq. The caller holds the lock on the row of each account.
+	AppendAuditRecords(ctx context.Context, records ...*models.AuditRecord) error
+	// AuditRecords returns up to limit records of the rows of a wallet with a Seq above
+	// after, in order.
+	AuditRecords(ctx context.Context, address string, after int64, limit int) ([]*models.AuditRecord, error)
+	// AuditAddresses returns the addresses of the wallets that have audit records, in order.
+	AuditAddresses(ctx context.Context) ([]string, error)
+	// AuditHeads returns the latest record of every account, ordered by account.
+	AuditHeads(ctx context.Context) ([]*models.AuditRecord, error)
+	// AuditCheckpoints returns every checkpoint with its entries, oldest first.
+	AuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error)
+	// LatestAuditCheckpoint returns the newest checkpoint without its entries, or nil if
+	// there is none.
+	LatestAuditCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error)
+	// CheckpointedHeads returns the latest checkpoint entry of every account.
+	CheckpointedHeads(ctx context.Context) ([]*models.AuditCheckpointEntry, error)
+	// SaveAuditCheckpoint adds a checkpoint and its entries, assigning its ID. It fails if
+	// another checkpoint follows the same one.
+	SaveAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error
+}
+
+// ReserveStore holds the Merkle roots of balances published as proof-of-reserves
+// attestations. See models.BalanceRoot.
+type ReserveStore interface {
+	// SaveBalanceRoot adds a root and its leaves, assigning its Seq.
+	SaveBalanceRoot(ctx context.Context, root *models.BalanceRoot) error
+	// LatestBalanceRoot returns the root with the highest Seq without its leaves, or nil
+	// if there is none.
+	LatestBalanceRoot(ctx context.Context) (*models.BalanceRoot, error)
+	// BalanceRoot returns the latest root with the given hash and its leaves, or
+	// models.ErrBalanceRootNotFound.
+	BalanceRoot(ctx context.Context, root string) (*models.BalanceRoot, error)
+}
+
+// BlockStore holds the blocks that group committed transfers into numbered epochs. See
+// models.Block.
+type BlockStore interface {
+	// UnsealedTransfers returns the transfers that belong to no block, in block order:
+	// oldest first, ties broken by ID.
+	UnsealedTransfers(ctx context.Context) ([]*models.Transfer, error)
+	// SaveBlock adds a block and assigns it the given transfers. It fails if the block
+	// exists or one of the transfers already belongs to a block.
+	SaveBlock(ctx context.Context, block *models.Block, transfers []*models.Transfer) error
+	// LatestBlock returns the block with the highest number, or nil if there is none.
+	LatestBlock(ctx context.Context) (*models.Block, error)
+	// Block returns the block with the given number, or models.ErrBlockNotFound.
+	Block(ctx context.Context, number int64) (*models.Block, error)
+	// BlockTransfers returns the transfers of a block in block order.
+	BlockTransfers(ctx context.Context, number int64) ([]*models.Transfer, error)
+}
+
+// ReconciliationStore holds the reports of reconciliation runs. See
+// models.ReconciliationReport.
+type ReconciliationStore interface {
+	// SaveReconciliationReport adds a report and its discrepancies, assigning its ID.
+	SaveReconciliationReport(ctx context.Context, report *models.ReconciliationReport) error
+	// ReconciliationReports returns up to limit reports with their discrepancies, newest
+	// first.
+	ReconciliationReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error)
+}
+
+// LedgerStore records transfers and the per-wallet spend counters derived from them.
+type LedgerStore interface {
+	RecordTransfer(ctx context.Context, fromAddress string, toAddress string, amount int, at time.Time) (*models.Transfer, error)
+	CountRecipientsSince(ctx context.Context, address string, since time.Time) (int, error)
+	HasTransferred(ctx context.Context, fromAddress string, toAddress string) (bool, error)
+	// TransferTotals returns the amounts a wallet received and sent in transfers since
+	// the given time.
+	TransferTotals(ctx context.Context, address string, since time.Time) (received int, sent int, err error)
+	// TransfersAfter returns up to limit transfers that follow after in block order, or
+	// the first ones if after is nil.
+	TransfersAfter(ctx context.Context, after *models.Transfer, limit int) ([]*models.Transfer, error)
+	// ImportTransfers adds transfers of another ledger as they are, keeping their IDs and
+	// times, in models.PreGenesisBlock so they are never sealed. It records no events and
+	// changes no balance.
+	ImportTransfers(ctx context.Context, transfers []*models.Transfer) error
+	RecordSpend(ctx context.Context, address string, amount int, at time.Time) error
+	SpentSince(ctx context.Context, address string, since time.Time) (amount int, transfers int, err error)
+	PruneSpendCounters(ctx context.Context, address string, now time.Time) error
+}
+
+// ReviewStore holds transfers waiting for an admin decision.
+type ReviewStore interface {
+	CreateReview(ctx context.Context, review *models.TransferReview) error
+	// LockPendingReview locks a review until the transaction ends. It fails with
+	// models.ErrReviewDecided if the review is no longer pending.
+	LockPendingReview(ctx context.Context, id string) (*models.TransferReview, error)
+	SaveReview(ctx context.Context, review *models.TransferReview) error
+	// Reviews returns the reviews with the given status, or all of them if status is
+	// nil, oldest first.
+	Reviews(ctx context.Context, status *string) ([]*models.TransferReview, error)
+}
+
+// MultisigStore holds multisig wallets, their signers, transfer proposals and signer
+// changes.
+type MultisigStore interface {
+	// CreateMultisigWallet turns a wallet into a multisig wallet, or fails with
+	// models.ErrMultisigExists if it already is one.
+	CreateMultisigWallet(ctx context.Context, address string, signers map[string]string, threshold int) (*models.MultisigWallet, error)
+	// ReplaceMultisigSigners replaces the signers and threshold of a multisig wallet and
+	// expires its pending proposals and signer changes.
+	ReplaceMultisigSigners(ctx context.Context, address string, signers map[string]string, threshold int) (*models.MultisigWallet, error)
+	// MultisigWallet returns the multisig settings of address, or nil for an ordinary wallet.
+	MultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error)
+	// LockMultisigWallet is MultisigWallet, locking the multisig settings until the
+	// transaction ends.
+	LockMultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error)
+	// Signer returns a signer of a multisig wallet, or models.ErrNotSigner.
+	Signer(ctx context.Context, address string, signer string) (*models.MultisigSigner, error)
+	// Signers returns the names of the signers of a multisig wallet in order.
+	Signers(ctx context.Context, address string) ([]string, error)
+	CreateProposal(ctx context.Context, proposal *models.TransferProposal) error
+	// LockProposal locks a proposal until the transaction ends.
+	LockProposal(ctx context.Context, id string) (*models.TransferProposal, error)
+	SaveProposal(ctx context.Context, proposal *models.TransferProposal) error
+	// Proposals returns the proposals from address with the given status, or all of
+	// them if status is nil, oldest first.
+	Proposals(ctx context.Context, address string, status *string) ([]*models.TransferProposal, error)
+	CreateMultisigChange(ctx context.Context, change *models.MultisigChange) error
+	// LockMultisigChange locks a signer change until the transaction ends.
+	LockMultisigChange(ctx context.Context, id string) (*models.MultisigChange, error)
+	SaveMultisigChange(ctx context.Context, change *models.MultisigChange) error
+	// MultisigChanges returns the signer changes of address with the given status, or
+	// all of them if status is nil, oldest first.
+	MultisigChanges(ctx context.Context, address string, status *string) ([]*models.MultisigChange, error)
+	// AddApproval records an approval of a proposal or signer change. It reports false
+	// if the signer already approved it.
+	AddApproval(ctx context.Context, approval *models.ProposalApproval) (bool, error)
+	CountApprovals(ctx context.Context, proposalID uuid.UUID) (int, error)
+	// Approvals returns the signers that approved a proposal in the order they did.
+	Approvals(ctx context.Context, proposalID uuid.UUID) ([]string, error)
+	// ExpireProposals marks every pending proposal and signer change past its expiry as
+	// expired.
+	ExpireProposals(ctx context.Context, now time.Time) (int64, error)
+}
+
+// VestingStore holds vesting tranches.
+type VestingStore interface {
+	CreateTranche(ctx context.Context, tranche *models.VestingTranche) error
+	// VestingTranches returns the tranches of a wallet ordered by cliff.
+	VestingTranches(ctx context.Context, address string) ([]*models.VestingTranche, error)
+	// LockedBalance returns the amount of a wallet's balance still vesting at the given time.
+	LockedBalance(ctx context.Context, address string, at time.Time) (int, error)
+}
+
+// Tx is the set of operations available inside a transaction.
+type Tx interface {
+	WalletStore
+	ShardStore
+	EventStore
+	AuditStore
+	ReserveStore
+	BlockStore
+	ReconciliationStore
+	LedgerStore
+	ReviewStore
+	MultisigStore
+	VestingStore
+	// Transaction runs fn in a savepoint: if fn returns an error, its writes are rolled
+	// back, the row locks it took are released, and the enclosing transaction carries on
+	// without them.
+	Transaction(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// Store runs operations on its own, each in an implicit transaction, or together in
+// an explicit one.
+type Store interface {
+	Tx
+	// Transaction runs fn in a transaction that is committed if fn returns nil and
+	// rolled back otherwise, including when fn panics. fn's error is returned as is.
+	Transaction(ctx context.Context, fn func(tx Tx) error) error
+	// Snapshot is Transaction, except that fn reads a consistent snapshot of the store
+	// taken when the transaction starts. Reads take no locks, so a long read of many
+	// rows, such as every balance, does not hold up transfers.
+	Snapshot(ctx context.Context, fn func(tx Tx) error) error
+}
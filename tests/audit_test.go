@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"strings"
+	"token-transfer-api/audit"
+	"token-transfer-api/graph"
+	"token-transfer-api/models"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// auditKey is a fixed key for signing checkpoints in tests.
+var auditKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+// assertAuditValid checks that the audit log verifies without problems.
+func (suite *MemStoreTestSuite) assertAuditValid() *audit.Report {
+	report, err := audit.Verify(context.Background(), suite.store, auditKey.Public().(ed25519.PublicKey))
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), report.Problems)
+	return report
+}
+
+func (suite *MemStoreTestSuite) TestAuditChainRecordsBalances() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 300)
+	suite.Require().NoError(err)
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 1000)
+	suite.Require().Error(err)
+
+	records, err := suite.store.AuditRecords(ctx, "0x1001", 0, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 2, "Failed transfers must not record anything")
+	assert.Equal(suite.T(), models.AuditCreate, records[0].Operation)
+	assert.Empty(suite.T(), records[0].PrevHash)
+	assert.Equal(suite.T(), models.AuditTransfer, records[1].Operation)
+	assert.Equal(suite.T(), 300, records[1].Balance)
+	assert.Equal(suite.T(), records[0].Hash, records[1].PrevHash)
+	assert.Equal(suite.T(), records[1].ComputeHash(), records[1].Hash)
+
+	report := suite.assertAuditValid()
+	assert.Equal(suite.T(), 4, report.Records)
+	assert.True(suite.T(), report.SignaturesVerified)
+}
+
+func (suite *MemStoreTestSuite) TestAuditDetectsUnrecordedChanges() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 300)
+	suite.Require().NoError(err)
+
+	wallet, err := suite.store.Wallet(ctx, "0x1001")
+	suite.Require().NoError(err)
+	wallet.Balance = 5000
+	suite.Require().NoError(suite.store.SaveWallet(ctx, wallet))
+	suite.Require().NoError(suite.store.SaveWalletShard(ctx, &models.WalletShard{Address: "0x1001", Shard: 0, Balance: 7}))
+
+	report, err := audit.Verify(ctx, suite.store, nil)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), report.SignaturesVerified)
+	suite.Require().Len(report.Problems, 2)
+	assert.Equal(suite.T(), audit.Problem{Kind: audit.ProblemBalance, Account: "0x1001", Seq: 4, Detail: "row holds 5000, audit log 300"}, report.Problems[0])
+	assert.Equal(suite.T(), audit.ProblemUnaudited, report.Problems[1].Kind)
+	assert.Equal(suite.T(), models.ShardAccount("0x1001", 0), report.Problems[1].Account)
+}
+
+func (suite *MemStoreTestSuite) TestAuditCheckpoints() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	first, err := audit.Checkpoint(ctx, suite.store, auditKey)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(first)
+	assert.Empty(suite.T(), first.PrevHash)
+	assert.Len(suite.T(), first.Entries, 2)
+
+	again, err := audit.Checkpoint(ctx, suite.store, auditKey)
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), again, "No checkpoint should be signed without new records")
+
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 1)
+	suite.Require().Error(err)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1002", 50))
+	second, err := audit.Checkpoint(ctx, suite.store, auditKey)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(second)
+	assert.Equal(suite.T(), first.Hash, second.PrevHash)
+	suite.Require().Len(second.Entries, 1, "Only changed chains should be checkpointed")
+	assert.Equal(suite.T(), "0x1002", second.Entries[0].Account)
+
+	report := suite.assertAuditValid()
+	assert.Equal(suite.T(), 2, report.Checkpoints)
+
+	// Checkpoints signed by another key.
+	other := ed25519.NewKeyFromSeed([]byte(strings.Repeat("k", ed25519.SeedSize)))
+	report, err = audit.Verify(ctx, suite.store, other.Public().(ed25519.PublicKey))
+	suite.Require().NoError(err)
+	suite.Require().Len(report.Problems, 2)
+	for _, problem := range report.Problems {
+		assert.Equal(suite.T(), audit.ProblemCheckpoint, problem.Kind)
+		assert.Equal(suite.T(), "signature does not match the audit key", problem.Detail)
+	}
+
+	forked := &models.AuditCheckpoint{PrevHash: first.Hash, Hash: "fork", Signature: "fork"}
+	assert.Error(suite.T(), suite.store.SaveAuditCheckpoint(ctx, forked), "Two checkpoints cannot follow the same one")
+}
+
+func (suite *MemStoreTestSuite) TestAuditHotWallets() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(4)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	_, err := resolver.Transfer(ctx, "0x1000", "0x1001", 6000)
+	suite.Require().NoError(err)
+	_, err = resolver.Transfer(ctx, "0x1001", "0x1000", 10)
+	suite.Require().NoError(err)
+	suite.Require().NoError(resolver.RebalanceHotWallets(ctx))
+	suite.assertAuditValid()
+
+	// Unsharding records the shards it empties.
+	resolver.HotWallets = nil
+	suite.Require().NoError(resolver.RebalanceHotWallets(ctx))
+	heads, err := suite.store.AuditHeads(ctx)
+	suite.Require().NoError(err)
+	balances := map[string]int{}
+	for _, head := range heads {
+		balances[head.Account] = head.Balance
+	}
+	assert.Equal(suite.T(), 4010, balances["0x1000"])
+	assert.Equal(suite.T(), 0, balances[models.ShardAccount("0x1000", 3)])
+	suite.assertAuditValid()
+}
+
+func (suite *MemStoreTestSuite) TestAuditEngine() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	startEngine(suite.T(), suite.resolver, oneBatch(4))
+
+	transferConcurrently(suite.resolver, "0x1000", "0x1001", 4000, 4)
+	records, err := suite.store.AuditRecords(ctx, "0x1001", 0, 10)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), records, 3, "Transfers rolled back in a batch must not leave records")
+	suite.assertAuditValid()
+}
+
+func (suite *GraphQLTestSuite) TestVerifyAuditChain() {
+	ctx := context.Background()
+	fromAddress := "0xTEST9A00"
+	toAddress := "0xTEST9A01"
+	suite.Require().NoError(models.InitializeWallet(suite.db, fromAddress, 1000))
+	suite.Require().NoError(models.InitializeWallet(suite.db, toAddress, 0))
+
+	s := gormstore.New(suite.db)
+	resolver := &graph.Resolver{Store: s, AuditKey: auditKey.Public().(ed25519.PublicKey)}
+	for _, amount := range []int{100, 200, 300} {
+		_, err := resolver.Transfer(ctx, fromAddress, toAddress, amount)
+		suite.Require().NoError(err)
+	}
+	_, err := audit.Checkpoint(ctx, s, auditKey)
+	suite.Require().NoError(err)
+
+	problems := func() map[string]string {
+		report, err := resolver.Query().VerifyAuditChain(ctx)
+		suite.Require().NoError(err)
+		assert.True(suite.T(), report.SignaturesVerified)
+		found := map[string]string{}
+		for _, problem := range report.Problems {
+			if problem.Account != nil && strings.HasPrefix(*problem.Account, "0xTEST9A") {
+				found[*problem.Account] += problem.Kind + " "
+			}
+		}
+		return found
+	}
+	assert.Empty(suite.T(), problems())
+
+	var records []*models.AuditRecord
+	suite.Require().NoError(suite.db.Where("address = ?", toAddress).Order("seq").Find(&records).Error)
+	suite.Require().Len(records, 4)
+
+	suite.Require().NoError(suite.db.Exec("UPDATE wallets SET balance = balance + 1000 WHERE address = ?", fromAddress).Error)
+	suite.Require().NoError(suite.db.Exec("UPDATE audit_records SET balance = 1 WHERE seq = ?", records[1].Seq).Error)
+	suite.Require().NoError(suite.db.Exec("DELETE FROM audit_records WHERE seq = ?", records[3].Seq).Error)
+
+	found := problems()
+	assert.Equal(suite.T(), "balance_mismatch ", found[fromAddress])
+	assert.Equal(suite.T(), "edited balance_mismatch missing_record ", found[toAddress])
+}
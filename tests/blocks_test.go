@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"context"
+	"time"
+	"token-transfer-api/blocks"
+	"token-transfer-api/config"
+	"token-transfer-api/graph"
+	"token-transfer-api/merkle"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *MemStoreTestSuite) TestBlocksSealTransfers() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	for _, amount := range []int{100, 200, 300, 400, 500} {
+		_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", amount)
+		suite.Require().NoError(err)
+	}
+
+	// The state root of a block holds the balances once its transfers are applied.
+	state := func(sent int) string {
+		return merkle.Build([]merkle.Leaf{{Address: "0x1000", Balance: 10000 - sent}, {Address: "0x1001", Balance: sent}}).Root()
+	}
+
+	first, err := blocks.Seal(ctx, suite.store)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(first)
+	assert.Equal(suite.T(), int64(1), first.Number)
+	assert.Empty(suite.T(), first.ParentHash)
+	assert.Equal(suite.T(), first.ComputeHash(), first.Hash)
+	assert.Equal(suite.T(), 5, first.TransferCount, "A block should hold every waiting transfer")
+	assert.Equal(suite.T(), state(1500), first.StateRoot)
+
+	transfers, err := suite.store.BlockTransfers(ctx, first.Number)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), transfers, first.TransferCount)
+	assert.Equal(suite.T(), blocks.TransferRoot(transfers), first.TransferRoot)
+
+	again, err := blocks.Seal(ctx, suite.store)
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), again, "No block should be sealed without new transfers")
+
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 50)
+	suite.Require().NoError(err)
+	next, err := blocks.Seal(ctx, suite.store)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(next)
+	assert.Equal(suite.T(), int64(2), next.Number)
+	assert.Equal(suite.T(), first.Hash, next.ParentHash)
+	assert.Equal(suite.T(), 1, next.TransferCount)
+	assert.Equal(suite.T(), state(1450), next.StateRoot)
+
+	latest, err := suite.store.LatestBlock(ctx)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), next, latest)
+	_, err = suite.store.Block(ctx, 3)
+	assert.ErrorIs(suite.T(), err, models.ErrBlockNotFound)
+}
+
+func (suite *MemStoreTestSuite) TestBlocksSealDoesNotWaitForWallets() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 100)
+	suite.Require().NoError(err)
+
+	// A transfer in flight holds the wallet locks until it commits, and is not part of
+	// the block.
+	locked, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- suite.store.Transaction(ctx, func(tx store.Tx) error {
+			if _, err := tx.LockWallet(ctx, "0x1000"); err != nil {
+				return err
+			}
+			if _, err := tx.LockWallet(ctx, "0x1001"); err != nil {
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	block, err := blocks.Seal(timeout, suite.store)
+	close(release)
+	suite.Require().NoError(err, "Sealing must not wait for wallet locks")
+	suite.Require().NotNil(block)
+	assert.Equal(suite.T(), 1, block.TransferCount)
+	suite.Require().NoError(<-done)
+}
+
+func (suite *MemStoreTestSuite) TestBlocksHotWallets() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(4)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	_, err := resolver.Transfer(ctx, "0x1000", "0x1001", 700)
+	suite.Require().NoError(err)
+
+	sealed, err := blocks.Seal(ctx, suite.store)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(sealed)
+	expected := merkle.Build([]merkle.Leaf{{Address: "0x1000", Balance: 9300}, {Address: "0x1001", Balance: 700}}).Root()
+	assert.Equal(suite.T(), expected, sealed.StateRoot, "The balance of a hot wallet should include its shards")
+}
+
+func (suite *MemStoreTestSuite) TestBlocksProducerSealsFullBlocks() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	producer := blocks.NewProducer(suite.store, config.BlocksConfig{Interval: time.Hour, MaxTransfers: 3})
+	suite.resolver.Blocks = producer
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		producer.Run(runCtx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for range 2 {
+		_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 10)
+		suite.Require().NoError(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	latest, err := suite.store.LatestBlock(ctx)
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), latest, "No block should be sealed before it is full")
+
+	_, err = suite.resolver.Transfer(ctx, "0x1000", "0x1001", 10)
+	suite.Require().NoError(err)
+	assert.Eventually(suite.T(), func() bool {
+		latest, err := suite.store.LatestBlock(ctx)
+		return err == nil && latest != nil && latest.TransferCount == 3
+	}, time.Second, 5*time.Millisecond)
+}
+
+func (suite *GraphQLTestSuite) TestBlocks() {
+	ctx := context.Background()
+	fromAddress := "0xTEST9C00"
+	toAddress := "0xTEST9C01"
+	suite.Require().NoError(models.InitializeWallet(suite.db, fromAddress, 1000))
+	suite.Require().NoError(models.InitializeWallet(suite.db, toAddress, 0))
+
+	s := gormstore.New(suite.db)
+	resolver := &graph.Resolver{Store: s}
+	for _, amount := range []int{100, 200, 300} {
+		_, err := resolver.Transfer(ctx, fromAddress, toAddress, amount)
+		suite.Require().NoError(err)
+	}
+
+	last, err := blocks.Seal(ctx, s)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(last)
+
+	latest, err := resolver.Query().LatestBlock(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(latest)
+	assert.Equal(suite.T(), last.Hash, latest.Hash)
+
+	block, err := resolver.Query().Block(ctx, int(last.Number))
+	suite.Require().NoError(err)
+	transfers, err := resolver.Block().Transfers(ctx, block)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), block.TransferRoot, blocks.TransferRoot(transfers), "Transfers read back should give the transfer root")
+
+	var amounts []int
+	for _, transfer := range transfers {
+		if transfer.FromAddress == fromAddress {
+			amounts = append(amounts, transfer.Amount)
+			suite.Require().NotNil(transfer.BlockNumber)
+			assert.Equal(suite.T(), block.Number, *transfer.BlockNumber)
+		}
+	}
+	assert.Equal(suite.T(), []int{100, 200, 300}, amounts)
+
+	_, err = resolver.Query().Block(ctx, int(last.Number)+1)
+	assert.ErrorIs(suite.T(), err, models.ErrBlockNotFound)
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"token-transfer-api/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "test.env")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestConfigLayering(t *testing.T) {
+	path := writeConfigFile(t, "POSTGRES_USER=file_user\nPOSTGRES_DB=file_db\nPOSTGRES_PORT=6543\nSERVER_ADDR=:7000\n")
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("POSTGRES_DB", "env_db")
+	t.Setenv("SERVER_ADDR", ":7001")
+
+	cfg, args, err := config.Load([]string{"-addr", ":7002", "migrate", "up"})
+	assert.NoError(t, err, "Failed to load the configuration")
+	assert.Equal(t, []string{"migrate", "up"}, args, "Remaining arguments incorrect")
+
+	assert.Equal(t, "file_user", cfg.Database.User, "File should override defaults")
+	assert.Equal(t, 6543, cfg.Database.Port, "File should override defaults")
+	assert.Equal(t, "env_db", cfg.Database.Name, "Environment should override the file")
+	assert.Equal(t, ":7002", cfg.Server.Addr, "Flags should override the environment")
+	assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout, "Default should be kept")
+}
+
+func TestConfigOptionalFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("POSTGRES_USER", "env_user")
+	t.Setenv("POSTGRES_DB", "env_db")
+	t.Chdir(t.TempDir())
+
+	_, _, err := config.Load(nil)
+	assert.NoError(t, err, "A missing .env file should not be an error")
+
+	_, _, err = config.Load([]string{"-config", "missing.env"})
+	assert.Error(t, err, "An explicitly requested file must exist")
+}
+
+func TestConfigValidation(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, ""))
+	t.Setenv("POSTGRES_USER", "user")
+	t.Setenv("POSTGRES_DB", "db")
+
+	_, _, err := config.Load([]string{"-db-sslmode", "sometimes"})
+	assert.ErrorContains(t, err, "unknown database SSL mode")
+
+	_, _, err = config.Load([]string{"-tls-cert", "cert.pem"})
+	assert.ErrorContains(t, err, "TLS certificate and key must be set together")
+
+	_, _, err = config.Load([]string{"-db-port", "not-a-port"})
+	assert.Error(t, err, "Expected error for invalid port")
+
+	_, _, err = config.Load([]string{"-db-driver", "mysql"})
+	assert.ErrorContains(t, err, "unknown database driver")
+}
+
+func TestConfigSQLiteDriver(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, ""))
+	t.Setenv("POSTGRES_USER", "")
+	t.Setenv("POSTGRES_DB", "")
+
+	cfg, _, err := config.Load([]string{"-db-driver", "sqlite", "-sqlite-path", "ledger.db"})
+	assert.NoError(t, err, "Postgres settings are not required for SQLite")
+	assert.Equal(t, "sqlite", cfg.Database.Driver)
+	assert.Equal(t, "ledger.db", cfg.Database.SQLitePath)
+
+	_, _, err = config.Load([]string{"-db-driver", "sqlite", "-sqlite-path", ""})
+	assert.ErrorContains(t, err, "SQLite path is required")
+}
+
+func TestConfigHotWallets(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "HOT_WALLETS=0x0000, 0x0001,\n"))
+	t.Setenv("POSTGRES_USER", "user")
+	t.Setenv("POSTGRES_DB", "db")
+
+	cfg, _, err := config.Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0x0000", "0x0001"}, cfg.Features.HotWallets)
+
+	_, _, err = config.Load([]string{"-hot-wallet-shards", "0"})
+	assert.ErrorContains(t, err, "hot wallet shards must be positive")
+}
+
+func TestConfigTransferEngine(t *testing.T) {
+	t.Setenv("POSTGRES_USER", "user")
+	t.Setenv("POSTGRES_DB", "db")
+	t.Setenv("TRANSFER_ENGINE_ENABLED", "true")
+
+	cfg, _, err := config.Load([]string{"-transfer-engine-max-batch", "500", "-transfer-engine-max-delay", "10ms"})
+	assert.NoError(t, err)
+	assert.True(t, cfg.Engine.Enabled)
+	assert.Equal(t, 500, cfg.Engine.MaxBatch)
+	assert.Equal(t, 10*time.Millisecond, cfg.Engine.MaxDelay)
+	assert.Equal(t, 1000, cfg.Engine.QueueSize)
+
+	_, _, err = config.Load([]string{"-transfer-engine-max-batch", "0"})
+	assert.ErrorContains(t, err, "transfer engine batch size must be positive")
+}
+
+func TestConfigAuditKey(t *testing.T) {
+	t.Setenv("POSTGRES_USER", "user")
+	t.Setenv("POSTGRES_DB", "db")
+	t.Setenv("AUDIT_KEY", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+
+	cfg, _, err := config.Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, cfg.Features.AuditCheckpointInterval)
+
+	_, _, err = config.Load([]string{"-audit-key", "c2hvcnQ="})
+	assert.ErrorContains(t, err, "audit key must be a base64 32-byte seed")
+}
+
+func TestConfigDSN(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.User = "tta_user"
+	cfg.Database.Password = "it's secret"
+	cfg.Database.Name = "tta_db"
+
+	assert.Equal(t,
+		`host='localhost' port=5432 user='tta_user' password='it\'s secret' dbname='tta_db' sslmode='prefer'`,
+		cfg.Database.DSN())
+}
@@ -0,0 +1,244 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"token-transfer-api/blocks"
+	"token-transfer-api/dump"
+	"token-transfer-api/graph"
+	"token-transfer-api/ledger"
+	"token-transfer-api/merkle"
+	"token-transfer-api/models"
+	"token-transfer-api/reconcile"
+	"token-transfer-api/store"
+	"token-transfer-api/store/gormstore"
+	"token-transfer-api/store/memstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exportedTransfers returns the transfers of s involving an address with the given
+// prefix, in block order.
+func exportedTransfers(ctx context.Context, s store.Store, prefix string) ([]*models.Transfer, error) {
+	var found []*models.Transfer
+	var after *models.Transfer
+	for {
+		transfers, err := s.TransfersAfter(ctx, after, 2)
+		if err != nil {
+			return nil, err
+		}
+		if len(transfers) == 0 {
+			return found, nil
+		}
+		for _, transfer := range transfers {
+			if strings.HasPrefix(transfer.FromAddress, prefix) || strings.HasPrefix(transfer.ToAddress, prefix) {
+				found = append(found, transfer)
+			}
+		}
+		after = transfers[len(transfers)-1]
+	}
+}
+
+func (suite *MemStoreTestSuite) TestExportImport() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(3)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 50))
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1002", 0))
+	for _, transfer := range []struct {
+		from, to string
+		amount   int
+	}{{"0x1000", "0x1001", 300}, {"0x1001", "0x1002", 120}, {"0x1002", "0x1000", 20}} {
+		_, err := resolver.Transfer(ctx, transfer.from, transfer.to, transfer.amount)
+		suite.Require().NoError(err)
+	}
+	_, err := resolver.Mint(ctx, "0x1002", 75)
+	suite.Require().NoError(err)
+	_, err = suite.store.VerifyWallet(ctx, "0x1001")
+	suite.Require().NoError(err)
+	_, err = suite.store.SetWalletFrozen(ctx, "0x1002", true)
+	suite.Require().NoError(err)
+	source, err := exportedTransfers(ctx, suite.store, "0x")
+	suite.Require().NoError(err)
+
+	for _, format := range dump.Formats {
+		dir := suite.T().TempDir()
+		exported, err := dump.Export(ctx, suite.store, dir, format)
+		suite.Require().NoError(err, format)
+		assert.Equal(suite.T(), dump.Summary{Wallets: 3, Transfers: 3, Supply: 10125}, *exported, format)
+
+		target := memstore.New()
+		imported, err := dump.Import(ctx, target, dir, format)
+		suite.Require().NoError(err, format)
+		assert.Equal(suite.T(), *exported, *imported, format)
+
+		for address, balance := range map[string]int{"0x1000": 9720, "0x1001": 230, "0x1002": 175} {
+			wallet, err := target.Wallet(ctx, address)
+			suite.Require().NoError(err)
+			assert.Equal(suite.T(), balance, wallet.Balance, "Hot wallets should be imported with their shards")
+			assert.Equal(suite.T(), address == "0x1001", wallet.Verified)
+			assert.Equal(suite.T(), address == "0x1002", wallet.Frozen)
+		}
+		transfers, err := exportedTransfers(ctx, target, "0x")
+		suite.Require().NoError(err)
+		suite.Require().Len(transfers, len(source))
+		for i, transfer := range transfers {
+			assert.Equal(suite.T(), source[i].ID, transfer.ID, "Transfers should keep their IDs")
+			assert.Equal(suite.T(), source[i].Amount, transfer.Amount)
+			assert.True(suite.T(), source[i].CreatedAt.Truncate(time.Microsecond).Equal(transfer.CreatedAt), "Times should survive at microsecond precision")
+		}
+
+		report, err := reconcile.Run(ctx, target)
+		suite.Require().NoError(err)
+		assert.True(suite.T(), report.OK, "Discrepancies: %+v", report.Discrepancies)
+		diff, err := ledger.Diff(ctx, target)
+		suite.Require().NoError(err)
+		assert.Empty(suite.T(), diff.Mismatches)
+
+		_, err = dump.Import(ctx, target, dir, format)
+		assert.ErrorContains(suite.T(), err, "already exists", "Importing twice should fail")
+	}
+}
+
+func (suite *MemStoreTestSuite) TestSealAfterImport() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	for _, amount := range []int{100, 200} {
+		_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", amount)
+		suite.Require().NoError(err)
+	}
+	dir := suite.T().TempDir()
+	_, err := dump.Export(ctx, suite.store, dir, dump.JSONL)
+	suite.Require().NoError(err)
+
+	target := memstore.New()
+	_, err = dump.Import(ctx, target, dir, dump.JSONL)
+	suite.Require().NoError(err)
+	block, err := blocks.Seal(ctx, target)
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), block, "Imported history should not be sealed")
+
+	_, err = (&graph.Resolver{Store: target}).Transfer(ctx, "0x1001", "0x1000", 50)
+	suite.Require().NoError(err)
+	block, err = blocks.Seal(ctx, target)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(block)
+	assert.Equal(suite.T(), int64(1), block.Number)
+	assert.Equal(suite.T(), 1, block.TransferCount, "The first block should hold only the transfers made after the import")
+	expected := merkle.Build([]merkle.Leaf{{Address: "0x1000", Balance: 9750}, {Address: "0x1001", Balance: 250}}).Root()
+	assert.Equal(suite.T(), expected, block.StateRoot)
+
+	imported, err := target.BlockTransfers(ctx, models.PreGenesisBlock)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), imported, 2)
+}
+
+func (suite *MemStoreTestSuite) TestImportChecksConservation() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 400)
+	suite.Require().NoError(err)
+	dir := suite.T().TempDir()
+	_, err = dump.Export(ctx, suite.store, dir, dump.CSV)
+	suite.Require().NoError(err)
+
+	wallets, err := os.ReadFile(filepath.Join(dir, "wallets.csv"))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "address,balance,issued,verified,frozen\n0x1000,9600,10000,false,false\n0x1001,400,0,false,false\n", string(wallets))
+	transfers, err := os.ReadFile(filepath.Join(dir, "transfers.csv"))
+	suite.Require().NoError(err)
+
+	for name, tamper := range map[string]struct {
+		wallets, transfers string
+		err                string
+	}{
+		"inflated balance": {
+			wallets: strings.Replace(string(wallets), "0x1001,400,0", "0x1001,500,0", 1),
+			err:     "the wallets hold 10100 but were issued 10000",
+		},
+		"shifted issuance": {
+			wallets: strings.NewReplacer("0x1000,9600,10000", "0x1000,9600,9900", "0x1001,400,0", "0x1001,400,100").Replace(string(wallets)),
+			err:     "wallet 0x1000 holds 9600 but was issued 9900 and received -400 net in transfers, and 1 more wallets do not add up",
+		},
+		"dropped transfer": {
+			transfers: strings.Join(strings.SplitAfter(string(transfers), "\n")[:1], ""),
+			err:       "wallet 0x1000 holds 9600 but was issued 10000 and received 0 net in transfers, and 1 more wallets do not add up",
+		},
+		"dropped wallet": {
+			wallets: strings.Replace(string(wallets), "0x1001,400,0,false,false\n", "", 1),
+			err:     "wallet 0x1001 is not part of the export",
+		},
+		"renamed column": {
+			wallets: strings.Replace(string(wallets), "issued", "minted", 1),
+			err:     "header has columns",
+		},
+	} {
+		tampered := suite.T().TempDir()
+		for file, content := range map[string]string{"wallets.csv": tamper.wallets, "transfers.csv": tamper.transfers} {
+			if content == "" {
+				content = map[string]string{"wallets.csv": string(wallets), "transfers.csv": string(transfers)}[file]
+			}
+			suite.Require().NoError(os.WriteFile(filepath.Join(tampered, file), []byte(content), 0o644))
+		}
+
+		target := memstore.New()
+		_, err := dump.Import(ctx, target, tampered, dump.CSV)
+		assert.ErrorContains(suite.T(), err, tamper.err, name)
+		wallets, err := target.Wallets(ctx)
+		suite.Require().NoError(err)
+		assert.Empty(suite.T(), wallets, "%s: a failed import must not commit anything", name)
+	}
+}
+
+func (suite *GraphQLTestSuite) TestExportImport() {
+	ctx := context.Background()
+	source := memstore.New()
+	suite.Require().NoError(source.CreateWallet(ctx, "0xTEST9E00", 1000))
+	suite.Require().NoError(source.CreateWallet(ctx, "0xTEST9E01", 0))
+	resolver := &graph.Resolver{Store: source}
+	for _, amount := range []int{100, 250, 5} {
+		_, err := resolver.Transfer(ctx, "0xTEST9E00", "0xTEST9E01", amount)
+		suite.Require().NoError(err)
+	}
+	sourceDir := suite.T().TempDir()
+	_, err := dump.Export(ctx, source, sourceDir, dump.JSONL)
+	suite.Require().NoError(err)
+
+	s := gormstore.New(suite.db)
+	imported, err := dump.Import(ctx, s, sourceDir, dump.JSONL)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), dump.Summary{Wallets: 2, Transfers: 3, Supply: 1000}, *imported)
+
+	wallet, err := s.Wallet(ctx, "0xTEST9E01")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 355, wallet.Balance)
+	expected, err := exportedTransfers(ctx, source, "0xTEST9E")
+	suite.Require().NoError(err)
+	transfers, err := exportedTransfers(ctx, s, "0xTEST9E")
+	suite.Require().NoError(err)
+	suite.Require().Len(transfers, 3, "Paging should visit every imported transfer")
+	for i, transfer := range transfers {
+		assert.Equal(suite.T(), expected[i].ID, transfer.ID)
+		assert.Equal(suite.T(), expected[i].Amount, transfer.Amount)
+	}
+
+	unsealed, err := s.UnsealedTransfers(ctx)
+	suite.Require().NoError(err)
+	for _, transfer := range unsealed {
+		assert.False(suite.T(), strings.HasPrefix(transfer.FromAddress, "0xTEST9E"), "Imported transfers should not wait for a block")
+	}
+
+	report, err := reconcile.Run(ctx, s)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), discrepanciesOf(report, "0xTEST9E00"))
+	assert.Empty(suite.T(), discrepanciesOf(report, "0xTEST9E01"))
+
+	dir := suite.T().TempDir()
+	exported, err := dump.Export(ctx, s, dir, dump.Parquet)
+	suite.Require().NoError(err)
+	assert.GreaterOrEqual(suite.T(), exported.Transfers, 3)
+	_, err = dump.Import(ctx, memstore.New(), dir, dump.Parquet)
+	suite.Require().NoError(err, "An export of the database should import again")
+}
@@ -0,0 +1,305 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"token-transfer-api/config"
+	"token-transfer-api/engine"
+	"token-transfer-api/graph"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startEngine runs a transfer engine for resolver until the test ends.
+func startEngine(t *testing.T, resolver *graph.Resolver, cfg config.EngineConfig) {
+	resolver.Engine = engine.New(resolver.Store, cfg, resolver.BatchExecutor())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		resolver.Engine.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// oneBatch makes the engine wait until n transfers are queued and commit them together.
+func oneBatch(n int) config.EngineConfig {
+	return config.EngineConfig{MaxBatch: n, MaxDelay: time.Minute, QueueSize: n}
+}
+
+// transferConcurrently runs count transfers of amount from fromAddress to toAddress at
+// once and returns their errors.
+func transferConcurrently(resolver *graph.Resolver, fromAddress string, toAddress string, amount int, count int) []error {
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	for i := range count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = resolver.Transfer(context.Background(), fromAddress, toAddress, amount)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestEngineCommitsBatchInOneTransaction(t *testing.T) {
+	flaky := newFlakyStore(t, 0, nil)
+	resolver := &graph.Resolver{Store: flaky}
+	startEngine(t, resolver, oneBatch(3))
+
+	errs := transferConcurrently(resolver, "0x1000", "0x1001", 40, 3)
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	require.Len(t, failed, 1, "Only two transfers of 40 fit in a balance of 100")
+	assert.EqualError(t, failed[0], "insufficient balance")
+	assert.Equal(t, 1, flaky.calls, "The batch should commit in one transaction")
+
+	sender, err := flaky.Wallet(context.Background(), "0x1000")
+	require.NoError(t, err)
+	assert.Equal(t, 20, sender.Balance)
+	receiver, err := flaky.Wallet(context.Background(), "0x1001")
+	require.NoError(t, err)
+	assert.Equal(t, 80, receiver.Balance)
+}
+
+func TestEngineBatchSeesEarlierTransfers(t *testing.T) {
+	flaky := newFlakyStore(t, 0, nil)
+	ctx := context.Background()
+	require.NoError(t, flaky.SetWalletLimits(ctx, models.WalletLimits{Address: "0x1000", DailyOutbound: 50}))
+	resolver := &graph.Resolver{Store: flaky}
+	startEngine(t, resolver, oneBatch(3))
+
+	errs := transferConcurrently(resolver, "0x1000", "0x1001", 20, 3)
+
+	limited := 0
+	for _, err := range errs {
+		if err != nil {
+			assert.EqualError(t, err, "transfer exceeds daily outbound limit")
+			limited++
+		}
+	}
+	assert.Equal(t, 1, limited, "The spend of earlier transfers in the batch must count")
+
+	spent, transfers, err := flaky.SpentSince(ctx, "0x1000", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 40, spent)
+	assert.Equal(t, 2, transfers)
+}
+
+func TestEngineFailedBatch(t *testing.T) {
+	flaky := newFlakyStore(t, 1, errors.New("connection reset"))
+	resolver := &graph.Resolver{Store: flaky}
+	startEngine(t, resolver, oneBatch(2))
+
+	for _, err := range transferConcurrently(resolver, "0x1000", "0x1001", 10, 2) {
+		assert.EqualError(t, err, "connection reset", "Every transfer of a failed batch should fail")
+	}
+	receiver, err := flaky.Wallet(context.Background(), "0x1001")
+	require.NoError(t, err)
+	assert.Equal(t, 0, receiver.Balance)
+}
+
+func TestEngineRetriesBatch(t *testing.T) {
+	flaky := newFlakyStore(t, 1, serializationFailure)
+	resolver := &graph.Resolver{Store: store.WithRetry(flaky, testRetryPolicy, gormstore.Retryable)}
+	startEngine(t, resolver, oneBatch(2))
+
+	for _, err := range transferConcurrently(resolver, "0x1000", "0x1001", 10, 2) {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, flaky.calls)
+	receiver, err := flaky.Wallet(context.Background(), "0x1001")
+	require.NoError(t, err)
+	assert.Equal(t, 20, receiver.Balance, "The aborted attempt must not be applied")
+}
+
+func TestEngineStop(t *testing.T) {
+	flaky := newFlakyStore(t, 0, nil)
+	resolver := &graph.Resolver{Store: flaky}
+	resolver.Engine = engine.New(flaky, oneBatch(10), resolver.BatchExecutor())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		resolver.Engine.Run(ctx)
+		close(done)
+	}()
+
+	// The engine waits for a full batch, so the transfer is still queued when it stops.
+	result := make(chan error)
+	go func() {
+		_, err := resolver.Transfer(context.Background(), "0x1000", "0x1001", 10)
+		result <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	assert.NoError(t, <-result, "Queued transfers should be executed before the engine stops")
+	_, err := resolver.Transfer(context.Background(), "0x1000", "0x1001", 10)
+	assert.ErrorIs(t, err, engine.ErrStopped)
+
+	receiver, err := flaky.Wallet(context.Background(), "0x1001")
+	require.NoError(t, err)
+	assert.Equal(t, 10, receiver.Balance)
+}
+
+func (suite *MemStoreTestSuite) TestEngineConcurrentTransfers() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0xA", 1000))
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0xB", 1000))
+	startEngine(suite.T(), suite.resolver, config.EngineConfig{MaxBatch: 16, MaxDelay: time.Millisecond, QueueSize: 64})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 400)
+	for i := 0; i < 200; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := suite.resolver.Transfer(ctx, "0xA", "0xB", 1)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := suite.resolver.Transfer(ctx, "0xB", "0xA", 2)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(suite.T(), err)
+	}
+	assert.Equal(suite.T(), 1200, suite.balance("0xA"))
+	assert.Equal(suite.T(), 800, suite.balance("0xB"))
+}
+
+func (suite *MemStoreTestSuite) TestEngineHotWallet() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(4)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	startEngine(suite.T(), resolver, oneBatch(5))
+
+	for _, err := range transferConcurrently(resolver, "0x1000", "0x1001", 100, 5) {
+		assert.NoError(suite.T(), err)
+	}
+	wallet, err := resolver.Query().Wallet(ctx, "0x1000", nil)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 9500, wallet.Balance)
+	assert.Equal(suite.T(), 500, suite.balance("0x1001"))
+}
+
+func (suite *MemStoreTestSuite) TestEngineHotWalletLockOrder() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(4)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	startEngine(suite.T(), resolver, config.EngineConfig{MaxBatch: 16, MaxDelay: time.Millisecond, QueueSize: 64})
+
+	// Like a payout from the hot wallet outside the engine, which locks a shard and then
+	// the receiver.
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- suite.store.Transaction(ctx, func(tx store.Tx) error {
+			for shard := range 4 {
+				if _, err := tx.LockWalletShard(ctx, "0x1000", shard); err != nil {
+					return err
+				}
+			}
+			close(locked)
+			<-release
+			timeout, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			_, err := tx.LockWallet(timeout, "0x1001")
+			return err
+		})
+	}()
+	<-locked
+
+	transferred := make(chan error, 1)
+	go func() {
+		_, err := resolver.Transfer(ctx, "0x1000", "0x1001", 100)
+		transferred <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	assert.NoError(suite.T(), <-done, "The engine should not hold the receiver while it waits for a shard")
+	assert.NoError(suite.T(), <-transferred)
+	assert.Equal(suite.T(), 100, suite.balance("0x1001"))
+}
+
+func (suite *GraphQLTestSuite) TestConcurrentTransfersEngine() {
+	initialBalance := 10000
+	num := 500
+	senders := 5
+	toAddress := "0xTEST9700"
+
+	suite.Require().NoError(models.InitializeWallet(suite.db, toAddress, 0))
+	for i := 0; i < senders; i++ {
+		suite.Require().NoError(models.InitializeWallet(suite.db, fmt.Sprintf("0xTEST97%02d", i+1), initialBalance))
+	}
+
+	resolver := &graph.Resolver{Store: gormstore.New(suite.db)}
+	startEngine(suite.T(), resolver, config.EngineConfig{MaxBatch: 50, MaxDelay: 5 * time.Millisecond, QueueSize: num})
+
+	var wg sync.WaitGroup
+	results := make(chan error, num)
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := resolver.Transfer(context.Background(), fmt.Sprintf("0xTEST97%02d", i%senders+1), toAddress, 1)
+			results <- err
+		}()
+	}
+	// Transfers that fail are rolled back within their batch.
+	failed := make(chan error, senders)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := resolver.Transfer(context.Background(), fmt.Sprintf("0xTEST97%02d", i+1), "0xTEST9799", 1)
+			failed <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(failed)
+
+	for err := range results {
+		assert.NoError(suite.T(), err)
+	}
+	for err := range failed {
+		assert.EqualError(suite.T(), err, "receiver wallet not found")
+	}
+
+	var receiver models.Wallet
+	suite.db.Where("address = ?", toAddress).First(&receiver)
+	assert.Equal(suite.T(), num, receiver.Balance)
+	var sent int
+	suite.db.Model(&models.Wallet{}).Where("address LIKE ? AND address <> ?", "0xTEST97%", toAddress).
+		Select("SUM(balance)").Scan(&sent)
+	assert.Equal(suite.T(), senders*initialBalance-num, sent)
+
+	var transfers int64
+	suite.db.Model(&models.Transfer{}).Where("to_address = ?", toAddress).Count(&transfers)
+	assert.Equal(suite.T(), int64(num), transfers, "Every transfer should be recorded")
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"token-transfer-api/ledger"
+	"token-transfer-api/models"
+	"token-transfer-api/reconcile"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *MemStoreTestSuite) TestFrozenWalletsCannotTransfer() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 100))
+
+	wallet, err := suite.store.SetWalletFrozen(ctx, "0x1001", true)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), wallet.Frozen)
+
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 10)
+	assert.ErrorContains(suite.T(), err, "wallet is frozen", "A frozen wallet must not send")
+	_, err = suite.resolver.Transfer(ctx, "0x1000", "0x1001", 10)
+	assert.ErrorContains(suite.T(), err, "wallet is frozen", "A frozen wallet must not receive")
+	assert.Equal(suite.T(), 100, suite.balance("0x1001"))
+
+	// Freezing twice records a single event.
+	_, err = suite.store.SetWalletFrozen(ctx, "0x1001", true)
+	suite.Require().NoError(err)
+	_, err = suite.store.SetWalletFrozen(ctx, "0x1001", false)
+	suite.Require().NoError(err)
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 10)
+	suite.Require().NoError(err)
+
+	events, err := suite.store.WalletEvents(ctx, "0x1001", 0)
+	suite.Require().NoError(err)
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	assert.Equal(suite.T(), []string{models.EventWalletCreated, models.EventFrozen, models.EventUnfrozen, models.EventDebited}, types)
+
+	_, err = suite.store.SetWalletFrozen(ctx, "0xMISSING", true)
+	assert.ErrorIs(suite.T(), err, models.ErrWalletNotFound)
+	suite.assertLedgerMatches()
+}
+
+func (suite *MemStoreTestSuite) TestMint() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(3)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	wallet, err := resolver.Mint(ctx, "0x1001", 250)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 250, wallet.Balance)
+	wallet, err = resolver.Mint(ctx, "0x1000", 500)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 10500, wallet.Balance, "Minting to a hot wallet should count its shards")
+
+	_, err = resolver.Mint(ctx, "0x1001", 0)
+	assert.Error(suite.T(), err)
+	_, err = resolver.Mint(ctx, "0xMISSING", 10)
+	assert.ErrorIs(suite.T(), err, models.ErrWalletNotFound)
+
+	report, err := reconcile.Run(ctx, suite.store)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), report.OK, "Discrepancies: %+v", report.Discrepancies)
+	assert.Equal(suite.T(), 10750, report.Minted)
+	assert.Equal(suite.T(), 10750, report.Supply)
+
+	diff, err := ledger.Diff(ctx, suite.store)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), diff.Mismatches)
+}
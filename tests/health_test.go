@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"token-transfer-api/db/migrations"
+	"token-transfer-api/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	checker := &health.Checker{}
+	recorder := httptest.NewRecorder()
+	checker.Healthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code, "Liveness must not depend on anything")
+}
+
+func (suite *GraphQLTestSuite) TestHealthReadinessAndStatus() {
+	migrator, err := migrations.New(suite.db)
+	assert.NoError(suite.T(), err, "Failed to load migrations")
+
+	draining := false
+	lastTransfer := time.Now()
+	checker := &health.Checker{
+		DB:           suite.db,
+		Migrator:     migrator,
+		Draining:     func() bool { return draining },
+		LastTransfer: func() time.Time { return lastTransfer },
+		Version:      "test",
+		Started:      time.Now().Add(-time.Minute),
+	}
+	mux := http.NewServeMux()
+	checker.Register(mux)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code, "Server should be ready")
+
+	draining = true
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(suite.T(), http.StatusServiceUnavailable, recorder.Code, "Readiness should fail while draining")
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(suite.T(), http.StatusOK, recorder.Code)
+
+	var status struct {
+		Status         string         `json:"status"`
+		Version        string         `json:"version"`
+		LastTransferAt *time.Time     `json:"lastTransferAt"`
+		DatabasePool   map[string]any `json:"databasePool"`
+	}
+	err = json.Unmarshal(recorder.Body.Bytes(), &status)
+	assert.NoError(suite.T(), err, "Status is not valid JSON")
+	assert.Equal(suite.T(), "not ready", status.Status)
+	assert.Equal(suite.T(), "test", status.Version)
+	assert.NotNil(suite.T(), status.LastTransferAt, "Missing last transfer time")
+	assert.Contains(suite.T(), status.DatabasePool, "openConnections", "Missing pool stats")
+}
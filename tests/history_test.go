@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"token-transfer-api/graph"
+	"token-transfer-api/ledger"
+	"token-transfer-api/models"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mark returns the current time, making sure events recorded before and after it fall
+// on different sides.
+func mark() time.Time {
+	time.Sleep(time.Millisecond)
+	at := time.Now()
+	time.Sleep(time.Millisecond)
+	return at
+}
+
+func (suite *MemStoreTestSuite) TestWalletAsOf() {
+	ctx := context.Background()
+	beforeWallet := mark()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	created := mark()
+
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 300)
+	suite.Require().NoError(err)
+	afterFirst := mark()
+	suite.Require().NoError(ledger.SnapshotAll(ctx, suite.store))
+	afterSnapshot := mark()
+
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 100)
+	suite.Require().NoError(err)
+	_, err = suite.store.VerifyWallet(ctx, "0x1001")
+	suite.Require().NoError(err)
+
+	current, err := suite.resolver.Query().Wallet(ctx, "0x1001", nil)
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), current.AsOf)
+
+	for _, tc := range []struct {
+		at       time.Time
+		balance  int
+		verified bool
+	}{
+		{created, 0, false},
+		{afterFirst, 300, false},
+		{afterSnapshot, 300, false},
+		{time.Now(), 200, true},
+	} {
+		wallet, err := suite.resolver.Query().Wallet(ctx, "0x1001", &tc.at)
+		suite.Require().NoError(err)
+		assert.Equal(suite.T(), tc.balance, wallet.Balance)
+		assert.Equal(suite.T(), tc.verified, wallet.Verified)
+		assert.Equal(suite.T(), current.ID, wallet.ID)
+		assert.Equal(suite.T(), tc.at, *wallet.AsOf)
+	}
+
+	_, err = suite.resolver.Query().Wallet(ctx, "0x1001", &beforeWallet)
+	assert.ErrorIs(suite.T(), err, models.ErrWalletNotFound, "A wallet created later should not be found")
+
+	balances, err := suite.resolver.Query().Balances(ctx, beforeWallet)
+	suite.Require().NoError(err)
+	suite.Require().Len(balances, 1)
+	assert.Equal(suite.T(), "0x1000", balances[0].Address)
+	assert.Equal(suite.T(), 10000, balances[0].Balance)
+
+	balances, err = suite.resolver.Query().Balances(ctx, afterSnapshot)
+	suite.Require().NoError(err)
+	suite.Require().Len(balances, 2)
+	assert.Equal(suite.T(), 9700, balances[0].Balance)
+	assert.Equal(suite.T(), 300, balances[1].Balance)
+}
+
+func (suite *MemStoreTestSuite) TestBalancesAsOfPages() {
+	ctx := context.Background()
+	wallets := 1200
+	for i := range wallets {
+		suite.Require().NoError(suite.store.CreateWallet(ctx, fmt.Sprintf("0x2%04d", i), 1))
+	}
+	suite.Require().NoError(ledger.SnapshotAll(ctx, suite.store))
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x21100", 10)
+	suite.Require().NoError(err)
+
+	balances, err := suite.resolver.Query().Balances(ctx, time.Now())
+	suite.Require().NoError(err)
+	suite.Require().Len(balances, wallets+1, "Every page of wallets should be rebuilt")
+	total := 0
+	for i, wallet := range balances {
+		if i > 0 {
+			assert.Less(suite.T(), balances[i-1].Address, wallet.Address)
+		}
+		total += wallet.Balance
+	}
+	assert.Equal(suite.T(), 10000+wallets, total)
+	assert.Equal(suite.T(), 11, balances[1101].Balance)
+}
+
+func (suite *MemStoreTestSuite) TestWalletAsOfSkipsLaterSnapshots() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 300)
+	suite.Require().NoError(err)
+	suite.Require().NoError(ledger.SnapshotAll(ctx, suite.store))
+	first := mark()
+
+	_, err = suite.resolver.Transfer(ctx, "0x1000", "0x1001", 200)
+	suite.Require().NoError(err)
+	second := mark()
+	suite.Require().NoError(ledger.SnapshotAll(ctx, suite.store))
+
+	wallet, err := ledger.ProjectAsOf(ctx, suite.store, "0x1001", first)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 300, wallet.Balance)
+	wallet, err = ledger.ProjectAsOf(ctx, suite.store, "0x1001", second)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 500, wallet.Balance)
+}
+
+func (suite *MemStoreTestSuite) TestWalletAsOfLockedBalance() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 100))
+	beforeGrant := mark()
+
+	now := time.Now()
+	_, err := suite.resolver.GrantVesting(ctx, "0x1000", "0x1001", 1000, now.Add(-time.Hour), now.Add(24*time.Hour), now.Add(48*time.Hour))
+	suite.Require().NoError(err)
+	afterGrant := mark()
+
+	wallet, err := suite.resolver.Query().Wallet(ctx, "0x1001", &beforeGrant)
+	suite.Require().NoError(err)
+	locked, err := suite.resolver.Wallet().LockedBalance(ctx, wallet)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 0, locked, "Tranches granted later should not be locked")
+	spendable, err := suite.resolver.Wallet().SpendableBalance(ctx, wallet)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 100, spendable)
+
+	wallet, err = suite.resolver.Query().Wallet(ctx, "0x1001", &afterGrant)
+	suite.Require().NoError(err)
+	spendable, err = suite.resolver.Wallet().SpendableBalance(ctx, wallet)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1100, wallet.Balance)
+	assert.Equal(suite.T(), 100, spendable)
+}
+
+func (suite *GraphQLTestSuite) TestWalletAsOf() {
+	ctx := context.Background()
+	fromAddress := "0xTEST9900"
+	toAddress := "0xTEST9901"
+	suite.Require().NoError(models.InitializeWallet(suite.db, fromAddress, 1000))
+	suite.Require().NoError(models.InitializeWallet(suite.db, toAddress, 0))
+
+	s := gormstore.New(suite.db)
+	resolver := &graph.Resolver{Store: s}
+	_, err := resolver.Transfer(ctx, fromAddress, toAddress, 100)
+	suite.Require().NoError(err)
+	_, err = ledger.Snapshot(ctx, s, toAddress)
+	suite.Require().NoError(err)
+	afterSnapshot := mark()
+
+	_, err = resolver.Transfer(ctx, fromAddress, toAddress, 250)
+	suite.Require().NoError(err)
+
+	wallet, err := resolver.Query().Wallet(ctx, toAddress, &afterSnapshot)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 100, wallet.Balance)
+
+	now := time.Now()
+	wallet, err = resolver.Query().Wallet(ctx, toAddress, &now)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 350, wallet.Balance)
+
+	balances, err := resolver.Query().Balances(ctx, afterSnapshot)
+	suite.Require().NoError(err)
+	found := map[string]int{}
+	for _, wallet := range balances {
+		found[wallet.Address] = wallet.Balance
+	}
+	assert.Equal(suite.T(), 900, found[fromAddress])
+	assert.Equal(suite.T(), 100, found[toAddress])
+}
@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"time"
+	"token-transfer-api/config"
+	"token-transfer-api/graph"
+	"token-transfer-api/ledger"
+	"token-transfer-api/models"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertLedgerMatches checks that every wallet rebuilt from its events matches the tables.
+func (suite *MemStoreTestSuite) assertLedgerMatches() {
+	report, err := ledger.Diff(context.Background(), suite.store)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), report.Mismatches)
+}
+
+func (suite *MemStoreTestSuite) TestLedgerRebuildsWallets() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 300)
+	suite.Require().NoError(err)
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 100)
+	suite.Require().NoError(err)
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 1000)
+	suite.Require().Error(err)
+	_, err = suite.store.VerifyWallet(ctx, "0x1001")
+	suite.Require().NoError(err)
+
+	events, err := suite.store.WalletEvents(ctx, "0x1001", 0)
+	suite.Require().NoError(err)
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	assert.Equal(suite.T(), []string{models.EventWalletCreated, models.EventCredited, models.EventDebited, models.EventVerified}, types,
+		"Failed transfers must not record events")
+
+	wallet, seq, err := ledger.Project(ctx, suite.store, "0x1001", true)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 200, wallet.Balance)
+	assert.True(suite.T(), wallet.Verified)
+	assert.Equal(suite.T(), events[len(events)-1].Seq, seq)
+
+	report, err := ledger.Diff(ctx, suite.store)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 2, report.Wallets)
+	assert.Empty(suite.T(), report.Mismatches)
+}
+
+func (suite *MemStoreTestSuite) TestLedgerSnapshots() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 300)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(ledger.SnapshotAll(ctx, suite.store))
+	snapshot, err := suite.store.LatestSnapshot(ctx, "0x1000")
+	suite.Require().NoError(err)
+	suite.Require().NotNil(snapshot)
+	assert.Equal(suite.T(), 9700, snapshot.Balance)
+
+	again, err := ledger.Snapshot(ctx, suite.store, "0x1000")
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), again, "No snapshot should be taken without new events")
+
+	_, err = suite.resolver.Transfer(ctx, "0x1000", "0x1001", 200)
+	suite.Require().NoError(err)
+
+	fromSnapshot, _, err := ledger.Project(ctx, suite.store, "0x1000", false)
+	suite.Require().NoError(err)
+	fromScratch, _, err := ledger.Project(ctx, suite.store, "0x1000", true)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 9500, fromSnapshot.Balance)
+	assert.Equal(suite.T(), fromScratch, fromSnapshot)
+
+	next, err := ledger.Snapshot(ctx, suite.store, "0x1000")
+	suite.Require().NoError(err)
+	suite.Require().NotNil(next)
+	assert.Greater(suite.T(), next.Seq, snapshot.Seq)
+	suite.assertLedgerMatches()
+}
+
+func (suite *MemStoreTestSuite) TestLedgerDiffReportsMismatches() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	suite.Require().NoError(ledger.SnapshotAll(ctx, suite.store))
+
+	// Changes that bypass the event stream.
+	wallet, err := suite.store.Wallet(ctx, "0x1000")
+	suite.Require().NoError(err)
+	wallet.Balance = 1
+	suite.Require().NoError(suite.store.SaveWallet(ctx, wallet))
+	suite.Require().NoError(suite.store.SaveSnapshot(ctx, &models.WalletSnapshot{Address: "0x1001", Seq: 1 << 40, Balance: 5, CreatedAt: time.Now()}))
+	suite.Require().NoError(suite.store.AppendEvents(ctx, &models.WalletEvent{Address: "0x2000", Type: models.EventWalletCreated, Amount: 7, CreatedAt: time.Now()}))
+
+	report, err := ledger.Diff(ctx, suite.store)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 3, report.Wallets)
+	suite.Require().Len(report.Mismatches, 3)
+
+	assert.Equal(suite.T(), "0x1000", report.Mismatches[0].Address)
+	assert.Equal(suite.T(), ledger.SourceWallet, report.Mismatches[0].Source)
+	assert.Equal(suite.T(), 1, report.Mismatches[0].Stored.Balance)
+	assert.Equal(suite.T(), 10000, report.Mismatches[0].Projected.Balance)
+
+	assert.Equal(suite.T(), "0x1001", report.Mismatches[1].Address)
+	assert.Equal(suite.T(), ledger.SourceSnapshot, report.Mismatches[1].Source)
+	assert.Equal(suite.T(), 5, report.Mismatches[1].Stored.Balance)
+	assert.Equal(suite.T(), 0, report.Mismatches[1].Projected.Balance)
+
+	assert.Equal(suite.T(), "0x2000", report.Mismatches[2].Address)
+	assert.Nil(suite.T(), report.Mismatches[2].Stored, "A wallet with events but no row should be reported")
+}
+
+func (suite *MemStoreTestSuite) TestLedgerHotWallets() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(4)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	_, err := resolver.Transfer(ctx, "0x1000", "0x1001", 6000)
+	suite.Require().NoError(err)
+	_, err = resolver.Transfer(ctx, "0x1001", "0x1000", 10)
+	suite.Require().NoError(err)
+	suite.Require().NoError(resolver.RebalanceHotWallets(ctx))
+
+	suite.assertLedgerMatches()
+}
+
+func (suite *MemStoreTestSuite) TestLedgerEngine() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	startEngine(suite.T(), suite.resolver, oneBatch(4))
+
+	errs := transferConcurrently(suite.resolver, "0x1000", "0x1001", 4000, 4)
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	assert.Equal(suite.T(), 2, failed)
+	suite.assertLedgerMatches()
+}
+
+func (suite *GraphQLTestSuite) TestLedgerRebuild() {
+	ctx := context.Background()
+	fromAddress := "0xTEST9800"
+	toAddress := "0xTEST9801"
+	suite.Require().NoError(models.InitializeWallet(suite.db, fromAddress, 1000))
+	suite.Require().NoError(models.InitializeWallet(suite.db, toAddress, 0))
+
+	s := gormstore.New(suite.db)
+	resolver := &graph.Resolver{Store: s}
+	_, err := resolver.Transfer(ctx, fromAddress, toAddress, 100)
+	suite.Require().NoError(err)
+	_, err = s.VerifyWallet(ctx, toAddress)
+	suite.Require().NoError(err)
+
+	snapshot, err := ledger.Snapshot(ctx, s, toAddress)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(snapshot)
+	assert.Equal(suite.T(), 100, snapshot.Balance)
+	assert.True(suite.T(), snapshot.Verified)
+
+	// Transfers through the engine record their events in savepoints.
+	startEngine(suite.T(), resolver, config.EngineConfig{MaxBatch: 4, MaxDelay: 10 * time.Millisecond, QueueSize: 4})
+	transferConcurrently(resolver, fromAddress, toAddress, 300, 4)
+
+	wallet, _, err := ledger.Project(ctx, s, toAddress, false)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1000, wallet.Balance)
+	assert.True(suite.T(), wallet.Verified)
+
+	report, err := ledger.Diff(ctx, s)
+	suite.Require().NoError(err)
+	for _, mismatch := range report.Mismatches {
+		assert.False(suite.T(), strings.HasPrefix(mismatch.Address, "0xTEST98"), "Unexpected mismatch for %s", mismatch.Address)
+	}
+}
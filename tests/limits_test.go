@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"token-transfer-api/graph"
+	"token-transfer-api/models"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *GraphQLTestSuite) TestTransferSingleLimit() {
+	fromAddress := "0x1000"
+	toAddress := "0xTEST9001"
+
+	err := models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+	err = models.SetWalletLimits(suite.db, models.WalletLimits{Address: fromAddress, MaxSingleTransfer: 100})
+	assert.NoError(suite.T(), err, "Failed to set limits")
+
+	_, err = suite.resolver.Transfer(context.Background(), fromAddress, toAddress, 101)
+	assert.Error(suite.T(), err, "Expected single transfer limit error")
+	assert.Equal(suite.T(), "amount exceeds single transfer limit", err.Error(), "Incorrect error message")
+
+	_, err = suite.resolver.Transfer(context.Background(), fromAddress, toAddress, 100)
+	assert.NoError(suite.T(), err, "Transfer within limit failed")
+}
+
+func (suite *GraphQLTestSuite) TestTransferDailyLimitConcurrent() {
+	fromAddress := "0xTEST9002"
+	toAddress := "0xTEST9003"
+	dailyLimit := 25
+	num := 50
+
+	err := models.InitializeWallet(suite.db, fromAddress, 1000)
+	assert.NoError(suite.T(), err, "Failed to initialize sender wallet")
+	err = models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+	err = models.SetWalletLimits(suite.db, models.WalletLimits{Address: fromAddress, DailyOutbound: dailyLimit})
+	assert.NoError(suite.T(), err, "Failed to set limits")
+
+	start := make(chan struct{})
+	results := make(chan error, num)
+	var wg sync.WaitGroup
+
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := suite.resolver.Transfer(context.Background(), fromAddress, toAddress, 1)
+			results <- err
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+	close(results)
+
+	successCount := 0
+	for err := range results {
+		if err == nil {
+			successCount++
+		} else {
+			assert.Equal(suite.T(), "transfer exceeds daily outbound limit", err.Error())
+		}
+	}
+	assert.Equal(suite.T(), dailyLimit, successCount, "Daily limit was not enforced atomically")
+
+	var finalReceiver models.Wallet
+	suite.db.Where("address = ?", toAddress).First(&finalReceiver)
+	assert.Equal(suite.T(), dailyLimit, finalReceiver.Balance)
+}
+
+func (suite *GraphQLTestSuite) TestTransferUnverifiedLimits() {
+	fromAddress := "0xTEST9004"
+	toAddress := "0xTEST9005"
+
+	err := models.InitializeWallet(suite.db, fromAddress, 1000)
+	assert.NoError(suite.T(), err, "Failed to initialize sender wallet")
+	err = models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+
+	resolver := &graph.Resolver{
+		Store:            gormstore.New(suite.db),
+		UnverifiedLimits: &models.WalletLimits{MaxTransfersPerWindow: 2, WindowMinutes: 60},
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = resolver.Transfer(context.Background(), fromAddress, toAddress, 1)
+		assert.NoError(suite.T(), err, "Transfer within limit failed")
+	}
+	_, err = resolver.Transfer(context.Background(), fromAddress, toAddress, 1)
+	assert.Error(suite.T(), err, "Expected transfer count limit error")
+	assert.Equal(suite.T(), "too many transfers in window", err.Error(), "Incorrect error message")
+
+	_, err = models.VerifyWallet(suite.db, fromAddress)
+	assert.NoError(suite.T(), err, "Failed to verify wallet")
+	_, err = resolver.Transfer(context.Background(), fromAddress, toAddress, 1)
+	assert.NoError(suite.T(), err, "Verified wallet should not be limited")
+}
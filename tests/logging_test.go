@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"token-transfer-api/graph"
+	"token-transfer-api/logging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs routes the default logger to a buffer for the duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buffer bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buffer, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buffer
+}
+
+// logRecords decodes the JSON records written to buffer.
+func logRecords(t *testing.T, buffer *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record), "Log line is not JSON: %s", line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggingRedactsSensitiveFields(t *testing.T) {
+	buffer := captureLogs(t)
+
+	slog.Info("signer", "signature", "deadbeef", "Password", "hunter2", "address", "0xA")
+
+	records := logRecords(t, buffer)
+	require.Len(t, records, 1)
+	assert.Equal(t, logging.Redacted, records[0]["signature"])
+	assert.Equal(t, logging.Redacted, records[0]["Password"])
+	assert.Equal(t, "0xA", records[0]["address"])
+	assert.NotContains(t, buffer.String(), "hunter2")
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	buffer := captureLogs(t)
+
+	var seen string
+	handler := logging.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+		slog.InfoContext(r.Context(), "inside")
+	}))
+
+	request := httptest.NewRequest(http.MethodPost, "/query", nil)
+	request.Header.Set(logging.RequestIDHeader, "client-id-1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, "client-id-1", seen, "A well-formed request ID should be reused")
+	assert.Equal(t, "client-id-1", recorder.Header().Get(logging.RequestIDHeader))
+
+	records := logRecords(t, buffer)
+	require.Len(t, records, 2)
+	for _, record := range records {
+		assert.Equal(t, "client-id-1", record["request_id"])
+		assert.Equal(t, logging.Anonymous, record["principal"])
+	}
+	assert.Equal(t, "request", records[1]["msg"])
+	assert.Equal(t, float64(http.StatusOK), records[1]["status"])
+
+	request = httptest.NewRequest(http.MethodPost, "/query", nil)
+	request.Header.Set(logging.RequestIDHeader, "bad id\nwith newline")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	assert.Len(t, seen, 32, "A malformed request ID should be replaced")
+	assert.Equal(t, seen, recorder.Header().Get(logging.RequestIDHeader))
+}
+
+func TestGORMLoggerSlowQuery(t *testing.T) {
+	buffer := captureLogs(t)
+	logger := logging.GORM{SlowThreshold: 10 * time.Millisecond}
+
+	query := func() (string, int64) { return `SELECT * FROM "wallets" WHERE address = $1`, 1 }
+	logger.Trace(context.Background(), time.Now(), query, nil)
+	logger.Trace(context.Background(), time.Now().Add(-time.Second), query, nil)
+
+	records := logRecords(t, buffer)
+	require.Len(t, records, 2)
+	assert.Equal(t, "DEBUG", records[0]["level"])
+	assert.Equal(t, "WARN", records[1]["level"])
+	assert.Equal(t, "slow query", records[1]["msg"])
+	assert.Equal(t, `SELECT * FROM "wallets" WHERE address = $1`, records[1]["sql"])
+
+	sql, params := logger.ParamsFilter(context.Background(), "SELECT $1", "secret")
+	assert.Equal(t, "SELECT $1", sql)
+	assert.Empty(t, params, "Bound values must not be logged")
+}
+
+func TestTransferAttemptLogged(t *testing.T) {
+	buffer := captureLogs(t)
+
+	ctx := logging.WithPrincipal(logging.WithRequestID(context.Background(), "req-1"), "operator")
+	_, err := (&graph.Resolver{}).Transfer(ctx, "0xA", "0xB", 0)
+	assert.Error(t, err)
+
+	records := logRecords(t, buffer)
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, "transfer", record["msg"])
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "invalid_amount", record["outcome"])
+	assert.Equal(t, "operator", record["principal"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Contains(t, record, "latency")
+}
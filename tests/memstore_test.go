@@ -0,0 +1,358 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"token-transfer-api/graph"
+	gqlmodels "token-transfer-api/graph/models"
+	"token-transfer-api/logging"
+	"token-transfer-api/models"
+	"token-transfer-api/rules"
+	"token-transfer-api/store"
+	"token-transfer-api/store/memstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// MemStoreTestSuite runs the resolver against the in-memory store, so it needs no database.
+type MemStoreTestSuite struct {
+	suite.Suite
+	store    *memstore.Store
+	resolver *graph.Resolver
+}
+
+func (suite *MemStoreTestSuite) SetupTest() {
+	suite.store = memstore.New()
+	suite.resolver = &graph.Resolver{Store: suite.store}
+	suite.Require().NoError(suite.store.CreateWallet(context.Background(), "0x1000", 10000))
+}
+
+func (suite *MemStoreTestSuite) balance(address string) int {
+	wallet, err := suite.store.Wallet(context.Background(), address)
+	suite.Require().NoError(err)
+	return wallet.Balance
+}
+
+func (suite *MemStoreTestSuite) TestTransfer() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	wallet, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 100)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 9900, wallet.Balance)
+	assert.Equal(suite.T(), 100, suite.balance("0x1001"))
+
+	_, err = suite.resolver.Transfer(ctx, "0x1001", "0x1000", 101)
+	assert.EqualError(suite.T(), err, "insufficient balance")
+	_, err = suite.resolver.Transfer(ctx, "0x1000", "0x9999", 1)
+	assert.EqualError(suite.T(), err, "receiver wallet not found")
+	_, err = suite.resolver.Transfer(ctx, "0x9999", "0x1000", 1)
+	assert.EqualError(suite.T(), err, "sender wallet not found")
+
+	assert.Equal(suite.T(), 9900, suite.balance("0x1000"), "Failed transfers must not move funds")
+}
+
+func (suite *MemStoreTestSuite) TestConcurrentTransfers() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0xA", 1000))
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0xB", 1000))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 400)
+	for i := 0; i < 200; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := suite.resolver.Transfer(ctx, "0xA", "0xB", 1)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := suite.resolver.Transfer(ctx, "0xB", "0xA", 2)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(suite.T(), err)
+	}
+	assert.Equal(suite.T(), 1200, suite.balance("0xA"))
+	assert.Equal(suite.T(), 800, suite.balance("0xB"))
+}
+
+func (suite *MemStoreTestSuite) TestRollbackDiscardsWrites() {
+	ctx := context.Background()
+	failure := errors.New("fail")
+
+	err := suite.store.Transaction(ctx, func(tx store.Tx) error {
+		wallet, err := tx.LockWallet(ctx, "0x1000")
+		suite.Require().NoError(err)
+		wallet.Balance = 0
+		suite.Require().NoError(tx.SaveWallet(ctx, wallet))
+		suite.Require().NoError(tx.CreateWallet(ctx, "0x2000", 5))
+
+		seen, err := tx.Wallet(ctx, "0x1000")
+		suite.Require().NoError(err)
+		assert.Equal(suite.T(), 0, seen.Balance, "A transaction must see its own writes")
+		return failure
+	})
+	assert.ErrorIs(suite.T(), err, failure)
+
+	assert.Equal(suite.T(), 10000, suite.balance("0x1000"))
+	_, err = suite.store.Wallet(ctx, "0x2000")
+	assert.ErrorIs(suite.T(), err, models.ErrWalletNotFound)
+}
+
+func (suite *MemStoreTestSuite) TestSavepointRollsBackWrites() {
+	ctx := context.Background()
+	failure := errors.New("fail")
+
+	err := suite.store.Transaction(ctx, func(tx store.Tx) error {
+		suite.Require().NoError(tx.CreateWallet(ctx, "0x2000", 5))
+		err := tx.Transaction(ctx, func(tx store.Tx) error {
+			suite.Require().NoError(tx.CreateWallet(ctx, "0x2001", 5))
+			return failure
+		})
+		assert.ErrorIs(suite.T(), err, failure)
+		return nil
+	})
+	suite.Require().NoError(err)
+
+	_, err = suite.store.Wallet(ctx, "0x2000")
+	assert.NoError(suite.T(), err, "Writes before the savepoint should commit")
+	_, err = suite.store.Wallet(ctx, "0x2001")
+	assert.ErrorIs(suite.T(), err, models.ErrWalletNotFound, "Writes in the failed savepoint should be discarded")
+}
+
+func (suite *MemStoreTestSuite) TestLockWaitsForCommit() {
+	ctx := context.Background()
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- suite.store.Transaction(ctx, func(tx store.Tx) error {
+			wallet, err := tx.LockWallet(ctx, "0x1000")
+			if err != nil {
+				return err
+			}
+			wallet.Balance = 1
+			if err := tx.SaveWallet(ctx, wallet); err != nil {
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	assert.Equal(suite.T(), 10000, suite.balance("0x1000"), "Uncommitted writes must not be visible")
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err := suite.store.LockWallet(timeout, "0x1000")
+	assert.ErrorIs(suite.T(), err, context.DeadlineExceeded, "Lock should wait while another transaction holds it")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	wallet, err := suite.store.LockWallet(ctx, "0x1000")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1, wallet.Balance, "Lock should return the committed row")
+	assert.NoError(suite.T(), <-done)
+}
+
+func (suite *MemStoreTestSuite) TestSavepointRollbackReleasesLocks() {
+	ctx := context.Background()
+	rolledBack := errors.New("rolled back")
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- suite.store.Transaction(ctx, func(tx store.Tx) error {
+			err := tx.Transaction(ctx, func(tx store.Tx) error {
+				if _, err := tx.LockWallet(ctx, "0x1000"); err != nil {
+					return err
+				}
+				return rolledBack
+			})
+			if !errors.Is(err, rolledBack) {
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err := suite.store.LockWallet(timeout, "0x1000")
+	assert.NoError(suite.T(), err, "Rolling back a savepoint should release the locks it took")
+	close(release)
+	assert.NoError(suite.T(), <-done)
+}
+
+func (suite *MemStoreTestSuite) TestLimits() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	suite.resolver.UnverifiedLimits = &models.WalletLimits{MaxTransfersPerWindow: 2, WindowMinutes: 60}
+
+	for i := 0; i < 2; i++ {
+		_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 1)
+		suite.Require().NoError(err)
+	}
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 1)
+	assert.EqualError(suite.T(), err, "too many transfers in window")
+
+	_, err = suite.resolver.VerifyWallet(ctx, "0x1000")
+	assert.EqualError(suite.T(), err, "forbidden: admin principal required", "Wallets cannot verify themselves")
+	_, err = suite.resolver.SetWalletLimits(ctx, "0x1000", gqlmodels.WalletLimitsInput{})
+	assert.EqualError(suite.T(), err, "forbidden: admin principal required")
+
+	suite.resolver.Admins = []string{"compliance"}
+	_, err = suite.resolver.VerifyWallet(logging.WithPrincipal(ctx, "compliance"), "0x1000")
+	suite.Require().NoError(err)
+	_, err = suite.resolver.Transfer(ctx, "0x1000", "0x1001", 1)
+	assert.NoError(suite.T(), err, "Verified wallets are not limited by default")
+}
+
+func (suite *MemStoreTestSuite) TestUnlimitedTransfersAreNotCounted() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 1)
+	suite.Require().NoError(err)
+	spent, transfers, err := suite.store.SpentSince(ctx, "0x1000", time.Now().Add(-time.Hour))
+	suite.Require().NoError(err)
+	assert.Zero(suite.T(), spent+transfers, "Transfers without limits should not write spend counters")
+
+	suite.Require().NoError(suite.store.SetWalletLimits(ctx, models.WalletLimits{Address: "0x1000", DailyOutbound: 10}))
+	_, err = suite.resolver.Transfer(ctx, "0x1000", "0x1001", 1)
+	suite.Require().NoError(err)
+	spent, transfers, err = suite.store.SpentSince(ctx, "0x1000", time.Now().Add(-time.Hour))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1, spent)
+	assert.Equal(suite.T(), 1, transfers)
+}
+
+func (suite *MemStoreTestSuite) TestReviewQueue() {
+	ctx := logging.WithPrincipal(context.Background(), "compliance")
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	suite.resolver.Rules = rules.NewEngine(&rules.AmountThreshold{Amount: 500, Decision: rules.Review})
+	suite.resolver.Admins = []string{"compliance"}
+
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 600)
+	assert.ErrorContains(suite.T(), err, "transfer requires review")
+
+	pending := models.ReviewPending
+	reviews, err := suite.resolver.Query().TransferReviews(ctx, &pending)
+	suite.Require().NoError(err)
+	suite.Require().Len(reviews, 1)
+	assert.Equal(suite.T(), 0, suite.balance("0x1001"))
+
+	review, err := suite.resolver.ApproveTransferReview(ctx, reviews[0].ID.String())
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.ReviewApproved, review.Status)
+	assert.Equal(suite.T(), 600, suite.balance("0x1001"))
+
+	_, err = suite.resolver.RejectTransferReview(ctx, review.ID.String(), nil)
+	assert.ErrorIs(suite.T(), err, models.ErrReviewDecided)
+}
+
+func (suite *MemStoreTestSuite) TestReviewDecisionsNeedAdmin() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	suite.resolver.Rules = rules.NewEngine(&rules.AmountThreshold{Amount: 500, Decision: rules.Review})
+	suite.resolver.Admins = []string{"compliance"}
+
+	_, err := suite.resolver.Transfer(ctx, "0x1000", "0x1001", 600)
+	assert.ErrorContains(suite.T(), err, "transfer requires review")
+	reviews, err := suite.store.Reviews(ctx, nil)
+	suite.Require().NoError(err)
+	suite.Require().Len(reviews, 1)
+	id := reviews[0].ID.String()
+
+	for _, principal := range []string{"", logging.Anonymous, "0x1000"} {
+		caller := logging.WithPrincipal(ctx, principal)
+		_, err = suite.resolver.ApproveTransferReview(caller, id)
+		assert.EqualError(suite.T(), err, "forbidden: admin principal required", "principal %q", principal)
+		_, err = suite.resolver.RejectTransferReview(caller, id, nil)
+		assert.EqualError(suite.T(), err, "forbidden: admin principal required", "principal %q", principal)
+		_, err = suite.resolver.Query().TransferReviews(caller, nil)
+		assert.EqualError(suite.T(), err, "forbidden: admin principal required", "principal %q", principal)
+	}
+	assert.Equal(suite.T(), 0, suite.balance("0x1001"), "A refused approval must not move funds")
+
+	pending := models.ReviewPending
+	reviews, err = suite.store.Reviews(ctx, &pending)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), reviews, 1, "A refused decision must leave the review pending")
+}
+
+func (suite *MemStoreTestSuite) TestMultisigWalletIsCreatedOnce() {
+	ctx := context.Background()
+	admin := logging.WithPrincipal(ctx, "custody")
+	suite.resolver.Admins = []string{"custody"}
+	alice, mallory := newTestSigner("alice"), newTestSigner("mallory")
+
+	_, err := suite.resolver.CreateMultisigWallet(ctx, "0x1000", []*gqlmodels.MultisigSignerInput{mallory.input()}, 1)
+	assert.EqualError(suite.T(), err, "forbidden: admin principal required")
+
+	_, err = suite.resolver.CreateMultisigWallet(admin, "0x1000", []*gqlmodels.MultisigSignerInput{alice.input()}, 1)
+	suite.Require().NoError(err)
+	_, err = suite.resolver.CreateMultisigWallet(admin, "0x1000", []*gqlmodels.MultisigSignerInput{mallory.input()}, 1)
+	assert.ErrorIs(suite.T(), err, models.ErrMultisigExists)
+
+	signers, err := suite.store.Signers(ctx, "0x1000")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []string{"alice"}, signers, "A refused registration must keep the signers")
+}
+
+func (suite *MemStoreTestSuite) TestSimulationRollsBack() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	simulation, err := suite.resolver.SimulateTransfer(ctx, "0x1000", "0x1001", 100)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), simulation.Ok)
+	assert.Equal(suite.T(), 100, *simulation.ToBalance)
+	assert.Equal(suite.T(), 0, suite.balance("0x1001"), "Simulation must not commit")
+}
+
+func TestMemStoreSuite(t *testing.T) {
+	suite.Run(t, new(MemStoreTestSuite))
+}
+
+func TestMemStoreSpendCounters(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	require.NoError(t, s.CreateWallet(ctx, "0xA", 0))
+
+	now := time.Now()
+	old := now.Add(-models.SpendRetention - time.Hour)
+	require.NoError(t, s.RecordSpend(ctx, "0xA", 5, old))
+	require.NoError(t, s.RecordSpend(ctx, "0xA", 3, now))
+	require.NoError(t, s.RecordSpend(ctx, "0xA", 4, now))
+
+	amount, transfers, err := s.SpentSince(ctx, "0xA", now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 7, amount)
+	assert.Equal(t, 2, transfers)
+
+	require.NoError(t, s.PruneSpendCounters(ctx, "0xA", now))
+	amount, transfers, err = s.SpentSince(ctx, "0xA", old)
+	require.NoError(t, err)
+	assert.Equal(t, 7, amount, "Counters past retention should be pruned")
+	assert.Equal(t, 2, transfers)
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"token-transfer-api/graph"
+	"token-transfer-api/graph/generated"
+	"token-transfer-api/metrics"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQLMetrics(t *testing.T) {
+	srv := handler.New(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{}}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(metrics.Tracer{})
+
+	fieldBefore := sampleCount(t, metrics.GraphQLFields.WithLabelValues("Query.simulateTransfer", "ok"))
+	operationBefore := sampleCount(t, metrics.GraphQLOperations.WithLabelValues("query", "ok"))
+
+	body := `{"query": "{ simulateTransfer(fromAddress: \"0xA\", toAddress: \"0xB\", amount: 0) { ok violations } }"}`
+	request := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	srv.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "amount must be positive")
+
+	assert.Equal(t, fieldBefore+1, sampleCount(t, metrics.GraphQLFields.WithLabelValues("Query.simulateTransfer", "ok")),
+		"Root field latency was not recorded")
+	assert.Equal(t, operationBefore+1, sampleCount(t, metrics.GraphQLOperations.WithLabelValues("query", "ok")),
+		"Operation latency was not recorded")
+}
+
+// sampleCount returns the number of observations of a histogram series.
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	var m dto.Metric
+	err := observer.(prometheus.Metric).Write(&m)
+	assert.NoError(t, err)
+	return m.GetHistogram().GetSampleCount()
+}
+
+func (suite *GraphQLTestSuite) TestTransferOutcomeMetrics() {
+	success := metrics.Transfers.WithLabelValues("success")
+	notFound := metrics.Transfers.WithLabelValues("receiver_not_found")
+	successBefore := testutil.ToFloat64(success)
+	notFoundBefore := testutil.ToFloat64(notFound)
+
+	_, err := suite.resolver.Transfer(context.Background(), "0x1000", "0xTEST9401", 1)
+	assert.Error(suite.T(), err, "Expected receiver wallet not found error")
+	assert.Equal(suite.T(), notFoundBefore+1, testutil.ToFloat64(notFound), "Not found outcome not counted")
+	assert.Equal(suite.T(), successBefore, testutil.ToFloat64(success), "Failed transfer counted as success")
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"testing"
+	"token-transfer-api/db/migrations"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationsLoad(t *testing.T) {
+	for _, dialect := range migrations.Dialects {
+		loaded, err := migrations.Load(dialect)
+		assert.NoError(t, err, "Failed to load embedded %s migrations", dialect)
+		assert.NotEmpty(t, loaded, "No %s migrations embedded", dialect)
+
+		for i, migration := range loaded {
+			assert.Equal(t, i+1, migration.Version, "Migration versions must be contiguous")
+			assert.NotEmpty(t, migration.Name)
+			assert.NotEmpty(t, migration.Up, "Missing up migration")
+			assert.NotEmpty(t, migration.Down, "Missing down migration")
+		}
+	}
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	postgres, err := migrations.Load("postgres")
+	assert.NoError(t, err)
+	sqlite, err := migrations.Load("sqlite")
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(postgres), len(sqlite), "Every dialect needs the same migrations")
+	for i := range min(len(postgres), len(sqlite)) {
+		assert.Equal(t, postgres[i].Name, sqlite[i].Name, "Migration %d differs between dialects", postgres[i].Version)
+	}
+}
+
+func TestMigrationsLoadUnknownDialect(t *testing.T) {
+	_, err := migrations.Load("mysql")
+	assert.Error(t, err)
+}
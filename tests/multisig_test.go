@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"time"
+	"token-transfer-api/graph"
+	gqlmodels "token-transfer-api/graph/models"
+	"token-transfer-api/logging"
+	"token-transfer-api/models"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testAdmin is the admin principal of the suites' resolvers.
+const testAdmin = "admin"
+
+type testSigner struct {
+	name string
+	key  ed25519.PrivateKey
+}
+
+func newTestSigner(name string) testSigner {
+	_, key, _ := ed25519.GenerateKey(nil)
+	return testSigner{name: name, key: key}
+}
+
+func (s testSigner) input() *gqlmodels.MultisigSignerInput {
+	return &gqlmodels.MultisigSignerInput{
+		Signer:    s.name,
+		PublicKey: hex.EncodeToString(s.key.Public().(ed25519.PublicKey)),
+	}
+}
+
+func (s testSigner) sign(message []byte) string {
+	return hex.EncodeToString(ed25519.Sign(s.key, message))
+}
+
+func (suite *GraphQLTestSuite) TestMultisigTransfer() {
+	treasury := "0xTEST9201"
+	toAddress := "0xTEST9202"
+	amount := 300
+
+	err := models.InitializeWallet(suite.db, treasury, 1000)
+	assert.NoError(suite.T(), err, "Failed to initialize treasury wallet")
+	err = models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+
+	alice, bob, carol := newTestSigner("alice"), newTestSigner("bob"), newTestSigner("carol")
+	_, err = suite.resolver.CreateMultisigWallet(logging.WithPrincipal(context.Background(), testAdmin), treasury,
+		[]*gqlmodels.MultisigSignerInput{alice.input(), bob.input(), carol.input()}, 2)
+	assert.NoError(suite.T(), err, "Failed to create multisig wallet")
+
+	_, err = suite.resolver.Transfer(context.Background(), treasury, toAddress, amount)
+	assert.Error(suite.T(), err, "Expected direct transfer from multisig wallet to fail")
+
+	proposal, err := suite.resolver.ProposeTransfer(context.Background(), treasury, toAddress, amount,
+		"alice", alice.sign(models.ProposalMessage(treasury, toAddress, amount)))
+	assert.NoError(suite.T(), err, "Failed to propose transfer")
+
+	id := proposal.ID.String()
+	proposal, err = suite.resolver.ApproveTransferProposal(context.Background(), id, "alice", alice.sign(models.ApprovalMessage(id)))
+	assert.NoError(suite.T(), err, "Failed to approve proposal")
+	assert.Equal(suite.T(), models.ProposalPending, proposal.Status, "Proposal executed below threshold")
+
+	_, err = suite.resolver.ApproveTransferProposal(context.Background(), id, "alice", alice.sign(models.ApprovalMessage(id)))
+	assert.Error(suite.T(), err, "Expected duplicate approval to fail")
+
+	_, err = suite.resolver.ApproveTransferProposal(context.Background(), id, "bob", carol.sign(models.ApprovalMessage(id)))
+	assert.Error(suite.T(), err, "Expected forged signature to fail")
+
+	proposal, err = suite.resolver.ApproveTransferProposal(context.Background(), id, "bob", bob.sign(models.ApprovalMessage(id)))
+	assert.NoError(suite.T(), err, "Failed to approve proposal")
+	assert.Equal(suite.T(), models.ProposalExecuted, proposal.Status, "Proposal not executed at threshold")
+
+	var receiver models.Wallet
+	suite.db.Where("address = ?", toAddress).First(&receiver)
+	assert.Equal(suite.T(), amount, receiver.Balance, "Receiver balance incorrect")
+}
+
+func (suite *GraphQLTestSuite) TestMultisigProposalExpires() {
+	treasury := "0xTEST9203"
+	toAddress := "0xTEST9204"
+
+	err := models.InitializeWallet(suite.db, treasury, 1000)
+	assert.NoError(suite.T(), err, "Failed to initialize treasury wallet")
+	err = models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+
+	alice := newTestSigner("alice")
+	_, err = suite.resolver.CreateMultisigWallet(logging.WithPrincipal(context.Background(), testAdmin), treasury,
+		[]*gqlmodels.MultisigSignerInput{alice.input()}, 1)
+	assert.NoError(suite.T(), err, "Failed to create multisig wallet")
+
+	resolver := &graph.Resolver{Store: gormstore.New(suite.db), ProposalTTL: time.Millisecond}
+	proposal, err := resolver.ProposeTransfer(context.Background(), treasury, toAddress, 1,
+		"alice", alice.sign(models.ProposalMessage(treasury, toAddress, 1)))
+	assert.NoError(suite.T(), err, "Failed to propose transfer")
+
+	time.Sleep(10 * time.Millisecond)
+	id := proposal.ID.String()
+	_, err = resolver.ApproveTransferProposal(context.Background(), id, "alice", alice.sign(models.ApprovalMessage(id)))
+	assert.Error(suite.T(), err, "Expected expired proposal to fail")
+	assert.Equal(suite.T(), "proposal expired", err.Error())
+
+	var stored models.TransferProposal
+	suite.db.Where("id = ?", id).First(&stored)
+	assert.Equal(suite.T(), models.ProposalExpired, stored.Status)
+}
+
+func (suite *GraphQLTestSuite) TestMultisigSignerChange() {
+	treasury := "0xTEST9205"
+	toAddress := "0xTEST9206"
+	admin := logging.WithPrincipal(context.Background(), testAdmin)
+
+	err := models.InitializeWallet(suite.db, treasury, 1000)
+	assert.NoError(suite.T(), err, "Failed to initialize treasury wallet")
+	err = models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+
+	alice, bob, mallory := newTestSigner("alice"), newTestSigner("bob"), newTestSigner("mallory")
+	_, err = suite.resolver.CreateMultisigWallet(context.Background(), treasury,
+		[]*gqlmodels.MultisigSignerInput{alice.input(), bob.input()}, 2)
+	assert.EqualError(suite.T(), err, "forbidden: admin principal required")
+	_, err = suite.resolver.CreateMultisigWallet(admin, treasury,
+		[]*gqlmodels.MultisigSignerInput{alice.input(), bob.input()}, 2)
+	assert.NoError(suite.T(), err, "Failed to create multisig wallet")
+
+	_, err = suite.resolver.CreateMultisigWallet(admin, treasury,
+		[]*gqlmodels.MultisigSignerInput{mallory.input()}, 1)
+	assert.ErrorIs(suite.T(), err, models.ErrMultisigExists, "Expected re-registration to fail")
+
+	proposal, err := suite.resolver.ProposeTransfer(context.Background(), treasury, toAddress, 100,
+		"alice", alice.sign(models.ProposalMessage(treasury, toAddress, 100)))
+	assert.NoError(suite.T(), err, "Failed to propose transfer")
+
+	signers := map[string]string{"alice": alice.input().PublicKey, "mallory": mallory.input().PublicKey}
+	_, err = suite.resolver.ProposeSignerChange(context.Background(), treasury,
+		[]*gqlmodels.MultisigSignerInput{alice.input(), mallory.input()}, 1,
+		"mallory", mallory.sign(models.MultisigChangeMessage(treasury, signers, 1)))
+	assert.ErrorIs(suite.T(), err, models.ErrNotSigner, "Expected a change proposed by an outsider to fail")
+
+	change, err := suite.resolver.ProposeSignerChange(context.Background(), treasury,
+		[]*gqlmodels.MultisigSignerInput{alice.input(), mallory.input()}, 1,
+		"alice", alice.sign(models.MultisigChangeMessage(treasury, signers, 1)))
+	assert.NoError(suite.T(), err, "Failed to propose signer change")
+
+	id := change.ID.String()
+	change, err = suite.resolver.ApproveSignerChange(context.Background(), id, "alice", alice.sign(models.ApprovalMessage(id)))
+	assert.NoError(suite.T(), err, "Failed to approve signer change")
+	assert.Equal(suite.T(), models.ProposalPending, change.Status, "Signer change executed below threshold")
+
+	multisig, err := suite.resolver.Store.MultisigWallet(context.Background(), treasury)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, multisig.Threshold, "Threshold changed below threshold")
+
+	change, err = suite.resolver.ApproveSignerChange(context.Background(), id, "bob", bob.sign(models.ApprovalMessage(id)))
+	assert.NoError(suite.T(), err, "Failed to approve signer change")
+	assert.Equal(suite.T(), models.ProposalExecuted, change.Status, "Signer change not executed at threshold")
+
+	multisig, err = suite.resolver.Store.MultisigWallet(context.Background(), treasury)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, multisig.Threshold)
+	names, err := suite.resolver.Store.Signers(context.Background(), treasury)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"alice", "mallory"}, names)
+
+	var stored models.TransferProposal
+	suite.db.Where("id = ?", proposal.ID).First(&stored)
+	assert.Equal(suite.T(), models.ProposalExpired, stored.Status, "Proposals approved by the old signers must expire")
+
+	_, err = suite.resolver.ProposeTransfer(context.Background(), treasury, toAddress, 100,
+		"bob", bob.sign(models.ProposalMessage(treasury, toAddress, 100)))
+	assert.ErrorIs(suite.T(), err, models.ErrNotSigner, "Expected a removed signer to fail")
+}
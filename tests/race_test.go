@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync"
 	"token-transfer-api/models"
+	"token-transfer-api/reconcile"
+	"token-transfer-api/store/gormstore"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -101,6 +103,13 @@ func (suite *GraphQLTestSuite) TestConcurrentMixedTransfers() {
 
 	assert.Equal(suite.T(), initialBalance, finalA.Balance, "Wrong final wallet A balance")
 	assert.Equal(suite.T(), initialBalance, finalB.Balance, "Wrong final wallet B balance")
+
+	report, err := reconcile.Run(context.Background(), gormstore.New(suite.db))
+	assert.NoError(suite.T(), err, "Failed to reconcile")
+	if err == nil {
+		assert.Empty(suite.T(), discrepanciesOf(report, walletA), "Wallet A should reconcile with its history")
+		assert.Empty(suite.T(), discrepanciesOf(report, walletB), "Wallet B should reconcile with its history")
+	}
 }
 
 func (suite *GraphQLTestSuite) TestConcurrentInsufficientFunds() {
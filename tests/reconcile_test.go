@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"time"
+	"token-transfer-api/graph"
+	"token-transfer-api/models"
+	"token-transfer-api/reconcile"
+	"token-transfer-api/store"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// discrepanciesOf returns the discrepancies of report about address.
+func discrepanciesOf(report *models.ReconciliationReport, address string) []models.ReconciliationDiscrepancy {
+	var found []models.ReconciliationDiscrepancy
+	for _, discrepancy := range report.Discrepancies {
+		if discrepancy.Address == address {
+			found = append(found, discrepancy)
+		}
+	}
+	return found
+}
+
+func (suite *MemStoreTestSuite) TestReconcile() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(3)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 50))
+	for _, amount := range []int{100, 200} {
+		_, err := resolver.Transfer(ctx, "0x1000", "0x1001", amount)
+		suite.Require().NoError(err)
+	}
+	_, err := resolver.Transfer(ctx, "0x1001", "0x1000", 25)
+	suite.Require().NoError(err)
+
+	report, err := reconcile.Run(ctx, suite.store)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), report.OK, "Discrepancies: %+v", report.Discrepancies)
+	assert.Equal(suite.T(), 2, report.Wallets)
+	assert.Equal(suite.T(), 10050, report.Supply)
+	assert.Equal(suite.T(), 10050, report.Minted)
+	assert.Zero(suite.T(), report.Burned)
+
+	// Tokens appearing out of nowhere break every invariant the wallet is part of.
+	wallet, err := suite.store.Wallet(ctx, "0x1001")
+	suite.Require().NoError(err)
+	wallet.Balance = -10
+	suite.Require().NoError(suite.store.SaveWallet(ctx, wallet))
+
+	broken, err := reconcile.Run(ctx, suite.store)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), broken.OK)
+	assert.Equal(suite.T(), []models.ReconciliationDiscrepancy{
+		{ReportID: broken.ID, Position: 0, Kind: models.DiscrepancySupply, Expected: 10050, Actual: 9715},
+		{ReportID: broken.ID, Position: 1, Kind: models.DiscrepancyNegativeBalance, Address: "0x1001", Actual: -10, Detail: "wallet"},
+		{ReportID: broken.ID, Position: 2, Kind: models.DiscrepancyHistory, Address: "0x1001", Expected: 325, Actual: -10},
+	}, broken.Discrepancies)
+
+	reports, err := suite.resolver.Query().ReconciliationReports(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(reports, 2)
+	assert.Equal(suite.T(), broken.ID, reports[0].ID, "The newest report should come first")
+	assert.Len(suite.T(), reports[0].Discrepancies, 3)
+	assert.True(suite.T(), reports[1].OK)
+
+	reports, err = suite.resolver.Query().ReconciliationReports(ctx, 1)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), reports, 1)
+}
+
+func (suite *MemStoreTestSuite) TestReconcileDoesNotWaitForWallets() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	// A transfer in flight has debited the sender but not yet credited the receiver.
+	locked, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- suite.store.Transaction(ctx, func(tx store.Tx) error {
+			wallet, err := tx.LockWallet(ctx, "0x1000")
+			if err != nil {
+				return err
+			}
+			wallet.Balance -= 100
+			if err := tx.SaveWallet(ctx, wallet); err != nil {
+				return err
+			}
+			close(locked)
+			<-release
+			return errors.New("rolled back")
+		})
+	}()
+	<-locked
+
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	report, err := reconcile.Run(timeout, suite.store)
+	close(release)
+	suite.Require().NoError(err, "Reconciling must not wait for wallet locks")
+	assert.True(suite.T(), report.OK, "Discrepancies: %+v", report.Discrepancies)
+	assert.Equal(suite.T(), 10000, report.Supply)
+	assert.Error(suite.T(), <-done)
+}
+
+func (suite *GraphQLTestSuite) TestReconciliationReports() {
+	ctx := context.Background()
+	fromAddress := "0xTEST9D00"
+	toAddress := "0xTEST9D01"
+	suite.Require().NoError(models.InitializeWallet(suite.db, fromAddress, 1000))
+	suite.Require().NoError(models.InitializeWallet(suite.db, toAddress, 0))
+
+	s := gormstore.New(suite.db)
+	resolver := &graph.Resolver{Store: s}
+	for _, amount := range []int{100, 250} {
+		_, err := resolver.Transfer(ctx, fromAddress, toAddress, amount)
+		suite.Require().NoError(err)
+	}
+
+	report, err := reconcile.Run(ctx, s)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), discrepanciesOf(report, fromAddress))
+	assert.Empty(suite.T(), discrepanciesOf(report, toAddress))
+
+	suite.Require().NoError(suite.db.Exec("UPDATE wallets SET balance = balance + 5 WHERE address = ?", toAddress).Error)
+	_, err = reconcile.Run(ctx, s)
+	suite.Require().NoError(err)
+
+	reports, err := resolver.Query().ReconciliationReports(ctx, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(reports, 1)
+	assert.False(suite.T(), reports[0].OK)
+	found := discrepanciesOf(reports[0], toAddress)
+	suite.Require().Len(found, 1, "Discrepancies read back should include the tampered wallet")
+	assert.Equal(suite.T(), models.DiscrepancyHistory, found[0].Kind)
+	assert.Equal(suite.T(), 350, found[0].Expected)
+	assert.Equal(suite.T(), 355, found[0].Actual)
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+	"token-transfer-api/graph"
+	"token-transfer-api/merkle"
+	"token-transfer-api/models"
+	"token-transfer-api/reserves"
+	"token-transfer-api/store"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	for count := 0; count <= 9; count++ {
+		leaves := make([]merkle.Leaf, count)
+		for i := range leaves {
+			leaves[i] = merkle.Leaf{Address: fmt.Sprintf("0x%04d", count-i), Balance: i * 100}
+		}
+		tree := merkle.Build(leaves)
+		assert.Equal(t, count, tree.Len())
+
+		for _, leaf := range leaves {
+			proof, ok := tree.Proof(leaf.Address)
+			require.True(t, ok)
+			assert.True(t, merkle.Verify(tree.Root(), proof), "Proof of %s among %d leaves should verify", leaf.Address, count)
+
+			tampered := *proof
+			tampered.Balance++
+			assert.False(t, merkle.Verify(tree.Root(), &tampered), "A changed balance should not verify")
+			if len(proof.Siblings) > 0 {
+				tampered = *proof
+				tampered.Siblings = append([]merkle.Sibling{}, proof.Siblings...)
+				tampered.Siblings[0].Left = !tampered.Siblings[0].Left
+				assert.False(t, merkle.Verify(tree.Root(), &tampered), "A reordered path should not verify")
+			}
+		}
+		_, ok := tree.Proof("0xMISSING")
+		assert.False(t, ok)
+	}
+
+	a := merkle.Build([]merkle.Leaf{{Address: "0x1", Balance: 1}, {Address: "0x2", Balance: 2}, {Address: "0x3", Balance: 3}})
+	b := merkle.Build([]merkle.Leaf{{Address: "0x3", Balance: 3}, {Address: "0x1", Balance: 1}, {Address: "0x2", Balance: 2}})
+	assert.Equal(t, a.Root(), b.Root(), "The root should not depend on the order of the leaves")
+
+	// A node of the tree cannot pass for a leaf.
+	proof, _ := a.Proof("0x3")
+	assert.False(t, merkle.Verify(proof.Siblings[0].Hash, &merkle.Proof{Address: "0x1", Balance: 1}))
+}
+
+func (suite *MemStoreTestSuite) TestReservesCommitAndProve() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1002", 50))
+
+	first, err := reserves.Commit(ctx, suite.store)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(first)
+	assert.Equal(suite.T(), 3, first.Wallets)
+	assert.Equal(suite.T(), 10050, first.Total)
+
+	again, err := reserves.Commit(ctx, suite.store)
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), again, "No root should be committed without balance changes")
+
+	_, err = suite.resolver.Transfer(ctx, "0x1000", "0x1001", 300)
+	suite.Require().NoError(err)
+	second, err := reserves.Commit(ctx, suite.store)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(second)
+	assert.Greater(suite.T(), second.Seq, first.Seq)
+	assert.NotEqual(suite.T(), first.Root, second.Root)
+
+	root, proof, err := reserves.Prove(ctx, suite.store, "0x1001", "")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), second.Seq, root.Seq, "The latest root should be used by default")
+	assert.Equal(suite.T(), 300, proof.Balance)
+	assert.True(suite.T(), merkle.Verify(second.Root, proof))
+
+	root, proof, err = reserves.Prove(ctx, suite.store, "0x1001", first.Root)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), first.Seq, root.Seq)
+	assert.Equal(suite.T(), 0, proof.Balance, "Proofs against an earlier root should hold the balance at the time")
+	assert.True(suite.T(), merkle.Verify(first.Root, proof))
+
+	_, _, err = reserves.Prove(ctx, suite.store, "0x9999", "")
+	assert.ErrorIs(suite.T(), err, reserves.ErrNotIncluded)
+	_, _, err = reserves.Prove(ctx, suite.store, "0x1001", "feed")
+	assert.ErrorIs(suite.T(), err, models.ErrBalanceRootNotFound)
+}
+
+func (suite *MemStoreTestSuite) TestReservesHotWallets() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(4)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	_, err := resolver.Transfer(ctx, "0x1000", "0x1001", 700)
+	suite.Require().NoError(err)
+
+	root, err := reserves.Commit(ctx, suite.store)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(root)
+	assert.Equal(suite.T(), 10000, root.Total)
+
+	_, proof, err := reserves.Prove(ctx, suite.store, "0x1000", root.Root)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 9300, proof.Balance, "The balance of a hot wallet should include its shards")
+}
+
+func (suite *MemStoreTestSuite) TestReservesCommitDoesNotWaitForLocks() {
+	ctx := context.Background()
+	locked, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- suite.store.Transaction(ctx, func(tx store.Tx) error {
+			wallet, err := tx.LockWallet(ctx, "0x1000")
+			if err != nil {
+				return err
+			}
+			wallet.Balance -= 100
+			if err := tx.SaveWallet(ctx, wallet); err != nil {
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	root, err := reserves.Commit(timeout, suite.store)
+	close(release)
+	suite.Require().NoError(err, "Committing a root must not wait for wallet locks")
+	suite.Require().NotNil(root)
+	assert.Equal(suite.T(), 10000, root.Total, "Uncommitted writes must not be counted")
+	suite.Require().NoError(<-done)
+}
+
+func (suite *GraphQLTestSuite) TestBalanceProof() {
+	ctx := context.Background()
+	address := "0xTEST9B00"
+	suite.Require().NoError(models.InitializeWallet(suite.db, address, 420))
+
+	s := gormstore.New(suite.db)
+	resolver := &graph.Resolver{Store: s}
+	query := resolver.Query()
+
+	_, err := query.BalanceProof(ctx, address, nil)
+	assert.ErrorIs(suite.T(), err, models.ErrBalanceRootNotFound)
+
+	committed, err := reserves.Commit(ctx, s)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(committed)
+
+	latest, err := query.BalanceRoot(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(latest)
+	assert.Equal(suite.T(), committed.Root, latest.Root)
+
+	result, err := query.BalanceProof(ctx, address, &committed.Root)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 420, result.Balance)
+	assert.Equal(suite.T(), int(committed.Seq), result.Seq)
+
+	// The response of the query is what wallet owners verify offline.
+	data, err := json.Marshal(result)
+	suite.Require().NoError(err)
+	var proof merkle.Proof
+	suite.Require().NoError(json.Unmarshal(data, &proof))
+	assert.True(suite.T(), merkle.Verify(result.Root, &proof))
+}
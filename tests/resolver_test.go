@@ -2,16 +2,16 @@ package tests
 
 import (
 	"context"
-	"fmt"
-	"os"
 	"testing"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
 	"token-transfer-api/graph"
 	"token-transfer-api/models"
+	"token-transfer-api/store/gormstore"
 
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -27,23 +27,18 @@ func (suite *GraphQLTestSuite) SetupSuite() {
 		suite.T().Log("Warning: .env file not found")
 	}
 
-	user := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	dbname := os.Getenv("POSTGRES_DB")
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
+	cfg, _, err := config.Load(nil)
+	assert.NoError(suite.T(), err, "Failed to load the configuration")
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", host, user, password, dbname, port)
-
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	database, err := db.Connect(cfg.Database)
 	assert.NoError(suite.T(), err, "Failed to connect to the database")
 
-	err = database.AutoMigrate(&models.Wallet{})
-	assert.NoError(suite.T(), err, "Failed to auto-migrate")
+	err = db.Migrate(database)
+	assert.NoError(suite.T(), err, "Failed to migrate")
 
 	suite.db = database
 
-	suite.resolver = &graph.Resolver{DB: database}
+	suite.resolver = &graph.Resolver{Store: gormstore.New(database), Admins: []string{testAdmin}}
 }
 
 // func (suite *GraphQLTestSuite) TearDownSuite() {
@@ -60,6 +55,26 @@ func (suite *GraphQLTestSuite) SetupTest() {
 // using that instead of TearDownSuite() because incorrect receiver address test is causing runtime error otherwise
 func (suite *GraphQLTestSuite) TearDownTest() {
 	suite.db.Exec("DELETE FROM wallets WHERE address LIKE '0xTEST%' OR address LIKE '0x1000'")
+	suite.db.Exec("DELETE FROM wallet_shards WHERE address LIKE '0xTEST%'")
+	suite.db.Exec("DELETE FROM wallet_events WHERE address LIKE '0xTEST%' OR address LIKE '0x1000'")
+	suite.db.Exec("DELETE FROM wallet_snapshots WHERE address LIKE '0xTEST%' OR address LIKE '0x1000'")
+	suite.db.Exec("DELETE FROM audit_records WHERE address LIKE '0xTEST%' OR address LIKE '0x1000'")
+	suite.db.Exec("DELETE FROM audit_checkpoint_entries")
+	suite.db.Exec("DELETE FROM audit_checkpoints")
+	suite.db.Exec("DELETE FROM balance_leaves")
+	suite.db.Exec("DELETE FROM balance_roots")
+	suite.db.Exec("DELETE FROM blocks")
+	suite.db.Exec("DELETE FROM reconciliation_discrepancies")
+	suite.db.Exec("DELETE FROM reconciliation_reports")
+	suite.db.Exec("DELETE FROM wallet_limits WHERE address LIKE '0xTEST%' OR address LIKE '0x1000'")
+	suite.db.Exec("DELETE FROM spend_counters WHERE address LIKE '0xTEST%' OR address LIKE '0x1000'")
+	suite.db.Exec("DELETE FROM transfers WHERE from_address LIKE '0xTEST%' OR from_address LIKE '0x1000'")
+	suite.db.Exec("DELETE FROM transfer_reviews WHERE from_address LIKE '0xTEST%' OR from_address LIKE '0x1000'")
+	suite.db.Exec("DELETE FROM proposal_approvals WHERE proposal_id IN (SELECT id FROM transfer_proposals WHERE from_address LIKE '0xTEST%')")
+	suite.db.Exec("DELETE FROM transfer_proposals WHERE from_address LIKE '0xTEST%'")
+	suite.db.Exec("DELETE FROM multisig_signers WHERE address LIKE '0xTEST%'")
+	suite.db.Exec("DELETE FROM multisig_wallets WHERE address LIKE '0xTEST%'")
+	suite.db.Exec("DELETE FROM vesting_tranches WHERE address LIKE '0xTEST%'")
 }
 
 func (suite *GraphQLTestSuite) TestTransferSuccessful() {
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"token-transfer-api/graph"
+	"token-transfer-api/metrics"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+	"token-transfer-api/store/gormstore"
+	"token-transfer-api/store/memstore"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	serializationFailure = &pgconn.PgError{Code: "40001", Message: "could not serialize access due to concurrent update"}
+	deadlockDetected     = &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+)
+
+// flakyStore fails its first failures transactions with err after running fn, the way a
+// database aborts a transaction at commit.
+type flakyStore struct {
+	store.Store
+	failures int
+	err      error
+	calls    int
+}
+
+func (s *flakyStore) Transaction(ctx context.Context, fn func(tx store.Tx) error) error {
+	s.calls++
+	if s.failures == 0 {
+		return s.Store.Transaction(ctx, fn)
+	}
+	s.failures--
+	return s.Store.Transaction(ctx, func(tx store.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return s.err
+	})
+}
+
+func newFlakyStore(t *testing.T, failures int, err error) *flakyStore {
+	base := memstore.New()
+	require.NoError(t, base.CreateWallet(context.Background(), "0x1000", 100))
+	require.NoError(t, base.CreateWallet(context.Background(), "0x1001", 0))
+	return &flakyStore{Store: base, failures: failures, err: err}
+}
+
+var testRetryPolicy = store.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond}
+
+func TestRetryableErrors(t *testing.T) {
+	assert.Equal(t, "serialization_failure", gormstore.Retryable(serializationFailure))
+	assert.Equal(t, "deadlock", gormstore.Retryable(fmt.Errorf("error locking wallet: %w", deadlockDetected)))
+	assert.Empty(t, gormstore.Retryable(&pgconn.PgError{Code: "23505"}), "Unique violations are not transient")
+	assert.Empty(t, gormstore.Retryable(models.ErrWalletNotFound))
+}
+
+func TestTransferRetriesSerializationFailure(t *testing.T) {
+	flaky := newFlakyStore(t, 2, serializationFailure)
+	resolver := &graph.Resolver{Store: store.WithRetry(flaky, testRetryPolicy, gormstore.Retryable)}
+	retries := metrics.TransactionRetries.WithLabelValues("serialization_failure")
+	before := testutil.ToFloat64(retries)
+
+	wallet, err := resolver.Transfer(context.Background(), "0x1000", "0x1001", 10)
+	require.NoError(t, err, "Transfer should succeed once the conflict is gone")
+	assert.Equal(t, 90, wallet.Balance)
+	assert.Equal(t, 3, flaky.calls, "Transaction should have run three times")
+	assert.Equal(t, before+2, testutil.ToFloat64(retries), "Retries not counted")
+
+	receiver, err := flaky.Wallet(context.Background(), "0x1001")
+	require.NoError(t, err)
+	assert.Equal(t, 10, receiver.Balance, "Aborted attempts must not be applied")
+}
+
+func TestTransferRetriesExhausted(t *testing.T) {
+	flaky := newFlakyStore(t, 5, deadlockDetected)
+	resolver := &graph.Resolver{Store: store.WithRetry(flaky, testRetryPolicy, gormstore.Retryable)}
+	exhausted := metrics.TransactionRetriesExhausted.WithLabelValues("deadlock")
+	before := testutil.ToFloat64(exhausted)
+
+	_, err := resolver.Transfer(context.Background(), "0x1000", "0x1001", 10)
+	assert.ErrorIs(t, err, deadlockDetected, "The last error should be returned")
+	assert.Equal(t, testRetryPolicy.MaxAttempts, flaky.calls)
+	assert.Equal(t, before+1, testutil.ToFloat64(exhausted), "Exhausted retries not counted")
+}
+
+func TestTransferRetryBudget(t *testing.T) {
+	flaky := newFlakyStore(t, 1, serializationFailure)
+	policy := store.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour, Budget: time.Millisecond}
+	resolver := &graph.Resolver{Store: store.WithRetry(flaky, policy, gormstore.Retryable)}
+
+	_, err := resolver.Transfer(context.Background(), "0x1000", "0x1001", 10)
+	assert.ErrorIs(t, err, serializationFailure)
+	assert.Equal(t, 1, flaky.calls, "No retry should start past the budget")
+}
+
+func TestTransferDoesNotRetryOtherErrors(t *testing.T) {
+	flaky := newFlakyStore(t, 1, errors.New("connection reset"))
+	resolver := &graph.Resolver{Store: store.WithRetry(flaky, testRetryPolicy, gormstore.Retryable)}
+
+	_, err := resolver.Transfer(context.Background(), "0x1000", "0x1001", 10)
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, 1, flaky.calls, "Only transient errors may be retried")
+}
+
+func (suite *GraphQLTestSuite) TestConcurrentTransfersSerializable() {
+	fromAddress := "0xTEST9500"
+	toAddress := "0xTEST9501"
+	num := 20
+
+	suite.Require().NoError(models.InitializeWallet(suite.db, fromAddress, 1000))
+	suite.Require().NoError(models.InitializeWallet(suite.db, toAddress, 0))
+
+	base := gormstore.New(suite.db)
+	base.Isolation = sql.LevelSerializable
+	resolver := &graph.Resolver{Store: store.WithRetry(base, store.RetryPolicy{
+		MaxAttempts: 20,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    50 * time.Millisecond,
+	}, gormstore.Retryable)}
+
+	var wg sync.WaitGroup
+	results := make(chan error, num)
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := resolver.Transfer(context.Background(), fromAddress, toAddress, 1)
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	for err := range results {
+		assert.NoError(suite.T(), err, "Transfer failed")
+	}
+
+	var receiver models.Wallet
+	suite.db.Where("address = ?", toAddress).First(&receiver)
+	assert.Equal(suite.T(), num, receiver.Balance, "Wrong final receiver balance")
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"token-transfer-api/graph"
+	"token-transfer-api/logging"
+	"token-transfer-api/models"
+	"token-transfer-api/rules"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeHistory struct {
+	recipients int
+	known      bool
+}
+
+func (h fakeHistory) RecipientsSince(address string, since time.Time) (int, error) {
+	return h.recipients, nil
+}
+
+func (h fakeHistory) HasTransferred(fromAddress string, toAddress string) (bool, error) {
+	return h.known, nil
+}
+
+func TestRulesEngineStrictestDecision(t *testing.T) {
+	engine := rules.NewEngine(
+		&rules.AmountThreshold{Amount: 100, Decision: rules.Review},
+		&rules.Blocklist{Addresses: map[string]bool{"0xbad": true}, Decision: rules.Deny},
+	)
+
+	result, err := engine.Evaluate(rules.Transfer{FromAddress: "0x1", ToAddress: "0x2", Amount: 10}, fakeHistory{})
+	assert.NoError(t, err)
+	assert.Equal(t, rules.Allow, result.Decision)
+	assert.Empty(t, result.Reasons)
+
+	result, err = engine.Evaluate(rules.Transfer{FromAddress: "0x1", ToAddress: "0xbad", Amount: 500}, fakeHistory{})
+	assert.NoError(t, err)
+	assert.Equal(t, rules.Deny, result.Decision)
+	assert.Len(t, result.Reasons, 2)
+}
+
+func TestRulesFanOutAndFirstTimeCounterparty(t *testing.T) {
+	fanOut := &rules.FanOut{MaxRecipients: 3, Window: time.Hour, Decision: rules.Review}
+	transfer := rules.Transfer{FromAddress: "0x1", ToAddress: "0x2", Amount: 10, At: time.Now()}
+
+	decision, _, err := fanOut.Evaluate(transfer, fakeHistory{recipients: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, rules.Allow, decision)
+	decision, _, err = fanOut.Evaluate(transfer, fakeHistory{recipients: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, rules.Review, decision)
+	decision, _, err = fanOut.Evaluate(transfer, fakeHistory{recipients: 3, known: true})
+	assert.NoError(t, err)
+	assert.Equal(t, rules.Allow, decision)
+
+	firstTime := &rules.FirstTimeCounterparty{MinAmount: 5, Decision: rules.Review}
+	decision, _, err = firstTime.Evaluate(transfer, fakeHistory{})
+	assert.NoError(t, err)
+	assert.Equal(t, rules.Review, decision)
+	decision, _, err = firstTime.Evaluate(transfer, fakeHistory{known: true})
+	assert.NoError(t, err)
+	assert.Equal(t, rules.Allow, decision)
+}
+
+func TestRulesLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(path, []byte(`{"rules": [
+		{"type": "blocklist", "addresses": ["0xbad"], "decision": "deny"},
+		{"type": "fan_out", "maxRecipients": 5, "window": "1h", "decision": "review"}
+	]}`), 0o600)
+	assert.NoError(t, err)
+
+	engine, err := rules.LoadFile(path)
+	assert.NoError(t, err, "Failed to load rules file")
+	assert.Len(t, engine.Rules(), 2)
+
+	err = os.WriteFile(path, []byte(`{"rules": [{"type": "unknown", "decision": "deny"}]}`), 0o600)
+	assert.NoError(t, err)
+	_, err = rules.LoadFile(path)
+	assert.Error(t, err, "Expected error for unknown rule type")
+}
+
+func (suite *GraphQLTestSuite) TestTransferReviewQueue() {
+	fromAddress := "0x1000"
+	toAddress := "0xTEST9101"
+
+	err := models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+
+	resolver := &graph.Resolver{
+		Store:  gormstore.New(suite.db),
+		Rules:  rules.NewEngine(&rules.AmountThreshold{Amount: 500, Decision: rules.Review}),
+		Admins: []string{"compliance"},
+	}
+	admin := logging.WithPrincipal(context.Background(), "compliance")
+
+	_, err = resolver.Transfer(context.Background(), fromAddress, toAddress, 600)
+	assert.Error(suite.T(), err, "Expected transfer to be held for review")
+	assert.Contains(suite.T(), err.Error(), "transfer requires review")
+
+	var review models.TransferReview
+	err = suite.db.Where("from_address = ? AND to_address = ?", fromAddress, toAddress).First(&review).Error
+	assert.NoError(suite.T(), err, "Review was not queued")
+	assert.Equal(suite.T(), models.ReviewPending, review.Status)
+
+	var receiver models.Wallet
+	suite.db.Where("address = ?", toAddress).First(&receiver)
+	assert.Equal(suite.T(), 0, receiver.Balance, "Flagged transfer must not move funds")
+
+	approved, err := resolver.ApproveTransferReview(admin, review.ID.String())
+	assert.NoError(suite.T(), err, "Failed to approve review")
+	assert.Equal(suite.T(), models.ReviewApproved, approved.Status)
+
+	suite.db.Where("address = ?", toAddress).First(&receiver)
+	assert.Equal(suite.T(), 600, receiver.Balance, "Approved transfer was not executed")
+
+	_, err = resolver.RejectTransferReview(admin, review.ID.String(), nil)
+	assert.Error(suite.T(), err, "Expected error for already decided review")
+}
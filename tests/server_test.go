@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+	"token-transfer-api/config"
+	"token-transfer-api/server"
+	"token-transfer-api/worker"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestServerDrainsInFlightRequests(t *testing.T) {
+	cfg := config.Default().Server
+	cfg.Addr = freeAddr(t)
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	httpServer := server.New(cfg, mux)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- httpServer.Run(ctx) }()
+
+	var body []byte
+	var status int
+	requestDone := make(chan struct{})
+	go func() {
+		defer close(requestDone)
+		var resp *http.Response
+		var err error
+		for i := 0; i < 50; i++ {
+			if resp, err = http.Get("http://" + cfg.Addr + "/slow"); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if assert.NoError(t, err, "Request failed") {
+			defer resp.Body.Close()
+			status = resp.StatusCode
+			body, _ = io.ReadAll(resp.Body)
+		}
+	}()
+
+	<-started
+	assert.False(t, httpServer.Draining(), "Server should not drain before shutdown")
+	cancel()
+
+	<-requestDone
+	assert.Equal(t, http.StatusOK, status, "In-flight request was not allowed to finish")
+	assert.Equal(t, "done", string(body))
+	assert.NoError(t, <-stopped, "Server did not shut down cleanly")
+	assert.True(t, httpServer.Draining(), "Server should report draining after shutdown")
+}
+
+func TestWorkerGroupStopWaitsForRunningWork(t *testing.T) {
+	workers := worker.NewGroup()
+	var runs, finished atomic.Int32
+	workers.Every("test", 5*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		finished.Add(1)
+		return nil
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	err := workers.Stop(context.Background())
+	assert.NoError(t, err, "Workers did not stop")
+	assert.Positive(t, runs.Load(), "Worker never ran")
+	assert.Equal(t, runs.Load(), finished.Load(), "Stop returned while work was running")
+}
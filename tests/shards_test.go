@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"token-transfer-api/graph"
+	gqlmodels "token-transfer-api/graph/models"
+	"token-transfer-api/logging"
+	"token-transfer-api/models"
+	"token-transfer-api/store"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// shardBalances returns the balances of the shards of a wallet in index order.
+func shardBalances(ctx context.Context, tx store.Tx, address string) ([]int, error) {
+	shards, err := tx.WalletShards(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]int, len(shards))
+	for i, shard := range shards {
+		balances[i] = shard.Balance
+	}
+	return balances, nil
+}
+
+func (suite *MemStoreTestSuite) hotResolver(shards int) *graph.Resolver {
+	resolver := &graph.Resolver{Store: suite.store, HotWallets: []string{"0x1000"}, HotWalletShards: shards}
+	suite.Require().NoError(resolver.RebalanceHotWallets(context.Background()))
+	return resolver
+}
+
+func (suite *MemStoreTestSuite) TestHotWalletSharding() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(3)
+
+	balances, err := shardBalances(ctx, suite.store, "0x1000")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []int{3334, 3333, 3333}, balances, "Balance should be split evenly")
+	assert.Equal(suite.T(), 0, suite.balance("0x1000"), "The wallet row should be emptied")
+
+	wallet, err := resolver.Query().Wallet(ctx, "0x1000", nil)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 10000, wallet.Balance, "Reads should sum the shards")
+
+	// Moving the shards back when the wallet is no longer hot.
+	suite.Require().NoError((&graph.Resolver{Store: suite.store}).RebalanceHotWallets(ctx))
+	balances, err = shardBalances(ctx, suite.store, "0x1000")
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), balances)
+	assert.Equal(suite.T(), 10000, suite.balance("0x1000"))
+}
+
+func (suite *MemStoreTestSuite) TestHotWalletsCannotHaveLimits() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+	limited := &graph.Resolver{
+		Store:            suite.store,
+		UnverifiedLimits: &models.WalletLimits{MaxTransfersPerWindow: 2, WindowMinutes: 60},
+		HotWallets:       []string{"0x1000"},
+		HotWalletShards:  3,
+	}
+	assert.EqualError(suite.T(), limited.RebalanceHotWallets(ctx), "hot wallets cannot have spending limits: 0x1000",
+		"Unverified wallets should not be sharded when they are limited")
+	balances, err := shardBalances(ctx, suite.store, "0x1000")
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), balances)
+
+	// Limits that come to apply after sharding fail transfers rather than go unenforced.
+	resolver := suite.hotResolver(3)
+	resolver.UnverifiedLimits = limited.UnverifiedLimits
+	_, err = resolver.Transfer(ctx, "0x1000", "0x1001", 1)
+	assert.EqualError(suite.T(), err, "hot wallets cannot have spending limits: 0x1000")
+
+	resolver.Admins = []string{testAdmin}
+	_, err = resolver.SetWalletLimits(logging.WithPrincipal(ctx, testAdmin), "0x1000", gqlmodels.WalletLimitsInput{})
+	assert.EqualError(suite.T(), err, "hot wallets cannot have spending limits")
+}
+
+func (suite *MemStoreTestSuite) TestHotWalletPayouts() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(4)
+
+	receivers := 20
+	for i := 0; i < receivers; i++ {
+		suite.Require().NoError(suite.store.CreateWallet(ctx, fmt.Sprintf("0x2%03d", i), 0))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, receivers*10)
+	for i := 0; i < receivers*10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := resolver.Transfer(ctx, "0x1000", fmt.Sprintf("0x2%03d", i%receivers), 5)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(suite.T(), err)
+	}
+	for i := 0; i < receivers; i++ {
+		assert.Equal(suite.T(), 50, suite.balance(fmt.Sprintf("0x2%03d", i)))
+	}
+	wallet, err := resolver.Query().Wallet(ctx, "0x1000", nil)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 9000, wallet.Balance)
+
+	suite.Require().NoError(resolver.RebalanceHotWallets(ctx))
+	balances, err := shardBalances(ctx, suite.store, "0x1000")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []int{2250, 2250, 2250, 2250}, balances, "Rebalancing should even out the shards")
+}
+
+func (suite *MemStoreTestSuite) TestHotWalletPayoutDoesNotLockWallet() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(2)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- suite.store.Transaction(ctx, func(tx store.Tx) error {
+			if _, err := tx.LockWallet(ctx, "0x1000"); err != nil {
+				return err
+			}
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	wallet, err := resolver.Transfer(timeout, "0x1000", "0x1001", 10)
+	suite.Require().NoError(err, "A payout must not wait for the wallet row")
+	assert.Equal(suite.T(), 9990, wallet.Balance)
+
+	close(release)
+	suite.Require().NoError(<-done)
+}
+
+func (suite *MemStoreTestSuite) TestHotWalletPayoutAcrossShards() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(4)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 0))
+
+	wallet, err := resolver.Transfer(ctx, "0x1000", "0x1001", 6000)
+	suite.Require().NoError(err, "A payout larger than any shard should drain several")
+	assert.Equal(suite.T(), 4000, wallet.Balance)
+	assert.Equal(suite.T(), 6000, suite.balance("0x1001"))
+
+	_, err = resolver.Transfer(ctx, "0x1000", "0x1001", 4001)
+	assert.EqualError(suite.T(), err, "insufficient balance")
+}
+
+func (suite *MemStoreTestSuite) TestHotWalletReceives() {
+	ctx := context.Background()
+	resolver := suite.hotResolver(2)
+	suite.Require().NoError(suite.store.CreateWallet(ctx, "0x1001", 100))
+
+	_, err := resolver.Transfer(ctx, "0x1001", "0x1000", 100)
+	suite.Require().NoError(err)
+
+	wallet, err := resolver.Query().Wallet(ctx, "0x1000", nil)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 10100, wallet.Balance)
+	assert.Equal(suite.T(), 0, suite.balance("0x1001"))
+}
+
+func (suite *GraphQLTestSuite) TestConcurrentHotWalletPayouts() {
+	ctx := context.Background()
+	hotAddress := "0xTEST9600"
+	receivers := 50
+	num := 500
+
+	suite.Require().NoError(models.InitializeWallet(suite.db, hotAddress, 10000))
+	for i := 0; i < receivers; i++ {
+		suite.Require().NoError(models.InitializeWallet(suite.db, fmt.Sprintf("0xTEST96%02d", i+1), 0))
+	}
+
+	resolver := &graph.Resolver{Store: gormstore.New(suite.db), HotWallets: []string{hotAddress}, HotWalletShards: 8}
+	suite.Require().NoError(resolver.RebalanceHotWallets(ctx))
+
+	// Unlike TestConcurrentHighVolume, all payouts start at once.
+	start := make(chan struct{})
+	results := make(chan error, num)
+	var wg sync.WaitGroup
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := resolver.Transfer(ctx, hotAddress, fmt.Sprintf("0xTEST96%02d", i%receivers+1), 1)
+			results <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+
+	for err := range results {
+		assert.NoError(suite.T(), err)
+	}
+
+	wallet, err := resolver.Query().Wallet(ctx, hotAddress, nil)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 10000-num, wallet.Balance)
+
+	var received int
+	suite.db.Model(&models.Wallet{}).Where("address LIKE ? AND address <> ?", "0xTEST96%", hotAddress).
+		Select("SUM(balance)").Scan(&received)
+	assert.Equal(suite.T(), num, received)
+}
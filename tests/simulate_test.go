@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"context"
+	"token-transfer-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *GraphQLTestSuite) TestSimulateTransferSuccessful() {
+	fromAddress := "0x1000"
+	toAddress := "0xTEST8001"
+	amount := 100
+
+	err := models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+
+	simulation, err := suite.resolver.SimulateTransfer(context.Background(), fromAddress, toAddress, amount)
+	assert.NoError(suite.T(), err, "Failed to simulate transfer")
+	assert.True(suite.T(), simulation.Ok, "Simulation should succeed")
+	assert.Empty(suite.T(), simulation.Violations, "Unexpected violations")
+	assert.Equal(suite.T(), 10000-amount, *simulation.FromBalance, "Projected sender balance incorrect")
+	assert.Equal(suite.T(), amount, *simulation.ToBalance, "Projected receiver balance incorrect")
+
+	var senderWallet, receiverWallet models.Wallet
+	suite.db.Where("address = ?", fromAddress).First(&senderWallet)
+	suite.db.Where("address = ?", toAddress).First(&receiverWallet)
+	assert.Equal(suite.T(), 10000, senderWallet.Balance, "Simulation changed sender balance")
+	assert.Equal(suite.T(), 0, receiverWallet.Balance, "Simulation changed receiver balance")
+}
+
+func (suite *GraphQLTestSuite) TestSimulateTransferViolations() {
+	fromAddress := "0x1000"
+	toAddress := "0xTEST8002"
+
+	err := models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+
+	cases := []struct {
+		from      string
+		to        string
+		amount    int
+		violation string
+	}{
+		{fromAddress, toAddress, 0, "amount must be positive"},
+		{fromAddress, fromAddress, 1, "cannot transfer to self"},
+		{"0xTEST8009", toAddress, 1, "sender wallet not found"},
+		{fromAddress, "0xTEST8009", 1, "receiver wallet not found"},
+		{fromAddress, toAddress, 2000000, "insufficient balance"},
+	}
+
+	for _, c := range cases {
+		simulation, err := suite.resolver.SimulateTransfer(context.Background(), c.from, c.to, c.amount)
+		assert.NoError(suite.T(), err, "Simulation should report violations, not errors")
+		assert.False(suite.T(), simulation.Ok, "Simulation should fail")
+		assert.Equal(suite.T(), []string{c.violation}, simulation.Violations, "Incorrect violation")
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+	"token-transfer-api/audit"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
+	"token-transfer-api/db/migrations"
+	"token-transfer-api/graph"
+	"token-transfer-api/models"
+	"token-transfer-api/store/gormstore"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// SQLiteTestSuite runs every GraphQLTestSuite test, including the concurrency tests,
+// against a SQLite database file.
+type SQLiteTestSuite struct {
+	GraphQLTestSuite
+}
+
+func (suite *SQLiteTestSuite) SetupSuite() {
+	cfg := config.Default()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.SQLitePath = filepath.Join(suite.T().TempDir(), "test.db")
+	// The concurrency tests queue hundreds of transactions for the write lock, which
+	// takes longer than the default under the race detector.
+	cfg.Database.SQLiteBusyTimeout = time.Minute
+
+	database, err := db.Connect(cfg.Database)
+	assert.NoError(suite.T(), err, "Failed to connect to the database")
+
+	err = db.Migrate(database)
+	assert.NoError(suite.T(), err, "Failed to migrate")
+
+	suite.db = database
+
+	suite.resolver = &graph.Resolver{Store: gormstore.New(database), Admins: []string{testAdmin}}
+}
+
+func (suite *SQLiteTestSuite) TestMigrationsRoundTrip() {
+	migrator, err := migrations.New(suite.db)
+	assert.NoError(suite.T(), err, "Failed to load migrations")
+
+	reverted, err := migrator.Down(context.Background(), -1)
+	assert.NoError(suite.T(), err, "Failed to revert migrations")
+	applied, err := migrator.Up(context.Background())
+	assert.NoError(suite.T(), err, "Failed to reapply migrations")
+	assert.Equal(suite.T(), len(reverted), len(applied))
+
+	pending, err := migrator.Pending(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), pending)
+}
+
+func (suite *SQLiteTestSuite) TestMigrationsBackfillWalletEvents() {
+	ctx := context.Background()
+	migrator, err := migrations.New(suite.db)
+	suite.Require().NoError(err)
+
+	reverted, err := migrator.Down(ctx, 7)
+	suite.Require().NoError(err)
+	suite.Require().Equal("create_wallet_events", reverted[6].Name)
+	suite.Require().NoError(suite.db.Exec("INSERT INTO wallets (id, address, balance, verified) VALUES (?, ?, ?, ?)",
+		uuid.NewString(), "0xTEST9900", 250, true).Error)
+	_, err = migrator.Up(ctx)
+	suite.Require().NoError(err)
+
+	events, err := gormstore.New(suite.db).WalletEvents(ctx, "0xTEST9900", 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(events, 2)
+	assert.Equal(suite.T(), models.EventWalletCreated, events[0].Type)
+	assert.Equal(suite.T(), 250, events[0].Amount)
+	assert.Equal(suite.T(), models.EventVerified, events[1].Type)
+	assert.WithinDuration(suite.T(), time.Now(), events[0].CreatedAt, time.Minute)
+}
+
+func (suite *SQLiteTestSuite) TestMigrationsVerifyExistingWallets() {
+	ctx := context.Background()
+	migrator, err := migrations.New(suite.db)
+	suite.Require().NoError(err)
+
+	reverted, err := migrator.Down(ctx, 12)
+	suite.Require().NoError(err)
+	suite.Require().Equal("create_wallet_limits", reverted[11].Name)
+	suite.Require().NoError(suite.db.Exec("INSERT INTO wallets (id, address, balance) VALUES (?, ?, ?)",
+		uuid.NewString(), "0xTEST9B00", 250).Error)
+	_, err = migrator.Up(ctx)
+	suite.Require().NoError(err)
+
+	s := gormstore.New(suite.db)
+	wallet, err := s.Wallet(ctx, "0xTEST9B00")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), wallet.Verified, "Wallets that predate the limits should not become limited")
+
+	suite.Require().NoError(s.CreateWallet(ctx, "0xTEST9B01", 0))
+	wallet, err = s.Wallet(ctx, "0xTEST9B01")
+	suite.Require().NoError(err)
+	assert.False(suite.T(), wallet.Verified, "New wallets should start unverified")
+}
+
+func TestSQLiteSuite(t *testing.T) {
+	suite.Run(t, new(SQLiteTestSuite))
+}
+
+func (suite *SQLiteTestSuite) TestAuditOpensExistingWallets() {
+	ctx := context.Background()
+	s := gormstore.New(suite.db)
+	suite.Require().NoError(suite.db.Exec("INSERT INTO wallets (id, address, balance, verified) VALUES (?, ?, ?, ?)",
+		uuid.NewString(), "0xTEST9A10", 250, false).Error)
+
+	suite.Require().NoError(audit.Open(ctx, s))
+	records, err := s.AuditRecords(ctx, "0xTEST9A10", 0, 10)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), records, "Wallets should not be adopted once the audit log has records")
+
+	suite.Require().NoError(suite.db.Exec("DELETE FROM audit_records").Error)
+	suite.Require().NoError(audit.Open(ctx, s))
+	records, err = s.AuditRecords(ctx, "0xTEST9A10", 0, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 1)
+	assert.Equal(suite.T(), models.AuditOpen, records[0].Operation)
+	assert.Equal(suite.T(), 250, records[0].Balance)
+
+	report, err := audit.Verify(ctx, s, nil)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), report.Problems)
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"token-transfer-api/graph"
+	"token-transfer-api/graph/generated"
+	"token-transfer-api/models"
+	"token-transfer-api/tracing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// recordSpans installs an in-memory tracer provider for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+// spanNamed returns the first recorded span called name.
+func spanNamed(spans tracetest.SpanStubs, name string) (tracetest.SpanStub, bool) {
+	for _, span := range spans {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+func TestGraphQLTracing(t *testing.T) {
+	exporter := recordSpans(t)
+
+	srv := handler.New(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{}}))
+	srv.AddTransport(transport.POST{})
+	srv.Use(tracing.GraphQL{})
+
+	body := `{"query": "query Simulate { simulateTransfer(fromAddress: \"0xA\", toAddress: \"0xB\", amount: 0) { ok violations } }"}`
+	request := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	recorder := httptest.NewRecorder()
+	tracing.Middleware(srv).ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	spans := exporter.GetSpans()
+	server, ok := spanNamed(spans, "POST /query")
+	require.True(t, ok, "Expected a server span")
+	operation, ok := spanNamed(spans, "graphql.query Simulate")
+	require.True(t, ok, "Expected an operation span")
+	field, ok := spanNamed(spans, "graphql.field Query.simulateTransfer")
+	require.True(t, ok, "Expected a field span")
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String(),
+		"Incoming traceparent was not continued")
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.Equal(t, server.SpanContext.SpanID(), operation.Parent.SpanID())
+	assert.Equal(t, operation.SpanContext.SpanID(), field.Parent.SpanID())
+
+	_, ok = spanNamed(spans, "graphql.field TransferSimulation.ok")
+	assert.False(t, ok, "Plain struct fields should not be traced")
+}
+
+func TestGORMTracing(t *testing.T) {
+	exporter := recordSpans(t)
+
+	// A dry run builds statements without touching a database
+	database, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, database.Use(tracing.GORM{}))
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	var wallet models.Wallet
+	database.WithContext(ctx).Where("address = ?", "0xA").Find(&wallet)
+	parent.End()
+
+	statement, ok := spanNamed(exporter.GetSpans(), "db.select")
+	require.True(t, ok, "Expected a span for the query")
+	assert.Equal(t, parent.SpanContext().SpanID(), statement.Parent.SpanID())
+
+	attributes := map[string]string{}
+	for _, attribute := range statement.Attributes {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	assert.Equal(t, "wallets", attributes["db.collection.name"])
+	assert.Contains(t, attributes["db.query.text"], `FROM "wallets" WHERE address = $1`)
+}
+
+func (suite *GraphQLTestSuite) TestTransferSpans() {
+	exporter := recordSpans(suite.T())
+
+	err := models.InitializeWallet(suite.db, "0xTEST3601", 0)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.resolver.Transfer(context.Background(), "0x1000", "0xTEST3601", 10)
+	assert.NoError(suite.T(), err)
+
+	spans := exporter.GetSpans()
+	transfer, ok := spanNamed(spans, "transfer")
+	suite.Require().True(ok, "Expected a transfer span")
+
+	var locks, saves int
+	for _, span := range spans {
+		if span.Parent.SpanID() != transfer.SpanContext.SpanID() {
+			continue
+		}
+		switch span.Name {
+		case "transfer.lock_wallet":
+			locks++
+		case "transfer.save_wallet":
+			saves++
+		}
+	}
+	assert.Equal(suite.T(), 2, locks, "Expected a span per wallet lock")
+	assert.Equal(suite.T(), 2, saves, "Expected a span per wallet save")
+}
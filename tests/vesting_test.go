@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+	"token-transfer-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVestingTrancheUnlocks(t *testing.T) {
+	day := 24 * time.Hour
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tranche := models.VestingTranche{
+		Amount:   1200,
+		StartsAt: start,
+		CliffAt:  start.Add(30 * day),
+		EndsAt:   start.Add(120 * day),
+	}
+	assert.NoError(t, tranche.Validate())
+
+	assert.Equal(t, 0, tranche.Unlocked(start.Add(29*day)), "Nothing unlocks before the cliff")
+	assert.Equal(t, 300, tranche.Unlocked(start.Add(30*day)), "Cliff releases the time elapsed since start")
+	assert.Equal(t, 900, tranche.Unlocked(start.Add(90*day)), "Linear release after the cliff")
+	assert.Equal(t, 1200, tranche.Unlocked(start.Add(365*day)), "Everything unlocks at the end")
+	assert.Equal(t, 300, tranche.Locked(start.Add(90*day)))
+
+	invalid := tranche
+	invalid.CliffAt = start.Add(-time.Hour)
+	assert.Error(t, invalid.Validate(), "Expected error for cliff before start")
+}
+
+func (suite *GraphQLTestSuite) TestTransferSpendsOnlyUnlockedBalance() {
+	fromAddress := "0x1000"
+	beneficiary := "0xTEST9301"
+	toAddress := "0xTEST9302"
+	now := time.Now()
+
+	err := models.InitializeWallet(suite.db, beneficiary, 100)
+	assert.NoError(suite.T(), err, "Failed to initialize beneficiary wallet")
+	err = models.InitializeWallet(suite.db, toAddress, 0)
+	assert.NoError(suite.T(), err, "Failed to initialize receiver wallet")
+
+	_, err = suite.resolver.GrantVesting(context.Background(), fromAddress, beneficiary, 1000,
+		now.Add(-time.Hour), now.Add(24*time.Hour), now.Add(48*time.Hour))
+	assert.NoError(suite.T(), err, "Failed to grant vesting")
+
+	var wallet models.Wallet
+	suite.db.Where("address = ?", beneficiary).First(&wallet)
+	assert.Equal(suite.T(), 1100, wallet.Balance, "Vested tokens should count towards the total balance")
+
+	locked, err := models.LockedBalance(suite.db, beneficiary, now)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1000, locked, "Granted tokens should be locked until the cliff")
+
+	_, err = suite.resolver.Transfer(context.Background(), beneficiary, toAddress, 101)
+	assert.Error(suite.T(), err, "Expected locked balance error")
+	assert.Equal(suite.T(), "insufficient unlocked balance", err.Error())
+
+	_, err = suite.resolver.Transfer(context.Background(), beneficiary, toAddress, 100)
+	assert.NoError(suite.T(), err, "Failed to transfer unlocked balance")
+}
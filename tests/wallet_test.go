@@ -1,15 +1,16 @@
 package tests
 
 import (
-	"fmt"
-	"os"
+	"context"
 	"testing"
+	"token-transfer-api/config"
+	"token-transfer-api/db"
+	"token-transfer-api/db/migrations"
 	"token-transfer-api/models"
 
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -24,25 +25,23 @@ func (suite *WalletTestSuite) SetupSuite() {
 		suite.T().Log("Warning: .env file not found")
 	}
 
-	user := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	dbname := os.Getenv("POSTGRES_DB")
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
+	cfg, _, err := config.Load(nil)
+	assert.NoError(suite.T(), err, "Failed to load the configuration")
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", host, user, password, dbname, port)
-
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	database, err := db.Connect(cfg.Database)
 	assert.NoError(suite.T(), err, "Failed to connect to the database")
 
 	suite.db = database
 
-	err = suite.db.AutoMigrate(&models.Wallet{})
-	assert.NoError(suite.T(), err, "Failed to auto-migrate Wallet model")
+	err = db.Migrate(suite.db)
+	assert.NoError(suite.T(), err, "Failed to migrate")
 }
 
 func (suite *WalletTestSuite) TearDownSuite() {
-	suite.db.Migrator().DropTable(&models.Wallet{})
+	migrator, err := migrations.New(suite.db)
+	assert.NoError(suite.T(), err, "Failed to load migrations")
+	_, err = migrator.Down(context.Background(), -1)
+	assert.NoError(suite.T(), err, "Failed to revert migrations")
 }
 
 func (suite *WalletTestSuite) TestInitWallet() {
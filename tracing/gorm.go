@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// GORM is a plugin creating a span for every SQL statement, as a child of the span in
+// the statement's context.
+type GORM struct{}
+
+var _ gorm.Plugin = GORM{}
+
+func (GORM) Name() string {
+	return "tracing"
+}
+
+func (GORM) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	for _, err := range []error{
+		callback.Create().Before("gorm:create").Register("tracing:before_create", before("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", after),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", before("select")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", after),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", after),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", after),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type statementSpan struct {
+	span   trace.Span
+	parent context.Context
+}
+
+func before(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		parent := tx.Statement.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, span := Start(parent, "db."+operation,
+			attribute.String("db.system", tx.Dialector.Name()),
+			attribute.String("db.operation.name", operation),
+		)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(spanKey, statementSpan{span: span, parent: parent})
+	}
+}
+
+func after(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	current := value.(statementSpan)
+	tx.Statement.Context = current.parent
+
+	if tx.Statement.Table != "" {
+		current.span.SetAttributes(attribute.String("db.collection.name", tx.Statement.Table))
+	}
+	current.span.SetAttributes(
+		attribute.String("db.query.text", tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	// A missing row is an answer, not a failed statement
+	if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		current.span.End()
+		return
+	}
+	End(current.span, tx.Error)
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// GraphQL is a gqlgen handler extension creating a span per operation and per resolved
+// field. Trivial struct field reads are skipped to keep traces readable.
+type GraphQL struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = GraphQL{}
+
+func (GraphQL) ExtensionName() string {
+	return "OpenTelemetry"
+}
+
+func (GraphQL) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (GraphQL) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+
+	oc := graphql.GetOperationContext(ctx)
+	operationType, operationName := "unknown", oc.OperationName
+	if oc.Operation != nil {
+		operationType = string(oc.Operation.Operation)
+		operationName = oc.Operation.Name
+	}
+	name := "graphql." + operationType
+	if operationName != "" {
+		name += " " + operationName
+	}
+
+	ctx, span := Start(ctx, name,
+		attribute.String("graphql.operation.type", operationType),
+		attribute.String("graphql.operation.name", operationName),
+	)
+	defer span.End()
+
+	response := next(ctx)
+	if response != nil && len(response.Errors) > 0 {
+		span.SetStatus(codes.Error, response.Errors.Error())
+	}
+	return response
+}
+
+func (GraphQL) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver && !fc.IsMethod {
+		return next(ctx)
+	}
+
+	ctx, span := Start(ctx, "graphql.field "+fc.Object+"."+fc.Field.Name,
+		attribute.String("graphql.field.object", fc.Object),
+		attribute.String("graphql.field.name", fc.Field.Name),
+		attribute.String("graphql.field.path", fc.Path().String()),
+	)
+	result, err := next(ctx)
+	End(span, err)
+	return result, err
+}